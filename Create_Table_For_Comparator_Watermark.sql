@@ -0,0 +1,10 @@
+
+-- Create table for the watermarks of the incremental scans
+create TABLE IF NOT EXISTS public.comparator_watermark (
+    jurisdiction VARCHAR(255) NOT NULL,
+    case_type_id VARCHAR(1024) NOT NULL,
+    last_event_created_date timestamp NOT NULL,
+    last_event_id bigint NOT NULL,
+    updated_date timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY (jurisdiction, case_type_id)
+);
@@ -19,6 +19,23 @@ create TABLE IF NOT EXISTS public.event_data_report (
     change_type VARCHAR(255),
     rule_matched BOOLEAN NOT NULL DEFAULT FALSE,
     case_type_id VARCHAR(255),
+    state_id VARCHAR(70),
+    state_name VARCHAR(255),
+    summary VARCHAR(1024),
+    description VARCHAR(65536),
+    user_first_name VARCHAR(255),
+    user_last_name VARCHAR(255),
+    proxied_by VARCHAR(64),
     id SERIAL
 );
 alter sequence public.event_data_report_id_seq OWNED BY public.event_data_report.id CACHE 50;
+
+-- Add the event context columns to a report table created before they were introduced
+alter table public.event_data_report
+    add column IF NOT EXISTS state_id VARCHAR(70),
+    add column IF NOT EXISTS state_name VARCHAR(255),
+    add column IF NOT EXISTS summary VARCHAR(1024),
+    add column IF NOT EXISTS description VARCHAR(65536),
+    add column IF NOT EXISTS user_first_name VARCHAR(255),
+    add column IF NOT EXISTS user_last_name VARCHAR(255),
+    add column IF NOT EXISTS proxied_by VARCHAR(64);
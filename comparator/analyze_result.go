@@ -1,18 +1,23 @@
 package comparator
 
 import (
+	"ccd-comparator-data-diff-rapid/config"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 )
 
 type AnalyzeResult struct {
-	result map[string]Violation
-	mutex  sync.RWMutex
+	result     map[string]Violation
+	caseResult map[string]CaseViolation
+	mutex      sync.RWMutex
 }
 
 func NewAnalyzeResult() *AnalyzeResult {
 	return &AnalyzeResult{
-		result: make(map[string]Violation),
+		result:     make(map[string]Violation),
+		caseResult: make(map[string]CaseViolation),
 	}
 }
 
@@ -30,16 +35,88 @@ func (a *AnalyzeResult) Put(combinedReference string, violation Violation) {
 	a.result[key] = violation
 }
 
+func (a *AnalyzeResult) GetCaseViolation(caseReference int64, sourceEventId int64) CaseViolation {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	key := a.generateCaseKey(caseReference, sourceEventId)
+	return a.caseResult[key]
+}
+
+func (a *AnalyzeResult) PutCaseViolation(violation CaseViolation) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	key := a.generateCaseKey(violation.caseReference, violation.sourceEventId)
+	a.caseResult[key] = violation
+}
+
+func (a *AnalyzeResult) GetCaseViolations() []CaseViolation {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	violations := make([]CaseViolation, 0, len(a.caseResult))
+	for _, violation := range a.caseResult {
+		violations = append(violations, violation)
+	}
+	return violations
+}
+
+// CaseReferences returns the references of the cases with a violation.
+func (a *AnalyzeResult) CaseReferences() map[int64]bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	references := make(map[int64]bool)
+	for key := range a.result {
+		if reference, err := strconv.ParseInt(strings.Split(key, "->")[0], 10, 64); err == nil {
+			references[reference] = true
+		}
+	}
+	for _, violation := range a.caseResult {
+		references[violation.caseReference] = true
+	}
+	return references
+}
+
+// ViolationCounts returns the number of violations of each rule on the changes, counting every rule of the merged
+// violations. Like the report, it leaves out the violations of the events the filter excludes.
+func (a *AnalyzeResult) ViolationCounts(eventFieldChanges EventFieldChanges,
+	filter config.EventFilter) map[RuleType]int {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	counts := make(map[RuleType]int)
+	counted := make(map[string]bool)
+	for combinedReference, fieldChanges := range eventFieldChanges {
+		for _, fieldChange := range fieldChanges {
+			key := a.generateKey(combinedReference, fieldChange.SourceEventId)
+			violation, found := a.result[key]
+			if !found || counted[key] || !IsEventIncluded(filter, fieldChange.SourceEventName, fieldChange.UserId) {
+				continue
+			}
+			counted[key] = true
+			for _, ruleType := range violation.ruleTypes() {
+				counts[ruleType]++
+			}
+		}
+	}
+	for _, violation := range a.caseResult {
+		if !IsEventIncluded(filter, violation.event.Name, violation.event.UserId) {
+			continue
+		}
+		for _, ruleType := range violation.ruleTypes() {
+			counts[ruleType]++
+		}
+	}
+	return counts
+}
+
 func (a *AnalyzeResult) IsNotEmpty() bool {
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
-	return len(a.result) > 0
+	return len(a.result) > 0 || len(a.caseResult) > 0
 }
 
 func (a *AnalyzeResult) IsEmpty() bool {
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
-	return len(a.result) == 0
+	return len(a.result) == 0 && len(a.caseResult) == 0
 }
 
 func (a *AnalyzeResult) Clear() {
@@ -48,14 +125,21 @@ func (a *AnalyzeResult) Clear() {
 	for key := range a.result {
 		delete(a.result, key)
 	}
+	for key := range a.caseResult {
+		delete(a.caseResult, key)
+	}
 }
 
 func (a *AnalyzeResult) Size() int {
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
-	return len(a.result)
+	return len(a.result) + len(a.caseResult)
 }
 
 func (a *AnalyzeResult) generateKey(combinedReference string, sourceEventId int64) string {
 	return fmt.Sprintf("%s_%d", combinedReference, sourceEventId)
 }
+
+func (a *AnalyzeResult) generateCaseKey(caseReference int64, sourceEventId int64) string {
+	return fmt.Sprintf("%d_%d", caseReference, sourceEventId)
+}
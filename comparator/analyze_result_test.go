@@ -1,6 +1,7 @@
 package comparator
 
 import (
+	"ccd-comparator-data-diff-rapid/config"
 	"testing"
 )
 
@@ -56,3 +57,40 @@ func TestAnalyzeResult(t *testing.T) {
 		t.Error("Expected IsEmpty to be true after Clear, but got false")
 	}
 }
+
+func TestAnalyzeResultCaseReferences(t *testing.T) {
+	analyzeResult := NewAnalyzeResult()
+	analyzeResult.Put("1111->.applicant.name", Violation{sourceEventId: 1})
+	analyzeResult.Put("1111->.applicant.age", Violation{sourceEventId: 2})
+	analyzeResult.PutCaseViolation(CaseViolation{Violation: Violation{sourceEventId: 3}, caseReference: 2222})
+
+	references := analyzeResult.CaseReferences()
+
+	if len(references) != 2 || !references[1111] || !references[2222] {
+		t.Errorf("Expected the references 1111 and 2222, but got: %v", references)
+	}
+}
+
+func TestAnalyzeResultViolationCounts(t *testing.T) {
+	analyzeResult := NewAnalyzeResult()
+	analyzeResult.Put("1111->.applicant.name", Violation{sourceEventId: 1, ruleType: RuleTypeStaticFieldChange})
+	analyzeResult.Put("1111->.applicant.age", Violation{sourceEventId: 2, ruleType: RuleTypeStaticFieldChange})
+	analyzeResult.PutCaseViolation(CaseViolation{Violation: Violation{sourceEventId: 3, ruleType: RuleTypeEventBurst},
+		caseReference: 2222, event: EventDetails{Id: 3, Name: "updateCase"}})
+	eventFieldChanges := EventFieldChanges{
+		"1111->.applicant.name": {{SourceEventId: 1, SourceEventName: "updateCase"}},
+		"1111->.applicant.age":  {{SourceEventId: 2, SourceEventName: "createCase"}},
+	}
+
+	counts := analyzeResult.ViolationCounts(eventFieldChanges, config.EventFilter{})
+
+	if len(counts) != 2 || counts[RuleTypeStaticFieldChange] != 2 || counts[RuleTypeEventBurst] != 1 {
+		t.Errorf("Expected 2 staticfieldchange and 1 eventburst violations, but got: %v", counts)
+	}
+
+	counts = analyzeResult.ViolationCounts(eventFieldChanges, config.EventFilter{ExcludeEvents: []string{"createCase"}})
+
+	if len(counts) != 2 || counts[RuleTypeStaticFieldChange] != 1 || counts[RuleTypeEventBurst] != 1 {
+		t.Errorf("Expected the violation of the excluded event to be left out, but got: %v", counts)
+	}
+}
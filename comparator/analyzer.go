@@ -1,6 +1,7 @@
 package comparator
 
 import (
+	"strconv"
 	"strings"
 )
 
@@ -28,6 +29,24 @@ func (e *EventChangesAnalyze) AnalyzeEventFieldChanges() *AnalyzeResult {
 	return e.analyzeResult
 }
 
+func (e *EventChangesAnalyze) AnalyzeCaseEvents(activeCaseRules *[]CaseRule,
+	caseEvents CasesWithEventDetails) *AnalyzeResult {
+	if activeCaseRules != nil && caseEvents != nil {
+		fieldChangesByCase := groupFieldChangesByCase(e.eventFieldChanges)
+		for caseReference, events := range caseEvents {
+			orderedEvents := sortEventsById(events)
+			for _, rule := range *activeCaseRules {
+				violations := rule.CheckCaseForViolation(caseReference, orderedEvents, fieldChangesByCase[caseReference])
+				for _, violation := range violations {
+					e.addCaseAnalyzeDetail(violation)
+				}
+			}
+		}
+	}
+
+	return e.analyzeResult
+}
+
 func (e *EventChangesAnalyze) analyzeFieldDifferencesForCase(combinedReference string, fieldChanges []EventFieldChange) {
 	fieldName := getFieldFromCombinedReference(combinedReference)
 	for _, rule := range *e.activeRules {
@@ -40,6 +59,21 @@ func (e *EventChangesAnalyze) analyzeFieldDifferencesForCase(combinedReference s
 	}
 }
 
+func groupFieldChangesByCase(eventFieldChanges map[string][]EventFieldChange) map[int64]EventFieldChanges {
+	fieldChangesByCase := make(map[int64]EventFieldChanges)
+	for combinedReference, fieldChanges := range eventFieldChanges {
+		caseReference, err := strconv.ParseInt(strings.Split(combinedReference, "->")[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := fieldChangesByCase[caseReference]; !ok {
+			fieldChangesByCase[caseReference] = make(EventFieldChanges)
+		}
+		fieldChangesByCase[caseReference][combinedReference] = fieldChanges
+	}
+	return fieldChangesByCase
+}
+
 func getFieldFromCombinedReference(combinedReference string) string {
 	referenceParts := strings.Split(combinedReference, "->")
 	if len(referenceParts) > 1 {
@@ -54,11 +88,23 @@ func (e *EventChangesAnalyze) addAnalyzeDetail(combinedReference string, violati
 
 	if existingViolation.message != "" {
 		newMessage = appendMessages(existingViolation.message, newMessage)
+		violation.preserveRawRecord = violation.preserveRawRecord || existingViolation.preserveRawRecord
+		violation.mergedRuleTypes = existingViolation.ruleTypes()
 	}
 	violation.message = newMessage
 	e.analyzeResult.Put(combinedReference, violation)
 }
 
+func (e *EventChangesAnalyze) addCaseAnalyzeDetail(violation CaseViolation) {
+	existingViolation := e.analyzeResult.GetCaseViolation(violation.caseReference, violation.sourceEventId)
+
+	if existingViolation.message != "" {
+		violation.message = appendMessages(existingViolation.message, violation.message)
+		violation.mergedRuleTypes = existingViolation.ruleTypes()
+	}
+	e.analyzeResult.PutCaseViolation(violation)
+}
+
 func appendMessages(existingMessage, newMessage string) string {
 	if existingMessage == "" {
 		return newMessage
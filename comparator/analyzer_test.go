@@ -1,6 +1,7 @@
 package comparator
 
 import (
+	"ccd-comparator-data-diff-rapid/config"
 	"ccd-comparator-data-diff-rapid/helper"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -58,7 +59,7 @@ func TestEventDifferencesData_ProcessEventDiff(t *testing.T) {
 		t.Errorf("Expected size : %d, but got: %d", expectedSize, analyzeResult.Size())
 	}
 
-	expectedResultMessage1 := "SV:Field 'field1' changed to 'new_record1' in event id 1 on " + helper.
+	expectedResultMessage1 := "Field 'field1' changed to 'new_record1' in event id 1 on " + helper.
 		FormatTimeStamp(timeNow) + ", " +
 		"but reverted back to the previous value 'old_record1' in event id 1 on " + helper.FormatTimeStamp(timeNow) +
 		"\nJsonNode field change threshold 0 exceeded for field field1." +
@@ -92,3 +93,24 @@ func TestEventDifferencesData_AppendMessages(t *testing.T) {
 	result = appendMessages(existingMessage, v2.message)
 	assert.Equal(t, existingMessage+"\n"+newMessage, result, "Appended message should have both existing and new messages")
 }
+
+type fixedViolationRule struct {
+	ruleType RuleType
+}
+
+func (r fixedViolationRule) CheckForViolation(_ string, fieldChanges []EventFieldChange) []Violation {
+	return []Violation{{sourceEventId: fieldChanges[0].SourceEventId, ruleType: r.ruleType, message: "violation"}}
+}
+
+func TestEventDifferencesData_CountsEveryRuleOnTheSameFieldAndEvent(t *testing.T) {
+	activeRules := []Rule{fixedViolationRule{RuleTypeStaticFieldChange}, fixedViolationRule{RuleTypeFieldChangeCount}}
+	eventDifferences := EventFieldChanges{
+		"1111->field1": {{SourceEventId: 1, SourceEventName: "event1", OperationType: Modified}},
+	}
+
+	analyzeResult := NewEventChangesAnalyze(&activeRules, eventDifferences).AnalyzeEventFieldChanges()
+
+	assert.Equal(t, 1, analyzeResult.Size())
+	assert.Equal(t, map[RuleType]int{RuleTypeStaticFieldChange: 1, RuleTypeFieldChangeCount: 1},
+		analyzeResult.ViolationCounts(eventDifferences, config.EventFilter{}))
+}
@@ -0,0 +1,389 @@
+package comparator
+
+import (
+	"ccd-comparator-data-diff-rapid/helper"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// CaseLevelFieldName is reported as the field name of findings that concern whole events rather than a single field.
+const CaseLevelFieldName = "[case]"
+
+// CaseRule is evaluated once per case against its events ordered by event id, together with the field changes
+// detected for that case.
+type CaseRule interface {
+	CheckCaseForViolation(caseReference int64, events []EventDetails, fieldChanges EventFieldChanges) []CaseViolation
+}
+
+type CaseViolation struct {
+	Violation
+	caseReference int64
+	event         EventDetails
+	operationType OperationType
+}
+
+type DuplicateEventRule struct {
+	burstTimeLimit int64
+	burstMinCount  int
+	ruleType       RuleType
+}
+
+func NewDuplicateEventRule(burstTimeLimit int64, burstMinCount int) *DuplicateEventRule {
+	return &DuplicateEventRule{
+		burstTimeLimit: burstTimeLimit,
+		burstMinCount:  burstMinCount,
+		ruleType:       RuleTypeDuplicateEvent,
+	}
+}
+
+func (d DuplicateEventRule) CheckCaseForViolation(caseReference int64, events []EventDetails,
+	_ EventFieldChanges) []CaseViolation {
+	var violations []CaseViolation
+	var burst []EventDetails
+
+	for i := 1; i < len(events); i++ {
+		currentEvent := events[i]
+		previousEvent := events[i-1]
+
+		if currentEvent.Data != previousEvent.Data {
+			violations = d.appendBurstViolation(violations, caseReference, burst)
+			burst = nil
+			continue
+		}
+
+		preCreatedDate := helper.FormatTimeStamp(previousEvent.CreatedDate)
+		message := fmt.Sprintf("Event id %d '%s' on %s submitted data identical to the previous event id %d '%s' on %s",
+			currentEvent.Id, currentEvent.Name, helper.FormatTimeStamp(currentEvent.CreatedDate), previousEvent.Id,
+			previousEvent.Name, preCreatedDate)
+		violations = append(violations, d.newViolation(caseReference, currentEvent, previousEvent, message))
+
+		if len(burst) > 0 && d.isInBurst(burst[0], currentEvent) {
+			burst = append(burst, currentEvent)
+			continue
+		}
+
+		violations = d.appendBurstViolation(violations, caseReference, burst)
+		if d.isInBurst(previousEvent, currentEvent) {
+			burst = []EventDetails{previousEvent, currentEvent}
+		} else {
+			burst = []EventDetails{currentEvent}
+		}
+	}
+
+	return d.appendBurstViolation(violations, caseReference, burst)
+}
+
+func (d DuplicateEventRule) isInBurst(burstStart, event EventDetails) bool {
+	timeDifference := event.CreatedDate.Sub(burstStart.CreatedDate).Milliseconds()
+	return event.UserId == burstStart.UserId && checkThreshold(d.burstTimeLimit, timeDifference)
+}
+
+func (d DuplicateEventRule) appendBurstViolation(violations []CaseViolation, caseReference int64,
+	burst []EventDetails) []CaseViolation {
+	if len(burst) < 2 || len(burst) < d.burstMinCount {
+		return violations
+	}
+
+	firstEvent := burst[0]
+	lastEvent := burst[len(burst)-1]
+	eventIds := make([]int64, 0, len(burst))
+	for _, event := range burst {
+		eventIds = append(eventIds, event.Id)
+	}
+
+	message := fmt.Sprintf("Burst of %d identical events submitted by user '%s' within %d ms: event ids %v",
+		len(burst), lastEvent.UserId, lastEvent.CreatedDate.Sub(firstEvent.CreatedDate).Milliseconds(), eventIds)
+
+	return append(violations, d.newViolation(caseReference, lastEvent, firstEvent, message))
+}
+
+func (d DuplicateEventRule) newViolation(caseReference int64, event, previousEvent EventDetails,
+	message string) CaseViolation {
+	return CaseViolation{
+		Violation: Violation{
+			sourceEventId:            event.Id,
+			previousEventId:          previousEvent.Id,
+			previousEventCreatedDate: helper.FormatTimeStamp(previousEvent.CreatedDate),
+			previousEventUserId:      previousEvent.UserId,
+			previousEventName:        previousEvent.Name,
+			ruleType:                 d.ruleType,
+			message:                  message,
+		},
+		caseReference: caseReference,
+		event:         event,
+		operationType: NoChange,
+	}
+}
+
+type EventBurstRule struct {
+	windowTimeLimit int64
+	minEventCount   int
+	minUserCount    int
+	ruleType        RuleType
+}
+
+func NewEventBurstRule(windowTimeLimit int64, minEventCount, minUserCount int) *EventBurstRule {
+	return &EventBurstRule{
+		windowTimeLimit: windowTimeLimit,
+		minEventCount:   minEventCount,
+		minUserCount:    minUserCount,
+		ruleType:        RuleTypeEventBurst,
+	}
+}
+
+// CheckCaseForViolation reports every window in which at least minEventCount events from at least minUserCount
+// distinct users were created within windowTimeLimit milliseconds. Overlapping windows are merged into one.
+func (b EventBurstRule) CheckCaseForViolation(caseReference int64, events []EventDetails,
+	fieldChanges EventFieldChanges) []CaseViolation {
+	var violations []CaseViolation
+
+	windowStart, windowEnd := -1, -1
+	for start := range events {
+		end := start
+		for end+1 < len(events) && checkThreshold(b.windowTimeLimit,
+			events[end+1].CreatedDate.Sub(events[start].CreatedDate).Milliseconds()) {
+			end++
+		}
+
+		if !b.isBurst(events[start : end+1]) {
+			continue
+		}
+
+		if windowEnd >= start {
+			if end > windowEnd {
+				windowEnd = end
+			}
+			continue
+		}
+
+		if windowStart >= 0 {
+			violations = append(violations, b.newViolation(caseReference, events[windowStart:windowEnd+1], fieldChanges))
+		}
+		windowStart, windowEnd = start, end
+	}
+
+	if windowStart >= 0 {
+		violations = append(violations, b.newViolation(caseReference, events[windowStart:windowEnd+1], fieldChanges))
+	}
+
+	return violations
+}
+
+func (b EventBurstRule) isBurst(window []EventDetails) bool {
+	return len(window) >= b.minEventCount && len(distinctUserIds(window)) >= b.minUserCount
+}
+
+func (b EventBurstRule) newViolation(caseReference int64, window []EventDetails,
+	fieldChanges EventFieldChanges) CaseViolation {
+	firstEvent := window[0]
+	lastEvent := window[len(window)-1]
+
+	eventIds := make([]int64, 0, len(window))
+	eventNames := make([]string, 0, len(window))
+	for _, event := range window {
+		eventIds = append(eventIds, event.Id)
+		eventNames = append(eventNames, event.Name)
+	}
+
+	fieldsTouched := fieldsChangedByEvents(eventIds, fieldChanges)
+	operationType := NoChange
+	if len(fieldsTouched) > 0 {
+		operationType = Modified
+	}
+
+	message := fmt.Sprintf("%d events by %d users %v within %d ms: event ids %v, events %v, fields touched %v",
+		len(window), len(distinctUserIds(window)), distinctUserIds(window),
+		lastEvent.CreatedDate.Sub(firstEvent.CreatedDate).Milliseconds(), eventIds, eventNames, fieldsTouched)
+
+	return CaseViolation{
+		Violation: Violation{
+			sourceEventId:            lastEvent.Id,
+			previousEventId:          firstEvent.Id,
+			previousEventCreatedDate: helper.FormatTimeStamp(firstEvent.CreatedDate),
+			previousEventUserId:      firstEvent.UserId,
+			previousEventName:        firstEvent.Name,
+			ruleType:                 b.ruleType,
+			message:                  message,
+		},
+		caseReference: caseReference,
+		event:         lastEvent,
+		operationType: operationType,
+	}
+}
+
+func distinctUserIds(events []EventDetails) []string {
+	seen := make(map[string]bool)
+	var userIds []string
+	for _, event := range events {
+		if !seen[event.UserId] {
+			seen[event.UserId] = true
+			userIds = append(userIds, event.UserId)
+		}
+	}
+	return userIds
+}
+
+// fieldsChangedByEvents returns the sorted field names changed by any of the given events.
+func fieldsChangedByEvents(eventIds []int64, fieldChanges EventFieldChanges) []string {
+	inWindow := make(map[int64]bool, len(eventIds))
+	for _, eventId := range eventIds {
+		inWindow[eventId] = true
+	}
+
+	var fields []string
+	for combinedReference, changes := range fieldChanges {
+		for _, change := range changes {
+			if change.OperationType != NoChange && inWindow[change.SourceEventId] {
+				fields = append(fields, getFieldFromCombinedReference(combinedReference))
+				break
+			}
+		}
+	}
+	sort.Strings(fields)
+
+	return fields
+}
+
+type DocumentLossRule struct {
+	isScanReportMask bool
+	ruleType         RuleType
+}
+
+func NewDocumentLossRule(isScanReportMask bool) *DocumentLossRule {
+	return &DocumentLossRule{
+		isScanReportMask: isScanReportMask,
+		ruleType:         RuleTypeDocumentLoss,
+	}
+}
+
+// caseDocument is a CCD document object found in the event data, located by its path.
+// Collection items are addressed by their id so that reordering a collection does not move its documents.
+type caseDocument struct {
+	path           string
+	collectionItem string
+	url            string
+	binaryUrl      string
+	filename       string
+}
+
+// CheckCaseForViolation flags every event that removes a document, or replaces its url, unless the old
+// document still appears somewhere else in the same event.
+func (d DocumentLossRule) CheckCaseForViolation(caseReference int64, events []EventDetails,
+	_ EventFieldChanges) []CaseViolation {
+	var violations []CaseViolation
+	var previousDocuments map[string]caseDocument
+
+	for i, event := range events {
+		documents := findCaseDocuments(event.Data)
+		if i == 0 {
+			previousDocuments = documents
+			continue
+		}
+
+		remainingUrls := make(map[string]bool, len(documents))
+		for _, document := range documents {
+			remainingUrls[document.url] = true
+			if document.binaryUrl != "" {
+				remainingUrls[document.binaryUrl] = true
+			}
+		}
+
+		for _, path := range sortedDocumentPaths(previousDocuments) {
+			previousDocument := previousDocuments[path]
+			if remainingUrls[previousDocument.url] ||
+				(previousDocument.binaryUrl != "" && remainingUrls[previousDocument.binaryUrl]) {
+				continue
+			}
+
+			previousEvent := events[i-1]
+			location := ""
+			if previousDocument.collectionItem != "" {
+				location = fmt.Sprintf(" in collection item '%s'", previousDocument.collectionItem)
+			}
+
+			operationType := Deleted
+			message := fmt.Sprintf("Document '%s'%s at '%s' was removed in event id %d on %s",
+				processInputValue(previousDocument.filename, d.isScanReportMask), location, path, event.Id,
+				helper.FormatTimeStamp(event.CreatedDate))
+			if document, ok := documents[path]; ok {
+				operationType = Modified
+				message = fmt.Sprintf("Document '%s'%s at '%s' was replaced by '%s' in event id %d on %s",
+					processInputValue(previousDocument.filename, d.isScanReportMask), location, path,
+					processInputValue(document.filename, d.isScanReportMask), event.Id,
+					helper.FormatTimeStamp(event.CreatedDate))
+			}
+
+			violations = append(violations, CaseViolation{
+				Violation: Violation{
+					sourceEventId:            event.Id,
+					previousEventId:          previousEvent.Id,
+					previousEventCreatedDate: helper.FormatTimeStamp(previousEvent.CreatedDate),
+					previousEventUserId:      previousEvent.UserId,
+					previousEventName:        previousEvent.Name,
+					ruleType:                 d.ruleType,
+					message:                  message,
+				},
+				caseReference: caseReference,
+				event:         event,
+				operationType: operationType,
+			})
+		}
+
+		previousDocuments = documents
+	}
+
+	return violations
+}
+
+func findCaseDocuments(data string) map[string]caseDocument {
+	documents := make(map[string]caseDocument)
+
+	var node any
+	if err := json.Unmarshal([]byte(data), &node); err != nil {
+		return documents
+	}
+
+	collectCaseDocuments(node, "", "", documents)
+	return documents
+}
+
+func collectCaseDocuments(node any, path, collectionItem string, documents map[string]caseDocument) {
+	switch value := node.(type) {
+	case map[string]any:
+		if url, ok := value["document_url"].(string); ok && url != "" {
+			binaryUrl, _ := value["document_binary_url"].(string)
+			filename, _ := value["document_filename"].(string)
+			documents[path] = caseDocument{
+				path:           path,
+				collectionItem: collectionItem,
+				url:            url,
+				binaryUrl:      binaryUrl,
+				filename:       filename,
+			}
+			return
+		}
+		for key, child := range value {
+			collectCaseDocuments(child, path+"."+key, collectionItem, documents)
+		}
+	case []any:
+		for index, child := range value {
+			itemPath := fmt.Sprintf("%s[%d]", path, index)
+			if item, ok := child.(map[string]any); ok {
+				if id, ok := item["id"].(string); ok && id != "" {
+					itemPath = fmt.Sprintf("%s[%s]", path, id)
+				}
+			}
+			collectCaseDocuments(child, itemPath, itemPath, documents)
+		}
+	}
+}
+
+func sortedDocumentPaths(documents map[string]caseDocument) []string {
+	paths := make([]string, 0, len(documents))
+	for path := range documents {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
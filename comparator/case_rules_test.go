@@ -0,0 +1,201 @@
+package comparator
+
+import (
+	"ccd-comparator-data-diff-rapid/helper"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDuplicateEventRule_FlagsIdenticalEventAndBurst(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+
+	events := []EventDetails{
+		{Id: 1, Name: "createCase", CreatedDate: createdDateBase, Data: `{"a":"1"}`, UserId: "user1"},
+		{Id: 2, Name: "updateCase", CreatedDate: createdDateBase.Add(time.Minute), Data: `{"a":"2"}`, UserId: "user1"},
+		{Id: 3, Name: "updateCase", CreatedDate: createdDateBase.Add(time.Minute + time.Second), Data: `{"a":"2"}`,
+			UserId: "user1"},
+		{Id: 4, Name: "updateCase", CreatedDate: createdDateBase.Add(time.Minute + 2*time.Second), Data: `{"a":"2"}`,
+			UserId: "user1"},
+	}
+
+	rule := NewDuplicateEventRule(5000, 2)
+	result := rule.CheckCaseForViolation(1234, events, nil)
+
+	assert.Len(t, result, 3)
+	assert.Equal(t, int64(3), result[0].sourceEventId)
+	assert.Equal(t, int64(2), result[0].previousEventId)
+	assert.Equal(t, "Event id 3 'updateCase' on "+helper.FormatTimeStamp(events[2].CreatedDate)+
+		" submitted data identical to the previous event id 2 'updateCase' on "+
+		helper.FormatTimeStamp(events[1].CreatedDate), result[0].message)
+	assert.Equal(t, int64(4), result[1].sourceEventId)
+
+	burst := result[2]
+	assert.Equal(t, int64(4), burst.sourceEventId)
+	assert.Equal(t, int64(2), burst.previousEventId)
+	assert.Equal(t, "Burst of 3 identical events submitted by user 'user1' within 2000 ms: event ids [2 3 4]",
+		burst.message)
+	assert.Equal(t, NoChange, burst.operationType)
+	assert.Equal(t, int64(1234), burst.caseReference)
+}
+
+func TestDuplicateEventRule_NoBurstAcrossUsersOrOutsideThreshold(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+
+	events := []EventDetails{
+		{Id: 1, Name: "updateCase", CreatedDate: createdDateBase, Data: `{"a":"1"}`, UserId: "user1"},
+		{Id: 2, Name: "updateCase", CreatedDate: createdDateBase.Add(time.Second), Data: `{"a":"1"}`, UserId: "user2"},
+		{Id: 3, Name: "updateCase", CreatedDate: createdDateBase.Add(time.Hour), Data: `{"a":"1"}`, UserId: "user2"},
+	}
+
+	rule := NewDuplicateEventRule(5000, 2)
+	result := rule.CheckCaseForViolation(1234, events, nil)
+
+	assert.Len(t, result, 2)
+	for _, violation := range result {
+		assert.NotContains(t, violation.message, "Burst")
+	}
+}
+
+func TestDuplicateEventRule_BurstMinCount(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+
+	events := []EventDetails{
+		{Id: 1, Name: "updateCase", CreatedDate: createdDateBase, Data: `{"a":"1"}`, UserId: "user1"},
+		{Id: 2, Name: "updateCase", CreatedDate: createdDateBase.Add(time.Second), Data: `{"a":"1"}`, UserId: "user1"},
+	}
+
+	result := NewDuplicateEventRule(5000, 3).CheckCaseForViolation(1234, events, nil)
+	assert.Len(t, result, 1)
+
+	result = NewDuplicateEventRule(-1, 2).CheckCaseForViolation(1234, events, nil)
+	assert.Len(t, result, 2)
+}
+
+func TestEventChangesAnalyze_AnalyzeCaseEvents(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+	caseEvents := CasesWithEventDetails{
+		1234: {
+			2: {Id: 2, Name: "updateCase", CreatedDate: createdDateBase.Add(time.Second), Data: `{"a":"1"}`,
+				UserId: "user1", CaseTypeId: "caseType"},
+			1: {Id: 1, Name: "createCase", CreatedDate: createdDateBase, Data: `{"a":"1"}`, UserId: "user1",
+				CaseTypeId: "caseType"},
+		},
+	}
+
+	activeCaseRules := []CaseRule{NewDuplicateEventRule(5000, 2)}
+	analyzeResult := NewEventChangesAnalyze(nil, nil).AnalyzeCaseEvents(&activeCaseRules, caseEvents)
+
+	assert.Equal(t, 1, analyzeResult.Size())
+	violation := analyzeResult.GetCaseViolation(1234, 2)
+	assert.Contains(t, violation.message, "submitted data identical to the previous event id 1")
+	assert.Contains(t, violation.message, "\nBurst of 2 identical events")
+
+	entity := newCaseViolationReportEntity(violation)
+	assert.Equal(t, "1234", entity.Reference)
+	assert.Equal(t, CaseLevelFieldName, entity.FieldName)
+	assert.Equal(t, string(NoChange), entity.ChangeType)
+	assert.Equal(t, int64(1), entity.PreviousEventId)
+	assert.Equal(t, "caseType", entity.CaseTypeId)
+	assert.True(t, entity.RuleMatched)
+	assert.Equal(t, time.Duration(1000), entity.EventDelta)
+}
+
+func TestEventBurstRule_ReportsMergedWindowWithFieldsTouched(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+
+	events := []EventDetails{
+		{Id: 1, Name: "createCase", CreatedDate: createdDateBase, UserId: "user1"},
+		{Id: 2, Name: "updateCase", CreatedDate: createdDateBase.Add(time.Hour), UserId: "user1"},
+		{Id: 3, Name: "uploadDocument", CreatedDate: createdDateBase.Add(time.Hour + time.Second), UserId: "user2"},
+		{Id: 4, Name: "updateCase", CreatedDate: createdDateBase.Add(time.Hour + 2*time.Second), UserId: "user1"},
+		{Id: 5, Name: "addNote", CreatedDate: createdDateBase.Add(time.Hour + 4*time.Second), UserId: "user3"},
+		{Id: 6, Name: "updateCase", CreatedDate: createdDateBase.Add(2 * time.Hour), UserId: "user1"},
+	}
+	fieldChanges := EventFieldChanges{
+		"1234->.name": {
+			{SourceEventId: 2, OperationType: Modified},
+			{SourceEventId: 4, OperationType: Modified},
+		},
+		"1234->.documents.value.document_url": {
+			{SourceEventId: 3, OperationType: ArrayExtended},
+		},
+		"1234->.notes": {
+			{SourceEventId: 5, OperationType: NoChange},
+		},
+		"1234->.status": {
+			{SourceEventId: 6, OperationType: Modified},
+		},
+	}
+
+	rule := NewEventBurstRule(3000, 3, 2)
+	result := rule.CheckCaseForViolation(1234, events, fieldChanges)
+
+	assert.Len(t, result, 1)
+	assert.Equal(t, int64(5), result[0].sourceEventId)
+	assert.Equal(t, int64(2), result[0].previousEventId)
+	assert.Equal(t, Modified, result[0].operationType)
+	assert.Equal(t, "4 events by 3 users [user1 user2 user3] within 4000 ms: event ids [2 3 4 5], "+
+		"events [updateCase uploadDocument updateCase addNote], fields touched [.documents.value.document_url .name]",
+		result[0].message)
+}
+
+func TestEventBurstRule_IgnoresSingleUserWindow(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+
+	events := []EventDetails{
+		{Id: 1, Name: "updateCase", CreatedDate: createdDateBase, UserId: "user1"},
+		{Id: 2, Name: "updateCase", CreatedDate: createdDateBase.Add(time.Second), UserId: "user1"},
+		{Id: 3, Name: "updateCase", CreatedDate: createdDateBase.Add(2 * time.Second), UserId: "user1"},
+	}
+
+	result := NewEventBurstRule(3000, 3, 2).CheckCaseForViolation(1234, events, nil)
+	assert.Nil(t, result)
+
+	events[2].UserId = "user2"
+	result = NewEventBurstRule(3000, 3, 2).CheckCaseForViolation(1234, events, nil)
+	assert.Len(t, result, 1)
+	assert.Equal(t, NoChange, result[0].operationType)
+}
+
+func TestDocumentLossRule_CheckCaseForViolation(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+
+	events := []EventDetails{
+		{Id: 1, Name: "uploadDocuments", CreatedDate: createdDateBase, UserId: "user1", Data: `{
+			"evidence": {"document_url": "http://dm/documents/1", "document_filename": "evidence.pdf"},
+			"bundle": [
+				{"id": "item-a", "value": {"file": {"document_url": "http://dm/documents/2", "document_filename": "a.pdf"}}},
+				{"id": "item-b", "value": {"file": {"document_url": "http://dm/documents/3", "document_filename": "b.pdf"}}}
+			]}`},
+		{Id: 2, Name: "reorderBundle", CreatedDate: createdDateBase.Add(time.Minute), UserId: "user2", Data: `{
+			"evidence": {"document_url": "http://dm/documents/1", "document_filename": "evidence.pdf"},
+			"archived": {"document_url": "http://dm/documents/3", "document_filename": "b.pdf"},
+			"bundle": [
+				{"id": "item-b", "value": {"file": {"document_url": "http://dm/documents/4", "document_filename": "c.pdf"}}}
+			]}`},
+		{Id: 3, Name: "callback", CreatedDate: createdDateBase.Add(2 * time.Minute), UserId: "system", Data: `{
+			"archived": {"document_url": "http://dm/documents/3", "document_filename": "b.pdf"},
+			"bundle": [
+				{"id": "item-b", "value": {"file": {"document_url": "http://dm/documents/5", "document_filename": "d.pdf"}}}
+			]}`},
+	}
+
+	result := NewDocumentLossRule(false).CheckCaseForViolation(1234, events, nil)
+
+	assert.Len(t, result, 3)
+	assert.Equal(t, int64(2), result[0].sourceEventId)
+	assert.Equal(t, Deleted, result[0].operationType)
+	assert.Equal(t, "Document 'a.pdf' in collection item '.bundle[item-a]' at '.bundle[item-a].value.file' "+
+		"was removed in event id 2 on "+helper.FormatTimeStamp(events[1].CreatedDate), result[0].message)
+
+	assert.Equal(t, int64(3), result[1].sourceEventId)
+	assert.Equal(t, Modified, result[1].operationType)
+	assert.Equal(t, "Document 'c.pdf' in collection item '.bundle[item-b]' at '.bundle[item-b].value.file' "+
+		"was replaced by 'd.pdf' in event id 3 on "+helper.FormatTimeStamp(events[2].CreatedDate), result[1].message)
+
+	assert.Equal(t, int64(3), result[2].sourceEventId)
+	assert.Equal(t, int64(2), result[2].previousEventId)
+	assert.Equal(t, "Document 'evidence.pdf' at '.evidence' was removed in event id 3 on "+
+		helper.FormatTimeStamp(events[2].CreatedDate), result[2].message)
+}
@@ -31,13 +31,52 @@ const (
 )
 
 type EventDetails struct {
-	Id          int64
-	Name        string
-	CreatedDate time.Time
-	Data        string
-	CaseDataId  int64
-	UserId      string
-	CaseTypeId  string
+	Id            int64
+	Name          string
+	CreatedDate   time.Time
+	Data          string
+	CaseDataId    int64
+	UserId        string
+	CaseTypeId    string
+	StateId       string
+	StateName     string
+	Summary       string
+	Description   string
+	UserFirstName string
+	UserLastName  string
+	ProxiedBy     string
+}
+
+// EventContext describes an event beyond its data, as far as the event source provides it.
+type EventContext struct {
+	StateId       string
+	StateName     string
+	Summary       string
+	Description   string
+	UserFirstName string
+	UserLastName  string
+	ProxiedBy     string
+}
+
+func (e EventDetails) Context() EventContext {
+	return EventContext{
+		StateId:       e.StateId,
+		StateName:     e.StateName,
+		Summary:       e.Summary,
+		Description:   e.Description,
+		UserFirstName: e.UserFirstName,
+		UserLastName:  e.UserLastName,
+		ProxiedBy:     e.ProxiedBy,
+	}
+}
+
+// sharedContext returns the context for the changes of the event to share, or nil when the event isn't described.
+func (e EventDetails) sharedContext() *EventContext {
+	context := e.Context()
+	if context == (EventContext{}) {
+		return nil
+	}
+	return &context
 }
 
 type EventFieldChange struct {
@@ -49,18 +88,25 @@ type EventFieldChange struct {
 	OperationType   OperationType
 	UserId          string
 	CaseTypeId      string
+	// PreviousStateId is the state the case was in when the source event was submitted.
+	PreviousStateId string
+	// Context describes the source event, and is shared by the changes of the event. It is nil when the source
+	// event isn't described.
+	Context *EventContext
 }
 
 type comparisonParams struct {
-	base        any
-	compareWith any
-	differences *differences
-	parentPath  string
-	eventId     int64
-	createdDate time.Time
-	eventName   string
-	userId      string
-	caseTypeId  string
+	base            any
+	compareWith     any
+	differences     *differences
+	parentPath      string
+	eventId         int64
+	createdDate     time.Time
+	eventName       string
+	userId          string
+	caseTypeId      string
+	previousStateId string
+	eventContext    *EventContext
 }
 
 type CasesWithEventDetails map[int64]map[int64]EventDetails
@@ -105,46 +151,106 @@ func CompareEventsByCaseReference(transactionId string, caseEvents CasesWithEven
 }
 
 func detectEventModifications(caseReference int64, eventDetails map[int64]EventDetails) EventFieldChanges {
-	fieldDifferences := newDifferences()
-	var base jsonx.NodeAny
+	caseEventStream := NewCaseEventStream(caseReference)
+	for _, eventDetail := range sortEventsById(eventDetails) {
+		caseEventStream.Add(eventDetail)
+	}
 
+	return caseEventStream.Changes()
+}
+
+// CaseEventStream diffs the events of one case as they arrive in event id order, keeping only the previous event
+// in memory. The changes are kept until they are flushed.
+type CaseEventStream struct {
+	caseReference   int64
+	base            jsonx.NodeAny
+	previousStateId string
+	differences     *differences
+	// flushedChanges holds the last flushed changes of each path, which the rules compare the next changes with.
+	flushedChanges EventFieldChanges
+}
+
+func NewCaseEventStream(caseReference int64) *CaseEventStream {
+	return &CaseEventStream{
+		caseReference:  caseReference,
+		differences:    newDifferences(),
+		flushedChanges: make(EventFieldChanges),
+	}
+}
+
+// Add compares the event with the previous one. The first event is only used as the base of the comparison.
+func (c *CaseEventStream) Add(eventDetail EventDetails) {
+	if c.base == nil {
+		// Unmarshal the base data from the first event
+		jsonx.MustUnmarshal([]byte(eventDetail.Data), &c.base)
+		c.previousStateId = eventDetail.StateId
+		return
+	}
+
+	var compareWith jsonx.NodeAny
+	jsonx.MustUnmarshal([]byte(eventDetail.Data), &compareWith)
+	params := comparisonParams{
+		base:            c.base,
+		compareWith:     compareWith,
+		differences:     c.differences,
+		parentPath:      strconv.FormatInt(c.caseReference, 10) + "->",
+		eventId:         eventDetail.Id,
+		createdDate:     eventDetail.CreatedDate,
+		eventName:       eventDetail.Name,
+		userId:          eventDetail.UserId,
+		caseTypeId:      eventDetail.CaseTypeId,
+		previousStateId: c.previousStateId,
+		eventContext:    eventDetail.sharedContext(),
+	}
+
+	compareJsonNodes(params)
+	c.base = compareWith
+	c.previousStateId = eventDetail.StateId
+}
+
+func (c *CaseEventStream) Changes() EventFieldChanges {
+	return c.differences.differencesByPath
+}
+
+// Flush returns the changes since the previous flush, and the same changes preceded by up to contextSize flushed
+// changes of their path for the rules to compare them with. Only those contextSize changes of each path are kept.
+func (c *CaseEventStream) Flush(contextSize int) (changes EventFieldChanges, changesWithContext EventFieldChanges) {
+	changes = make(EventFieldChanges)
+	changesWithContext = make(EventFieldChanges)
+	for path, pathChanges := range c.differences.differencesByPath {
+		// The path is kept, without its changes, so that NO_CHANGE records of the changed paths are still made
+		c.differences.differencesByPath[path] = nil
+		if len(pathChanges) == 0 {
+			continue
+		}
+
+		changes[path] = pathChanges
+		withContext := append(append([]EventFieldChange(nil), c.flushedChanges[path]...), pathChanges...)
+		changesWithContext[path] = withContext
+		if len(withContext) > contextSize {
+			withContext = withContext[len(withContext)-contextSize:]
+		}
+		c.flushedChanges[path] = append([]EventFieldChange(nil), withContext...)
+	}
+	return changes, changesWithContext
+}
+
+// sortEventsById returns the events of a case in ascending event id order.
+func sortEventsById(eventDetails map[int64]EventDetails) []EventDetails {
 	keys := make([]int64, 0, len(eventDetails))
 	for eventId := range eventDetails {
 		keys = append(keys, eventId)
 	}
 
-	// Sort the keys to ensure they are in ascending order
 	sort.Slice(keys, func(i, j int) bool {
 		return keys[i] < keys[j]
 	})
 
+	events := make([]EventDetails, 0, len(keys))
 	for _, eventId := range keys {
-		eventDetail := eventDetails[eventId]
-		if base == nil {
-			// Unmarshal the base data from the first event
-			jsonx.MustUnmarshal([]byte(eventDetail.Data), &base)
-			continue
-		}
-
-		var compareWith jsonx.NodeAny
-		jsonx.MustUnmarshal([]byte(eventDetail.Data), &compareWith)
-		params := comparisonParams{
-			base:        base,
-			compareWith: compareWith,
-			differences: fieldDifferences,
-			parentPath:  strconv.FormatInt(caseReference, 10) + "->",
-			eventId:     eventId,
-			createdDate: eventDetail.CreatedDate,
-			eventName:   eventDetail.Name,
-			userId:      eventDetail.UserId,
-			caseTypeId:  eventDetail.CaseTypeId,
-		}
-
-		compareJsonNodes(params)
-		base = compareWith
+		events = append(events, eventDetails[eventId])
 	}
-
-	return fieldDifferences.differencesByPath
+	return events
 }
 
 func compareJsonNodes(params comparisonParams) {
@@ -155,27 +261,27 @@ func compareJsonNodes(params comparisonParams) {
 			currentPath := fmt.Sprintf("%s.%s", params.parentPath, key)
 			if compareValue, ok := compareNode[key]; ok {
 				innerParams := comparisonParams{
-					base:        value,
-					compareWith: compareValue,
-					differences: params.differences,
-					parentPath:  currentPath,
-					eventId:     params.eventId,
-					createdDate: params.createdDate,
-					eventName:   params.eventName,
-					userId:      params.userId,
-					caseTypeId:  params.caseTypeId,
+					base:            value,
+					compareWith:     compareValue,
+					differences:     params.differences,
+					parentPath:      currentPath,
+					eventId:         params.eventId,
+					createdDate:     params.createdDate,
+					eventName:       params.eventName,
+					userId:          params.userId,
+					caseTypeId:      params.caseTypeId,
+					previousStateId: params.previousStateId,
+					eventContext:    params.eventContext,
 				}
 				compareJsonNodes(innerParams)
 			} else {
-				params.differences.recordDifferenceAtPath(currentPath, createDifference(value, "", params.eventId,
-					params.createdDate, Deleted, params.eventName, params.userId, params.caseTypeId))
+				params.differences.recordDifferenceAtPath(currentPath, createDifference(value, "", Deleted, params))
 			}
 		}
 		for key, value := range compareNode {
 			currentPath := fmt.Sprintf("%s.%s", params.parentPath, key)
 			if _, ok := baseNode[key]; !ok {
-				params.differences.recordDifferenceAtPath(currentPath, createDifference("", value, params.eventId,
-					params.createdDate, Added, params.eventName, params.userId, params.caseTypeId))
+				params.differences.recordDifferenceAtPath(currentPath, createDifference("", value, Added, params))
 			}
 		}
 	} else {
@@ -196,18 +302,15 @@ func compareJsonNodes(params comparisonParams) {
 				for key, items := range changes {
 					itemsJson := jsonx.MustMarshal(items)
 					params.differences.recordDifferenceAtPath(params.parentPath+key, createDifference("",
-						string(itemsJson), params.eventId, params.createdDate, changeType, params.eventName,
-						params.userId, params.caseTypeId))
+						string(itemsJson), changeType, params))
 				}
 			}
 		} else if !compareWithEqual(params.base, params.compareWith) {
 			params.differences.recordDifferenceAtPath(params.parentPath, createDifference(params.base,
-				params.compareWith, params.eventId, params.createdDate, Modified, params.eventName, params.userId,
-				params.caseTypeId))
+				params.compareWith, Modified, params))
 		} else {
 			params.differences.recordDifferenceAtPath(params.parentPath, createDifference(params.base,
-				params.compareWith, params.eventId, params.createdDate,
-				NoChange, params.eventName, params.userId, params.caseTypeId))
+				params.compareWith, NoChange, params))
 		}
 	}
 }
@@ -401,8 +504,7 @@ func compareWithEqual(base, compareWith any) bool {
 	return string(baseBytes) == string(compareBytes)
 }
 
-func createDifference(oldRecord, newRecord any, eventId int64, createdDate time.Time, operationType OperationType,
-	eventName, userId, caseTypeId string) EventFieldChange {
+func createDifference(oldRecord, newRecord any, operationType OperationType, params comparisonParams) EventFieldChange {
 
 	oldRecordValue, oBase := oldRecord.(string)
 	if !oBase {
@@ -419,11 +521,13 @@ func createDifference(oldRecord, newRecord any, eventId int64, createdDate time.
 	return EventFieldChange{
 		OldRecord:       oldRecordValue,
 		NewRecord:       newRecordValue,
-		SourceEventId:   eventId,
-		SourceEventName: eventName,
-		CreatedDate:     createdDate,
+		SourceEventId:   params.eventId,
+		SourceEventName: params.eventName,
+		CreatedDate:     params.createdDate,
 		OperationType:   operationType,
-		UserId:          userId,
-		CaseTypeId:      caseTypeId,
+		UserId:          params.userId,
+		CaseTypeId:      params.caseTypeId,
+		PreviousStateId: params.previousStateId,
+		Context:         params.eventContext,
 	}
 }
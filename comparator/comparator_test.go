@@ -155,7 +155,16 @@ func Test_compareNodes(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			compareJsonNodes(tt.args.base, tt.args.parentPath, tt.args.eventId, tt.args.createdDate, tt.args.eventName, "1")
+			compareJsonNodes(comparisonParams{
+				base:        tt.args.base,
+				compareWith: tt.args.compareWith,
+				differences: tt.args.differences,
+				parentPath:  tt.args.parentPath,
+				eventId:     tt.args.eventId,
+				createdDate: tt.args.createdDate,
+				eventName:   tt.args.eventName,
+				userId:      "1",
+			})
 
 			if !reflect.DeepEqual(tt.args.differences, tt.want) {
 				t.Errorf("Unexpected mergedDifferences:\nGot: %#v\nWant: %#v", tt.args.differences, tt.want)
@@ -294,3 +303,37 @@ func TestCompareCaseEvents(t *testing.T) {
 		t.Errorf("Unexpected result.\nwan: %+v\nGot: %+v", expectedResult, result)
 	}
 }
+
+func TestCaseEventStreamFlush(t *testing.T) {
+	createdDate := helper.MustParseTime(layout, "2023-07-25")
+	stream := NewCaseEventStream(123)
+	for id, data := range []string{`{"field1": "a"}`, `{"field1": "b"}`, `{"field1": "c"}`, `{"field1": "d"}`} {
+		stream.Add(EventDetails{Id: int64(id + 1), CreatedDate: createdDate, Data: data})
+	}
+
+	changes, changesWithContext := stream.Flush(1)
+	if len(changes["123->.field1"]) != 3 || len(changesWithContext["123->.field1"]) != 3 {
+		t.Fatalf("Unexpected first flush. \nGot: %v\nWith context: %v", changes, changesWithContext)
+	}
+	if len(stream.Changes()["123->.field1"]) != 0 {
+		t.Errorf("Flushed changes are still held: %v", stream.Changes())
+	}
+
+	stream.Add(EventDetails{Id: 5, CreatedDate: createdDate, Data: `{"field1": "e"}`})
+	changes, changesWithContext = stream.Flush(1)
+	var sourceEventIds []int64
+	for _, change := range changesWithContext["123->.field1"] {
+		sourceEventIds = append(sourceEventIds, change.SourceEventId)
+	}
+	if len(changes["123->.field1"]) != 1 || changes["123->.field1"][0].SourceEventId != 5 {
+		t.Errorf("Unexpected second flush: %v", changes)
+	}
+	if !reflect.DeepEqual(sourceEventIds, []int64{4, 5}) {
+		t.Errorf("Unexpected context. \nGot: %v\nWant: %v", sourceEventIds, []int64{4, 5})
+	}
+
+	changes, _ = stream.Flush(1)
+	if len(changes) != 0 {
+		t.Errorf("Unexpected changes without new events: %v", changes)
+	}
+}
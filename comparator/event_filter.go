@@ -0,0 +1,17 @@
+package comparator
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"slices"
+)
+
+// IsEventIncluded reports whether changes submitted by the event pass the configured event filter.
+func IsEventIncluded(filter config.EventFilter, eventName, userId string) bool {
+	if len(filter.IncludeEvents) > 0 && !slices.Contains(filter.IncludeEvents, eventName) {
+		return false
+	}
+	if len(filter.IncludeUsers) > 0 && !slices.Contains(filter.IncludeUsers, userId) {
+		return false
+	}
+	return !slices.Contains(filter.ExcludeEvents, eventName) && !slices.Contains(filter.ExcludeUsers, userId)
+}
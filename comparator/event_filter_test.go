@@ -0,0 +1,77 @@
+package comparator
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"ccd-comparator-data-diff-rapid/helper"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIsEventIncluded(t *testing.T) {
+	filter := config.EventFilter{
+		IncludeEvents: []string{"updateRespondent", "updateClaimant"},
+		ExcludeEvents: []string{"updateClaimant"},
+		ExcludeUsers:  []string{"system"},
+	}
+
+	assert.True(t, IsEventIncluded(filter, "updateRespondent", "user1"))
+	assert.False(t, IsEventIncluded(filter, "updateClaimant", "user1"))
+	assert.False(t, IsEventIncluded(filter, "createCase", "user1"))
+	assert.False(t, IsEventIncluded(filter, "updateRespondent", "system"))
+	assert.True(t, IsEventIncluded(config.EventFilter{}, "createCase", "system"))
+}
+
+func TestPrepareReportEntities_AttributesOnlyFilteredEvents(t *testing.T) {
+	createdDate := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+	eventDifferences := EventFieldChanges{
+		"1234->.name": {
+			{OldRecord: "a", NewRecord: "b", CreatedDate: createdDate, SourceEventId: 2,
+				SourceEventName: "updateCase", UserId: "user1", OperationType: Modified},
+			{OldRecord: "b", NewRecord: "c", CreatedDate: createdDate.Add(time.Minute), SourceEventId: 3,
+				SourceEventName: "updateRespondent", UserId: "user2", OperationType: Modified},
+		},
+	}
+	analyzeResult := NewAnalyzeResult()
+	configurations := *appConfigs
+	configurations.Report.IncludeEmptyChange = true
+	configurations.EventFilter = config.EventFilter{IncludeEvents: []string{"updateRespondent"}}
+
+	entities, err := PrepareReportEntities(eventDifferences, analyzeResult, &configurations)
+
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, int64(3), entities[0].EventId)
+	assert.Equal(t, int64(2), entities[0].PreviousEventId)
+	assert.Equal(t, "b", entities[0].OldRecord)
+}
+
+func TestPrepareReportEntities_DescribesTheSourceEvent(t *testing.T) {
+	createdDate := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+	context := EventContext{StateId: "Submitted", StateName: "Submitted", Summary: "Amended the name",
+		Description: "Requested by the applicant", UserFirstName: "Jane", UserLastName: "Doe", ProxiedBy: "proxy1"}
+
+	caseEventStream := NewCaseEventStream(1234)
+	caseEventStream.Add(EventDetails{Id: 1, Name: "createCase", CreatedDate: createdDate, Data: `{"name": "a"}`,
+		StateId: "Open", Summary: "Created"})
+	caseEventStream.Add(EventDetails{Id: 2, Name: "updateCase", CreatedDate: createdDate, Data: `{"name": "b"}`,
+		StateId: context.StateId, StateName: context.StateName, Summary: context.Summary,
+		Description: context.Description, UserFirstName: context.UserFirstName,
+		UserLastName: context.UserLastName, ProxiedBy: context.ProxiedBy})
+	configurations := *appConfigs
+	configurations.Report.IncludeEmptyChange = true
+
+	entities, err := PrepareReportEntities(caseEventStream.Changes(), NewAnalyzeResult(), &configurations)
+
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "Open", caseEventStream.Changes()["1234->.name"][0].PreviousStateId)
+	assert.Equal(t, &context, caseEventStream.Changes()["1234->.name"][0].Context)
+	assert.Equal(t, "Submitted", entities[0].StateId)
+	assert.Equal(t, "Submitted", entities[0].StateName)
+	assert.Equal(t, "Amended the name", entities[0].Summary)
+	assert.Equal(t, "Requested by the applicant", entities[0].Description)
+	assert.Equal(t, "Jane", entities[0].UserFirstName)
+	assert.Equal(t, "Doe", entities[0].UserLastName)
+	assert.Equal(t, "proxy1", entities[0].ProxiedBy)
+}
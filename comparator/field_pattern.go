@@ -0,0 +1,45 @@
+package comparator
+
+import (
+	"strings"
+)
+
+// matchFieldPattern reports whether a field name such as ".applicant.dateOfBirth" matches a dot separated pattern.
+// A "*" segment matches exactly one path segment and a "**" segment matches any number of segments.
+func matchFieldPattern(pattern, fieldName string) bool {
+	patternSegments := strings.Split(strings.TrimPrefix(strings.TrimSpace(pattern), "."), ".")
+	fieldSegments := strings.Split(strings.TrimPrefix(fieldName, "."), ".")
+	return matchSegments(patternSegments, fieldSegments)
+}
+
+func matchSegments(patternSegments, fieldSegments []string) bool {
+	if len(patternSegments) == 0 {
+		return len(fieldSegments) == 0
+	}
+
+	if patternSegments[0] == "**" {
+		for i := 0; i <= len(fieldSegments); i++ {
+			if matchSegments(patternSegments[1:], fieldSegments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(fieldSegments) == 0 {
+		return false
+	}
+	if patternSegments[0] != "*" && patternSegments[0] != fieldSegments[0] {
+		return false
+	}
+	return matchSegments(patternSegments[1:], fieldSegments[1:])
+}
+
+func matchAnyFieldPattern(patterns []string, fieldName string) bool {
+	for _, pattern := range patterns {
+		if matchFieldPattern(pattern, fieldName) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package comparator
+
+import (
+	"ccd-comparator-data-diff-rapid/jsonx"
+	"strconv"
+	"strings"
+)
+
+// FieldValueChange is a change of a watched field between two consecutive events of a case. The values are JSON and
+// empty when the field is missing from the event.
+type FieldValueChange struct {
+	CaseReference   int64
+	Path            string
+	OldValue        string
+	NewValue        string
+	Event           EventDetails
+	PreviousStateId string
+}
+
+// CompareFieldValueChanges diffs the values of the watched fields the same way CompareEventsByCaseReference diffs
+// whole events, so that the changes go through the same rules. The changes of a field must be in event order.
+func CompareFieldValueChanges(changes []FieldValueChange) EventFieldChanges {
+	fieldDifferences := newDifferences()
+	eventContexts := make(map[int64]*EventContext)
+
+	for _, change := range changes {
+		eventContext, found := eventContexts[change.Event.Id]
+		if !found {
+			eventContext = change.Event.sharedContext()
+			eventContexts[change.Event.Id] = eventContext
+		}
+		params := comparisonParams{
+			differences:     fieldDifferences,
+			parentPath:      strconv.FormatInt(change.CaseReference, 10) + "->." + strings.TrimPrefix(change.Path, "."),
+			eventId:         change.Event.Id,
+			createdDate:     change.Event.CreatedDate,
+			eventName:       change.Event.Name,
+			userId:          change.Event.UserId,
+			caseTypeId:      change.Event.CaseTypeId,
+			previousStateId: change.PreviousStateId,
+			eventContext:    eventContext,
+		}
+
+		oldValue, hasOldValue := unmarshalValue(change.OldValue)
+		newValue, hasNewValue := unmarshalValue(change.NewValue)
+		switch {
+		case !hasOldValue && !hasNewValue:
+			continue
+		case !hasOldValue:
+			fieldDifferences.recordDifferenceAtPath(params.parentPath, createDifference("", newValue, Added, params))
+		case !hasNewValue:
+			fieldDifferences.recordDifferenceAtPath(params.parentPath, createDifference(oldValue, "", Deleted, params))
+		default:
+			params.base = oldValue
+			params.compareWith = newValue
+			compareJsonNodes(params)
+		}
+	}
+
+	return fieldDifferences.differencesByPath
+}
+
+// unmarshalValue cleans the value the same way whole events are cleaned before the comparison. Null and empty
+// values count as missing, as the fields holding them are removed from whole events.
+func unmarshalValue(value string) (any, bool) {
+	if value == "" {
+		return nil, false
+	}
+
+	var node any
+	jsonx.MustUnmarshal([]byte(`{"value":`+value+`}`), &node)
+	cleaned, found := node.(map[string]any)["value"]
+	return cleaned, found
+}
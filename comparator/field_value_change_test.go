@@ -0,0 +1,49 @@
+package comparator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCompareFieldValueChanges_MatchesWholeEventComparison(t *testing.T) {
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	events := map[int64]EventDetails{
+		1: {Id: 1, Name: "create", CreatedDate: createdDate, StateId: "open",
+			Data: `{"a": {"b": "x", "c": 1, "id": "1"}, "d": "k"}`},
+		2: {Id: 2, Name: "update", CreatedDate: createdDate, StateId: "open",
+			Data: `{"a": {"b": "y", "c": 1, "id": "2"}, "d": "k"}`},
+		3: {Id: 3, Name: "clear", CreatedDate: createdDate, StateId: "closed",
+			Data: `{"a": {"b": "", "c": 1}, "d": "z"}`},
+	}
+	wholeEventChanges := detectEventModifications(11, events)
+
+	fieldValueChanges := CompareFieldValueChanges([]FieldValueChange{
+		{CaseReference: 11, Path: "a", OldValue: `{"b": "x", "c": 1, "id": "1"}`,
+			NewValue: `{"b": "y", "c": 1, "id": "2"}`, Event: events[2], PreviousStateId: "open"},
+		{CaseReference: 11, Path: "a", OldValue: `{"b": "y", "c": 1, "id": "2"}`, NewValue: `{"b": "", "c": 1}`,
+			Event: events[3], PreviousStateId: "open"},
+		{CaseReference: 11, Path: "d", OldValue: `"k"`, NewValue: `"z"`, Event: events[3], PreviousStateId: "open"},
+	})
+
+	assert.Equal(t, wholeEventChanges, fieldValueChanges)
+	assert.Equal(t, []OperationType{Modified, Deleted},
+		[]OperationType{fieldValueChanges["11->.a.b"][0].OperationType, fieldValueChanges["11->.a.b"][1].OperationType})
+}
+
+func TestCompareFieldValueChanges_MissingValues(t *testing.T) {
+	event := EventDetails{Id: 2, Name: "update", UserId: "user1", CaseTypeId: "CT1"}
+
+	fieldValueChanges := CompareFieldValueChanges([]FieldValueChange{
+		{CaseReference: 11, Path: ".name", NewValue: `"Jo"`, Event: event},
+		{CaseReference: 11, Path: "amount", OldValue: `10`, NewValue: `null`, Event: event},
+		{CaseReference: 11, Path: "notes", OldValue: `[]`, NewValue: `""`, Event: event},
+	})
+
+	assert.Len(t, fieldValueChanges, 2)
+	assert.Equal(t, EventFieldChange{NewRecord: "Jo", SourceEventId: 2, SourceEventName: "update", OperationType: Added,
+		UserId: "user1", CaseTypeId: "CT1"}, fieldValueChanges["11->.name"][0])
+	assert.Equal(t, Deleted, fieldValueChanges["11->.amount"][0].OperationType)
+	assert.Equal(t, "10", fieldValueChanges["11->.amount"][0].OldRecord)
+	assert.NotContains(t, fieldValueChanges, "11->.notes")
+}
@@ -5,30 +5,39 @@ import (
 	"ccd-comparator-data-diff-rapid/config"
 	"ccd-comparator-data-diff-rapid/helper"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type EventDataReportEntity struct {
-	Id                       int64         `db:"id"`
-	EventId                  int64         `db:"event_id"`
-	PreviousEventId          int64         `db:"previous_event_id"`
-	EventName                string        `db:"event_name"`
-	PreviousEventName        string        `db:"previous_event_name"`
-	CaseTypeId               string        `db:"case_type_id"`
-	Reference                string        `db:"reference"`
-	FieldName                string        `db:"field_name"`
-	ChangeType               string        `db:"change_type"`
-	OldRecord                string        `db:"old_record"`
-	NewRecord                string        `db:"new_record"`
-	ArrayChangeRecord        string        `db:"array_change_record"`
-	PreviousEventCreatedDate time.Time     `db:"previous_event_created_date"`
-	EventCreatedDate         time.Time     `db:"event_created_date"`
-	AnalyzeResult            string        `db:"analyze_result"`
-	RuleMatched              bool          `db:"rule_matched"`
-	PreviousEventUserId      string        `db:"previous_event_user_id"`
-	EventUserId              string        `db:"event_user_id"`
-	EventDelta               time.Duration `db:"event_delta"`
+	Id                       int64         `db:"id" json:"id"`
+	EventId                  int64         `db:"event_id" json:"event_id"`
+	PreviousEventId          int64         `db:"previous_event_id" json:"previous_event_id"`
+	EventName                string        `db:"event_name" json:"event_name"`
+	PreviousEventName        string        `db:"previous_event_name" json:"previous_event_name"`
+	CaseTypeId               string        `db:"case_type_id" json:"case_type_id"`
+	Reference                string        `db:"reference" json:"reference"`
+	FieldName                string        `db:"field_name" json:"field_name"`
+	ChangeType               string        `db:"change_type" json:"change_type"`
+	OldRecord                string        `db:"old_record" json:"old_record"`
+	NewRecord                string        `db:"new_record" json:"new_record"`
+	ArrayChangeRecord        string        `db:"array_change_record" json:"array_change_record"`
+	PreviousEventCreatedDate time.Time     `db:"previous_event_created_date" json:"previous_event_created_date"`
+	EventCreatedDate         time.Time     `db:"event_created_date" json:"event_created_date"`
+	AnalyzeResult            string        `db:"analyze_result" json:"analyze_result"`
+	RuleMatched              bool          `db:"rule_matched" json:"rule_matched"`
+	PreviousEventUserId      string        `db:"previous_event_user_id" json:"previous_event_user_id"`
+	EventUserId              string        `db:"event_user_id" json:"event_user_id"`
+	EventDelta               time.Duration `db:"event_delta" json:"event_delta"`
+	StateId                  string        `db:"state_id" json:"state_id"`
+	StateName                string        `db:"state_name" json:"state_name"`
+	Summary                  string        `db:"summary" json:"summary"`
+	Description              string        `db:"description" json:"description"`
+	UserFirstName            string        `db:"user_first_name" json:"user_first_name"`
+	UserLastName             string        `db:"user_last_name" json:"user_last_name"`
+	ProxiedBy                string        `db:"proxied_by" json:"proxied_by"`
 }
 
 func PrepareReportEntities(eventDifferences map[string][]EventFieldChange, analyzeResult *AnalyzeResult,
@@ -62,12 +71,14 @@ func PrepareReportEntities(eventDifferences map[string][]EventFieldChange, analy
 				isArrayChange := false
 
 				if violation.sourceEventId != 0 {
-					previousEventCreatedDate = helper.MustParseTime("", violation.previousEventCreatedDate)
+					if violation.previousEventCreatedDate != "" {
+						previousEventCreatedDate = helper.MustParseTime("", violation.previousEventCreatedDate)
+						delta = time.Duration(eventFieldDiff.CreatedDate.Sub(previousEventCreatedDate).Milliseconds())
+					}
 					previousUserId = violation.previousEventUserId
 					previousEventId = violation.previousEventId
 					previousEventName = violation.previousEventName
 					message = string(violation.ruleType) + ":" + violation.message
-					delta = time.Duration(eventFieldDiff.CreatedDate.Sub(previousEventCreatedDate).Milliseconds())
 					if violation.ruleType == RuleTypeArrayFieldChange {
 						isArrayChange = true
 					}
@@ -82,6 +93,10 @@ func PrepareReportEntities(eventDifferences map[string][]EventFieldChange, analy
 
 				changeIndex = i
 
+				if !IsEventIncluded(configurations.EventFilter, eventFieldDiff.SourceEventName, eventFieldDiff.UserId) {
+					continue
+				}
+
 				if configurations.Report.IncludeEmptyChange || message != "" {
 					var oldRecord, newRecord string
 					if !configurations.Report.MaskValue {
@@ -100,6 +115,9 @@ func PrepareReportEntities(eventDifferences map[string][]EventFieldChange, analy
 					entity.ChangeType = string(eventFieldDiff.OperationType)
 					if isArrayChange {
 						entity.ArrayChangeRecord = stripBytes(newRecord)
+					} else if violation.preserveRawRecord {
+						entity.OldRecord = escapeInvalidUTF8(oldRecord)
+						entity.NewRecord = escapeInvalidUTF8(newRecord)
 					} else {
 						entity.OldRecord = stripBytes(oldRecord)
 						entity.NewRecord = stripBytes(newRecord)
@@ -111,15 +129,90 @@ func PrepareReportEntities(eventDifferences map[string][]EventFieldChange, analy
 					entity.EventUserId = eventFieldDiff.UserId
 					entity.PreviousEventUserId = previousUserId
 					entity.EventDelta = delta
+					entity.setEventContext(eventFieldDiff.Context)
 					eventDataReportEntities = append(eventDataReportEntities, entity)
 				}
 			}
 		}
 	}
 
+	for _, caseViolation := range analyzeResult.GetCaseViolations() {
+		if !IsEventIncluded(configurations.EventFilter, caseViolation.event.Name, caseViolation.event.UserId) {
+			continue
+		}
+		eventDataReportEntities = append(eventDataReportEntities, newCaseViolationReportEntity(caseViolation))
+	}
+
 	return eventDataReportEntities, nil
 }
 
+func newCaseViolationReportEntity(caseViolation CaseViolation) EventDataReportEntity {
+	previousEventCreatedDate := helper.MustParseTime("", caseViolation.previousEventCreatedDate)
+	message := string(caseViolation.ruleType) + ":" + caseViolation.message
+
+	entity := EventDataReportEntity{}
+	entity.EventId = caseViolation.event.Id
+	entity.PreviousEventId = caseViolation.previousEventId
+	entity.PreviousEventName = caseViolation.previousEventName
+	entity.EventName = caseViolation.event.Name
+	entity.CaseTypeId = caseViolation.event.CaseTypeId
+	entity.Reference = strconv.FormatInt(caseViolation.caseReference, 10)
+	entity.FieldName = CaseLevelFieldName
+	entity.ChangeType = string(caseViolation.operationType)
+	entity.PreviousEventCreatedDate = previousEventCreatedDate
+	entity.EventCreatedDate = caseViolation.event.CreatedDate
+	entity.AnalyzeResult = stripBytes(message)
+	entity.RuleMatched = true
+	entity.EventUserId = caseViolation.event.UserId
+	entity.PreviousEventUserId = caseViolation.previousEventUserId
+	entity.EventDelta = time.Duration(caseViolation.event.CreatedDate.Sub(previousEventCreatedDate).Milliseconds())
+	eventContext := caseViolation.event.Context()
+	entity.setEventContext(&eventContext)
+	return entity
+}
+
+// setEventContext describes the event of the row with the state, summary, description and user of the event. The
+// row isn't described when the context is nil.
+func (e *EventDataReportEntity) setEventContext(context *EventContext) {
+	if context == nil {
+		return
+	}
+	e.StateId = context.StateId
+	e.StateName = context.StateName
+	e.Summary = stripBytes(context.Summary)
+	e.Description = stripBytes(context.Description)
+	e.UserFirstName = context.UserFirstName
+	e.UserLastName = context.UserLastName
+	e.ProxiedBy = context.ProxiedBy
+}
+
+const skippedCaseResult = "skippedcase"
+
+// NewSkippedCaseReportEntity records a case that wasn't compared, with the reason it was skipped.
+func NewSkippedCaseReportEntity(caseReference int64, caseTypeId, reason string) EventDataReportEntity {
+	return EventDataReportEntity{
+		CaseTypeId:    caseTypeId,
+		Reference:     strconv.FormatInt(caseReference, 10),
+		FieldName:     CaseLevelFieldName,
+		ChangeType:    string(NoChange),
+		AnalyzeResult: stripBytes(skippedCaseResult + ":Case was not compared, " + reason),
+		RuleMatched:   true,
+	}
+}
+
+const skippedCaseRulesResult = "skippedcaserules"
+
+// NewSkippedCaseRulesReportEntity records a case compared without the case rules.
+func NewSkippedCaseRulesReportEntity(caseReference int64, caseTypeId, reason string) EventDataReportEntity {
+	return EventDataReportEntity{
+		CaseTypeId:    caseTypeId,
+		Reference:     strconv.FormatInt(caseReference, 10),
+		FieldName:     CaseLevelFieldName,
+		ChangeType:    string(NoChange),
+		AnalyzeResult: stripBytes(skippedCaseRulesResult + ":Case rules were not applied, " + reason),
+	}
+}
+
 func stripBytes(value string) string {
 	data := []byte(value)
 	data = bytes.Replace(data, []byte{0xe2, 0x27, 0x20}, []byte{}, -1)
@@ -128,3 +221,20 @@ func stripBytes(value string) string {
 
 	return string(data)
 }
+
+// escapeInvalidUTF8 makes the value storable as text without dropping any of it: valid UTF-8 is kept as is and
+// invalid bytes and NUL characters are written as \xNN. Event data is decoded as JSON before it is compared, which
+// replaces invalid bytes with U+FFFD, so only its \u0000 escapes reach here as NUL characters.
+func escapeInvalidUTF8(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRuneInString(value[i:])
+		if (r == utf8.RuneError && size == 1) || r == 0 {
+			builder.WriteString(fmt.Sprintf("\\x%02x", value[i]))
+		} else {
+			builder.WriteString(value[i : i+size])
+		}
+		i += size
+	}
+	return builder.String()
+}
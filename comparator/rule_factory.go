@@ -2,14 +2,15 @@ package comparator
 
 import (
 	"ccd-comparator-data-diff-rapid/config"
-	"ccd-comparator-data-diff-rapid/helper"
 	"github.com/pkg/errors"
 	"strings"
+	"time"
 )
 
 type RuleFactory struct {
-	configuration   *config.Configurations
-	enabledRuleList []Rule
+	configuration       *config.Configurations
+	enabledRuleList     []Rule
+	enabledCaseRuleList []CaseRule
 }
 
 func NewRuleFactory(configuration *config.Configurations) *RuleFactory {
@@ -19,6 +20,7 @@ func NewRuleFactory(configuration *config.Configurations) *RuleFactory {
 		panic(err)
 	}
 	factory.enabledRuleList = rules
+	factory.enabledCaseRuleList = factory.createEnabledCaseRuleList(configuration.Active)
 	return factory
 }
 
@@ -30,7 +32,10 @@ func (f RuleFactory) createEnabledRuleList(activeAnalyzeRules string) ([]Rule, e
 	enabledRuleTypes := parseActiveAnalyzeRules(activeAnalyzeRules)
 
 	var ruleConfig = f.configuration.Scan
-	var searchStartTime = helper.MustParseTime("", f.configuration.Period.StartTime)
+	searchStartTime, _, err := f.configuration.Period.Resolve(time.Now())
+	if err != nil {
+		return nil, err
+	}
 
 	rules := make([]Rule, 0)
 
@@ -45,10 +50,48 @@ func (f RuleFactory) createEnabledRuleList(activeAnalyzeRules string) ([]Rule, e
 	if enabledRuleTypes[RuleTypeFieldChangeCount] {
 		rules = append(rules, NewFieldChangeCountRule(ruleConfig.FieldChange.Threshold))
 	}
+	if enabledRuleTypes[RuleTypeTextIntegrity] {
+		rules = append(rules, NewTextIntegrityRule(ruleConfig.TextIntegrity.MinTruncationLength,
+			ruleConfig.Report.MaskValue))
+	}
+	if enabledRuleTypes[RuleTypeDateRegression] {
+		for _, dateField := range f.configuration.DateFields {
+			rules = append(rules, NewDateRegressionRule(dateField.Fields, dateField.FrozenStates,
+				ruleConfig.Report.MaskValue))
+		}
+	}
+	if enabledRuleTypes[RuleTypeNumericDrift] {
+		for _, numericField := range f.configuration.NumericFields {
+			rules = append(rules, NewNumericDriftRule(numericField.Fields, numericField.MaxRelativeDelta,
+				numericField.MaxAbsoluteDelta, ruleConfig.Report.MaskValue))
+		}
+	}
 
 	return rules, nil
 }
 
+func (f RuleFactory) createEnabledCaseRuleList(activeAnalyzeRules string) []CaseRule {
+	enabledRuleTypes := parseActiveAnalyzeRules(activeAnalyzeRules)
+
+	var ruleConfig = f.configuration.Scan
+
+	rules := make([]CaseRule, 0)
+
+	if enabledRuleTypes[RuleTypeDuplicateEvent] {
+		rules = append(rules, NewDuplicateEventRule(ruleConfig.DuplicateEvent.BurstThresholdMilliseconds,
+			ruleConfig.DuplicateEvent.BurstMinCount))
+	}
+	if enabledRuleTypes[RuleTypeEventBurst] {
+		rules = append(rules, NewEventBurstRule(ruleConfig.EventBurst.WindowMilliseconds,
+			ruleConfig.EventBurst.MinEventCount, ruleConfig.EventBurst.MinUserCount))
+	}
+	if enabledRuleTypes[RuleTypeDocumentLoss] {
+		rules = append(rules, NewDocumentLossRule(ruleConfig.Report.MaskValue))
+	}
+
+	return rules
+}
+
 func parseActiveAnalyzeRules(activeAnalyzeRules string) map[RuleType]bool {
 	ruleMap := make(map[RuleType]bool)
 
@@ -70,6 +113,18 @@ func ruleTypeFromString(name string) (RuleType, bool) {
 		return RuleTypeArrayFieldChange, true
 	case "fieldchangecount":
 		return RuleTypeFieldChangeCount, true
+	case "textintegrity":
+		return RuleTypeTextIntegrity, true
+	case "dateregression":
+		return RuleTypeDateRegression, true
+	case "numericdrift":
+		return RuleTypeNumericDrift, true
+	case "duplicateevent":
+		return RuleTypeDuplicateEvent, true
+	case "eventburst":
+		return RuleTypeEventBurst, true
+	case "documentloss":
+		return RuleTypeDocumentLoss, true
 	default:
 		return RuleTypeUnknown, false
 	}
@@ -79,6 +134,10 @@ func (f RuleFactory) GetEnabledRuleList() []Rule {
 	return f.enabledRuleList
 }
 
+func (f RuleFactory) GetEnabledCaseRuleList() []CaseRule {
+	return f.enabledCaseRuleList
+}
+
 type RuleType string
 
 const (
@@ -86,4 +145,10 @@ const (
 	RuleTypeStaticFieldChange          = "staticfieldchange"
 	RuleTypeFieldChangeCount           = "fieldchangecount"
 	RuleTypeArrayFieldChange           = "arrayfieldchange"
+	RuleTypeTextIntegrity              = "textintegrity"
+	RuleTypeDateRegression             = "dateregression"
+	RuleTypeNumericDrift               = "numericdrift"
+	RuleTypeDuplicateEvent             = "duplicateevent"
+	RuleTypeEventBurst                 = "eventburst"
+	RuleTypeDocumentLoss               = "documentloss"
 )
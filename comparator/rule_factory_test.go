@@ -75,3 +75,26 @@ func TestRuleFactory_CreateEnabledRuleList_NoActiveRuleDefined(t *testing.T) {
 		t.Errorf("Expected error message: %s, but got: %s", expectedErrorMsg, err.Error())
 	}
 }
+
+func TestRuleFactory_CreatesValueRulesPerFieldBinding(t *testing.T) {
+	appConfigs.Active = "dateregression,numericdrift"
+	factory := NewRuleFactory(appConfigs)
+	enabledRuleList := factory.GetEnabledRuleList()
+
+	if len(enabledRuleList) != 2 {
+		t.Fatalf("Expected 2 enabled rules, but got %d", len(enabledRuleList))
+	}
+
+	dateRule, isDateRegressionRule := enabledRuleList[0].(*DateRegressionRule)
+	if !isDateRegressionRule {
+		t.Fatal("Expected the first rule to be DateRegressionRule")
+	}
+	if len(dateRule.fieldPatterns) != 2 || len(dateRule.frozenStates) != 1 {
+		t.Errorf("Unexpected date rule binding: %v %v", dateRule.fieldPatterns, dateRule.frozenStates)
+	}
+
+	numericRule, isNumericDriftRule := enabledRuleList[1].(*NumericDriftRule)
+	if !isNumericDriftRule || numericRule.maxRelativeDelta != 50 {
+		t.Error("Expected the second rule to be NumericDriftRule with a relative delta of 50")
+	}
+}
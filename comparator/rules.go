@@ -19,6 +19,15 @@ type Violation struct {
 	ruleType                 RuleType
 	message                  string
 	previousEventName        string
+	// preserveRawRecord keeps the decoded old and new records in the report instead of stripping bytes from them.
+	preserveRawRecord bool
+	// mergedRuleTypes holds the rules of the violations merged into this one, which ruleType only keeps the last of.
+	mergedRuleTypes []RuleType
+}
+
+// ruleTypes returns the rules of the violation and of the violations merged into it.
+func (v Violation) ruleTypes() []RuleType {
+	return append(append([]RuleType(nil), v.mergedRuleTypes...), v.ruleType)
 }
 
 type StaticFieldChangeRule struct {
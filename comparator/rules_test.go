@@ -50,8 +50,8 @@ func TestSameValueAfterChangeRule_CreateViolationInThreshold(t *testing.T) {
 	}
 
 	expectedSourceEventId := 3
-	expectedMessage := helper.FormatTimeStamp(createdDateBaseSecond) + "->field field1 changed in event id 2 with the value value2 on " +
-		helper.FormatTimeStamp(createdDateBaseSecond) + ". The field field1 was updated back to old value value1 " +
+	expectedMessage := "Field 'field1' changed to 'value2' in event id 2 on " +
+		helper.FormatTimeStamp(createdDateBaseSecond) + ", but reverted back to the previous value 'value1' " +
 		"in event id 3 on " + helper.FormatTimeStamp(createdDateBaseThird)
 
 	if result[0].sourceEventId != 3 {
@@ -103,7 +103,7 @@ func TestSameValueAfterChangeRule_IgnoreViolationExceedThreshold(t *testing.T) {
 	}
 }
 
-func TestSameValueAfterChangeRule_ViolationWith0Threshold(t *testing.T) {
+func TestSameValueAfterChangeRule_ViolationWithDisabledThreshold(t *testing.T) {
 	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
 	createdDateBaseSecond := createdDateBase.Add(10 * time.Second)
 	createdDateBaseThird := createdDateBase.Add(120 * time.Second)
@@ -135,7 +135,8 @@ func TestSameValueAfterChangeRule_ViolationWith0Threshold(t *testing.T) {
 		},
 	}
 
-	rule := NewStaticFieldChangeRule(0, false)
+	// Only -1 disables the time limit, a threshold of 0 only flags the reverts made in the same millisecond
+	rule := NewStaticFieldChangeRule(-1, false)
 	fieldName := "field1"
 	result := rule.CheckForViolation(fieldName, fieldDifferences)
 
@@ -144,15 +145,17 @@ func TestSameValueAfterChangeRule_ViolationWith0Threshold(t *testing.T) {
 	}
 
 	expectedSourceEventId := 3
-	expectedMessage := helper.FormatTimeStamp(createdDateBaseSecond) + "->field field1 changed in event id 2 with the value value2 on " +
-		helper.FormatTimeStamp(createdDateBaseSecond) + ". The field field1 was updated back to old value value1 " +
+	expectedMessage := "Field 'field1' changed to 'value2' in event id 2 on " +
+		helper.FormatTimeStamp(createdDateBaseSecond) + ", but reverted back to the previous value 'value1' " +
 		"in event id 3 on " + helper.FormatTimeStamp(createdDateBaseThird)
 
-	if result[0].sourceEventId != 3 {
-		t.Errorf("Incorrect SourceEventId. Expected: %d, Got: %d", expectedSourceEventId, result[0].sourceEventId)
+	// Event 3 also reverts the no-op change of event 1, which is the first violation
+	lastViolation := result[len(result)-1]
+	if lastViolation.sourceEventId != 3 {
+		t.Errorf("Incorrect SourceEventId. Expected: %d, Got: %d", expectedSourceEventId, lastViolation.sourceEventId)
 	}
-	if result[0].message != expectedMessage {
-		t.Errorf("Incorrect violation message. Expected: %s, Got: %s", expectedMessage, result[0].message)
+	if lastViolation.message != expectedMessage {
+		t.Errorf("Incorrect violation message. Expected: %s, Got: %s", expectedMessage, lastViolation.message)
 	}
 }
 
@@ -197,8 +200,8 @@ func TestSameValueAfterChangeRule_CreateViolationInThresholdAndMask(t *testing.T
 	}
 
 	expectedSourceEventId := 3
-	expectedMessage := helper.FormatTimeStamp(createdDateBaseSecond) + "->field field1 changed in event id 2 with the value *** on " +
-		helper.FormatTimeStamp(createdDateBaseSecond) + ". The field field1 was updated back to old value *** " +
+	expectedMessage := "Field 'field1' changed to '***' in event id 2 on " +
+		helper.FormatTimeStamp(createdDateBaseSecond) + ", but reverted back to the previous value '***' " +
 		"in event id 3 on " + helper.FormatTimeStamp(createdDateBaseThird)
 
 	if result[0].sourceEventId != 3 {
@@ -251,8 +254,8 @@ func TestSameValueAfterChangeRule_CreateViolationInThresholdOldValueEmpty(t *tes
 	}
 
 	expectedSourceEventId := 3
-	expectedMessage := helper.FormatTimeStamp(createdDateBaseSecond) + "->field field1 changed in event id 2 with the value value2 on " +
-		helper.FormatTimeStamp(createdDateBaseSecond) + ". The field field1 was updated back to old value value1value1value1value1v " +
+	expectedMessage := "Field 'field1' changed to 'value2' in event id 2 on " +
+		helper.FormatTimeStamp(createdDateBaseSecond) + ", but reverted back to the previous value 'value1value1value1value1value1' " +
 		"in event id 3 on " + helper.FormatTimeStamp(createdDateBaseThird)
 
 	if result[0].sourceEventId != int64(expectedSourceEventId) {
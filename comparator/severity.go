@@ -0,0 +1,73 @@
+package comparator
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+var severityRanks = map[Severity]int{
+	SeverityLow:    1,
+	SeverityMedium: 2,
+	SeverityHigh:   3,
+}
+
+// defaultSeverities rates the rules by how likely their violations are data loss rather than expected updates.
+var defaultSeverities = map[RuleType]Severity{
+	RuleTypeStaticFieldChange: SeverityHigh,
+	RuleTypeTextIntegrity:     SeverityHigh,
+	RuleTypeDocumentLoss:      SeverityHigh,
+	RuleTypeArrayFieldChange:  SeverityMedium,
+	RuleTypeDateRegression:    SeverityMedium,
+	RuleTypeNumericDrift:      SeverityMedium,
+	RuleTypeDuplicateEvent:    SeverityMedium,
+	RuleTypeFieldChangeCount:  SeverityLow,
+	RuleTypeEventBurst:        SeverityLow,
+}
+
+// ParseSeverity parses low, medium or high.
+func ParseSeverity(value string) (Severity, error) {
+	severity := Severity(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := severityRanks[severity]; !ok {
+		return "", errors.Errorf("unknown severity '%s', expected low, medium or high", value)
+	}
+	return severity, nil
+}
+
+// AtLeast reports whether the severity is as high as the given one.
+func (s Severity) AtLeast(severity Severity) bool {
+	return severityRanks[s] >= severityRanks[severity]
+}
+
+// RuleSeverity returns the severity the rule is overridden with, keyed by rule name, or its default one.
+func RuleSeverity(ruleType RuleType, overrides map[string]string) Severity {
+	if override, ok := overrides[string(ruleType)]; ok {
+		if severity, err := ParseSeverity(override); err == nil {
+			return severity
+		}
+	}
+	if severity, ok := defaultSeverities[ruleType]; ok {
+		return severity
+	}
+	return SeverityLow
+}
+
+// ValidateSeverities checks that the overrides name known rules and severities.
+func ValidateSeverities(overrides map[string]string) error {
+	for name, value := range overrides {
+		if _, ok := ruleTypeFromString(name); !ok {
+			return errors.Errorf("unknown rule '%s'", name)
+		}
+		if _, err := ParseSeverity(value); err != nil {
+			return errors.Wrapf(err, "rule '%s'", name)
+		}
+	}
+	return nil
+}
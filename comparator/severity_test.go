@@ -0,0 +1,28 @@
+package comparator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRuleSeverity_DefaultsAndOverrides(t *testing.T) {
+	overrides := map[string]string{"fieldchangecount": "High", "eventburst": "unknown"}
+
+	assert.Equal(t, SeverityHigh, RuleSeverity(RuleTypeDocumentLoss, overrides))
+	assert.Equal(t, SeverityMedium, RuleSeverity(RuleTypeNumericDrift, nil))
+	assert.Equal(t, SeverityHigh, RuleSeverity(RuleTypeFieldChangeCount, overrides))
+	assert.Equal(t, SeverityLow, RuleSeverity(RuleTypeEventBurst, overrides))
+}
+
+func TestSeverity_AtLeast(t *testing.T) {
+	assert.True(t, SeverityHigh.AtLeast(SeverityMedium))
+	assert.True(t, SeverityMedium.AtLeast(SeverityMedium))
+	assert.False(t, SeverityLow.AtLeast(SeverityMedium))
+}
+
+func TestValidateSeverities(t *testing.T) {
+	assert.NoError(t, ValidateSeverities(map[string]string{"numericdrift": "low"}))
+	assert.EqualError(t, ValidateSeverities(map[string]string{"unknownrule": "low"}), "unknown rule 'unknownrule'")
+	assert.EqualError(t, ValidateSeverities(map[string]string{"numericdrift": "urgent"}),
+		"rule 'numericdrift': unknown severity 'urgent', expected low, medium or high")
+}
@@ -0,0 +1,84 @@
+package comparator
+
+import (
+	"ccd-comparator-data-diff-rapid/helper"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+type TextIntegrityRule struct {
+	minTruncationLength int
+	isScanReportMask    bool
+	ruleType            RuleType
+}
+
+func NewTextIntegrityRule(minTruncationLength int, isScanReportMask bool) *TextIntegrityRule {
+	return &TextIntegrityRule{
+		minTruncationLength: minTruncationLength,
+		isScanReportMask:    isScanReportMask,
+		ruleType:            RuleTypeTextIntegrity,
+	}
+}
+
+// CheckForViolation flags text that was truncated, corrupted by a broken encoding or escaped by a callback.
+// The report keeps the decoded records of every flagged change unstripped. Decoding the event data replaces invalid
+// UTF-8 with U+FFFD, so a broken encoding is found by its replacement characters.
+func (t TextIntegrityRule) CheckForViolation(fieldName string, fieldChanges []EventFieldChange) []Violation {
+	var violations []Violation
+
+	for currentIndex, currentChange := range fieldChanges {
+		if currentChange.OperationType != Modified {
+			continue
+		}
+
+		finding := t.detectCorruption(currentChange.OldRecord, currentChange.NewRecord)
+		if finding == "" {
+			continue
+		}
+
+		message := fmt.Sprintf("Field '%s' %s in event id %d on %s: '%s' became '%s'", fieldName, finding,
+			currentChange.SourceEventId, helper.FormatTimeStamp(currentChange.CreatedDate),
+			escapeInvalidUTF8(processInputValue(currentChange.OldRecord, t.isScanReportMask)),
+			escapeInvalidUTF8(processInputValue(currentChange.NewRecord, t.isScanReportMask)))
+
+		violation := newValueViolation(fieldChanges, currentIndex, t.ruleType, message)
+		violation.preserveRawRecord = true
+		violations = append(violations, violation)
+	}
+
+	return violations
+}
+
+func (t TextIntegrityRule) detectCorruption(oldRecord, newRecord string) string {
+	if oldRecord == newRecord {
+		return ""
+	}
+
+	if !hasBrokenEncoding(oldRecord) && hasBrokenEncoding(newRecord) {
+		return "contains invalid UTF-8 or replacement characters"
+	}
+
+	if len(newRecord) > 0 && len(oldRecord) >= t.minTruncationLength && strings.HasPrefix(oldRecord, newRecord) {
+		return fmt.Sprintf("was truncated from %d to %d bytes", len(oldRecord), len(newRecord))
+	}
+
+	if html.UnescapeString(newRecord) == oldRecord {
+		return "was HTML escaped"
+	}
+
+	if unescaped, err := url.QueryUnescape(newRecord); err == nil && unescaped == oldRecord {
+		return "was percent encoded"
+	}
+	if unescaped, err := url.PathUnescape(newRecord); err == nil && unescaped == oldRecord {
+		return "was percent encoded"
+	}
+
+	return ""
+}
+
+func hasBrokenEncoding(value string) bool {
+	return !utf8.ValidString(value) || strings.ContainsRune(value, utf8.RuneError)
+}
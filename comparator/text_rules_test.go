@@ -0,0 +1,97 @@
+package comparator
+
+import (
+	"ccd-comparator-data-diff-rapid/helper"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTextIntegrityRule_CheckForViolation(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+	longText := "The respondent attended the hearing"
+
+	tests := []struct {
+		name      string
+		oldRecord string
+		newRecord string
+		expected  string
+	}{
+		{"Truncated", longText, longText[:12], "was truncated from 35 to 12 bytes"},
+		{"ShortValueNotTruncated", "abc", "ab", ""},
+		{"Cleared", longText, "", ""},
+		{"ReplacementCharacter", "Café", "Caf�", "contains invalid UTF-8 or replacement characters"},
+		{"InvalidUTF8", "Café", "Caf\xc3", "contains invalid UTF-8 or replacement characters"},
+		{"HtmlEscaped", "Smith & Sons <Ltd>", "Smith &amp; Sons &lt;Ltd&gt;", "was HTML escaped"},
+		{"PercentEncoded", "Flat 1/2 Main Street", "Flat%201%2F2%20Main%20Street", "was percent encoded"},
+		{"OrdinaryEdit", longText, "The applicant attended the hearing", ""},
+	}
+
+	rule := NewTextIntegrityRule(20, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fieldChanges := []EventFieldChange{
+				{OldRecord: tt.oldRecord, NewRecord: tt.newRecord, CreatedDate: createdDateBase.Add(time.Hour),
+					SourceEventId: 2, OperationType: Modified},
+			}
+			result := rule.CheckForViolation(".notes", fieldChanges)
+			if tt.expected == "" {
+				assert.Nil(t, result)
+				return
+			}
+			assert.Len(t, result, 1)
+			assert.Contains(t, result[0].message, "Field '.notes' "+tt.expected+" in event id 2")
+			assert.True(t, result[0].preserveRawRecord)
+		})
+	}
+}
+
+func TestPrepareReportEntities_PreservesRawRecordForTextIntegrity(t *testing.T) {
+	createdDate := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+	eventDifferences := EventFieldChanges{
+		"1234->.notes": {
+			{OldRecord: "Caf\xc3\xa9's", NewRecord: "Caf\xc3 \xc2's", CreatedDate: createdDate, SourceEventId: 2,
+				OperationType: Modified},
+		},
+	}
+	activeRules := []Rule{NewTextIntegrityRule(20, false)}
+	analyzeResult := NewEventChangesAnalyze(&activeRules, eventDifferences).AnalyzeEventFieldChanges()
+
+	entities, err := PrepareReportEntities(eventDifferences, analyzeResult, appConfigs)
+
+	assert.NoError(t, err)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "Café's", entities[0].OldRecord)
+	assert.Equal(t, "Caf\\xc3 \\xc2's", entities[0].NewRecord)
+	assert.True(t, entities[0].RuleMatched)
+}
+
+func TestCompareEventsByCaseReference_ReportsInvalidUTF8AsReplacementCharacters(t *testing.T) {
+	createdDate := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+	caseEvents := CasesWithEventDetails{
+		1234: {
+			1: {Id: 1, Name: "createCase", CreatedDate: createdDate, Data: `{"notes": "Café", "code": "a"}`},
+			2: {Id: 2, Name: "updateCase", CreatedDate: createdDate, Data: "{\"notes\": \"Caf\xc3\", " +
+				`"code": "a\u0000b"}`},
+		},
+	}
+	eventDifferences := CompareEventsByCaseReference("tid", caseEvents)
+	activeRules := []Rule{NewTextIntegrityRule(20, false)}
+	analyzeResult := NewEventChangesAnalyze(&activeRules, eventDifferences).AnalyzeEventFieldChanges()
+
+	entities, err := PrepareReportEntities(eventDifferences, analyzeResult, appConfigs)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Caf�", eventDifferences["1234->.notes"][0].NewRecord)
+	var notes []EventDataReportEntity
+	for _, entity := range entities {
+		if entity.FieldName == ".notes" {
+			notes = append(notes, entity)
+		}
+	}
+	assert.Len(t, notes, 1)
+	assert.Equal(t, "Café", notes[0].OldRecord)
+	assert.Equal(t, "Caf�", notes[0].NewRecord)
+	assert.Contains(t, notes[0].AnalyzeResult, "contains invalid UTF-8 or replacement characters")
+	assert.Equal(t, "a\\x00b", escapeInvalidUTF8(eventDifferences["1234->.code"][0].NewRecord))
+}
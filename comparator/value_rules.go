@@ -0,0 +1,182 @@
+package comparator
+
+import (
+	"ccd-comparator-data-diff-rapid/helper"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var dateValueLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+type DateRegressionRule struct {
+	fieldPatterns    []string
+	frozenStates     []string
+	isScanReportMask bool
+	ruleType         RuleType
+}
+
+type NumericDriftRule struct {
+	fieldPatterns    []string
+	maxRelativeDelta float64
+	maxAbsoluteDelta float64
+	isScanReportMask bool
+	ruleType         RuleType
+}
+
+func NewDateRegressionRule(fieldPatterns, frozenStates []string, isScanReportMask bool) *DateRegressionRule {
+	return &DateRegressionRule{
+		fieldPatterns:    fieldPatterns,
+		frozenStates:     frozenStates,
+		isScanReportMask: isScanReportMask,
+		ruleType:         RuleTypeDateRegression,
+	}
+}
+
+func NewNumericDriftRule(fieldPatterns []string, maxRelativeDelta, maxAbsoluteDelta float64,
+	isScanReportMask bool) *NumericDriftRule {
+	return &NumericDriftRule{
+		fieldPatterns:    fieldPatterns,
+		maxRelativeDelta: maxRelativeDelta,
+		maxAbsoluteDelta: maxAbsoluteDelta,
+		isScanReportMask: isScanReportMask,
+		ruleType:         RuleTypeNumericDrift,
+	}
+}
+
+func (d DateRegressionRule) CheckForViolation(fieldName string, fieldChanges []EventFieldChange) []Violation {
+	if !matchAnyFieldPattern(d.fieldPatterns, fieldName) {
+		return nil
+	}
+
+	var violations []Violation
+
+	for currentIndex, currentChange := range fieldChanges {
+		if currentChange.OperationType == NoChange || currentChange.OperationType.IsArrayOperation() {
+			continue
+		}
+
+		var message string
+		if d.isFrozenState(currentChange.PreviousStateId) {
+			message = fmt.Sprintf("Field '%s' changed from '%s' to '%s' in event id %d on %s "+
+				"while the case was in state '%s'", fieldName,
+				processInputValue(currentChange.OldRecord, d.isScanReportMask),
+				processInputValue(currentChange.NewRecord, d.isScanReportMask), currentChange.SourceEventId,
+				helper.FormatTimeStamp(currentChange.CreatedDate), currentChange.PreviousStateId)
+		} else if currentChange.OperationType == Modified && isDateDecrease(currentChange.OldRecord,
+			currentChange.NewRecord) {
+			message = fmt.Sprintf("Date field '%s' moved backwards from '%s' to '%s' in event id %d on %s",
+				fieldName, processInputValue(currentChange.OldRecord, d.isScanReportMask),
+				processInputValue(currentChange.NewRecord, d.isScanReportMask), currentChange.SourceEventId,
+				helper.FormatTimeStamp(currentChange.CreatedDate))
+		} else {
+			continue
+		}
+
+		violations = append(violations, newValueViolation(fieldChanges, currentIndex, d.ruleType, message))
+	}
+
+	return violations
+}
+
+func (d DateRegressionRule) isFrozenState(stateId string) bool {
+	if stateId == "" {
+		return false
+	}
+	for _, frozenState := range d.frozenStates {
+		if strings.EqualFold(strings.TrimSpace(frozenState), stateId) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n NumericDriftRule) CheckForViolation(fieldName string, fieldChanges []EventFieldChange) []Violation {
+	if !matchAnyFieldPattern(n.fieldPatterns, fieldName) {
+		return nil
+	}
+
+	var violations []Violation
+
+	for currentIndex, currentChange := range fieldChanges {
+		if currentChange.OperationType != Modified {
+			continue
+		}
+
+		oldValue, oldErr := strconv.ParseFloat(strings.TrimSpace(currentChange.OldRecord), 64)
+		newValue, newErr := strconv.ParseFloat(strings.TrimSpace(currentChange.NewRecord), 64)
+		if oldErr != nil || newErr != nil {
+			continue
+		}
+
+		absoluteDelta := math.Abs(newValue - oldValue)
+		var exceeded string
+		if n.maxAbsoluteDelta > 0 && absoluteDelta > n.maxAbsoluteDelta {
+			exceeded = fmt.Sprintf("absolute delta %g exceeds %g", absoluteDelta, n.maxAbsoluteDelta)
+		} else if n.maxRelativeDelta > 0 && oldValue != 0 {
+			relativeDelta := absoluteDelta / math.Abs(oldValue) * 100
+			if relativeDelta > n.maxRelativeDelta {
+				exceeded = fmt.Sprintf("relative delta %.2f%% exceeds %g%%", relativeDelta, n.maxRelativeDelta)
+			}
+		}
+		if exceeded == "" {
+			continue
+		}
+
+		message := fmt.Sprintf("Numeric field '%s' changed from '%s' to '%s' in event id %d on %s: %s",
+			fieldName, processInputValue(currentChange.OldRecord, n.isScanReportMask),
+			processInputValue(currentChange.NewRecord, n.isScanReportMask), currentChange.SourceEventId,
+			helper.FormatTimeStamp(currentChange.CreatedDate), exceeded)
+		violations = append(violations, newValueViolation(fieldChanges, currentIndex, n.ruleType, message))
+	}
+
+	return violations
+}
+
+// newValueViolation creates a violation for the change at currentIndex, linked to the change that introduced the
+// old value if there is one.
+func newValueViolation(fieldChanges []EventFieldChange, currentIndex int, ruleType RuleType,
+	message string) Violation {
+	violation := Violation{
+		sourceEventId: fieldChanges[currentIndex].SourceEventId,
+		ruleType:      ruleType,
+		message:       message,
+	}
+
+	if currentIndex > 0 {
+		previousChange := fieldChanges[currentIndex-1]
+		violation.previousEventId = previousChange.SourceEventId
+		violation.previousEventCreatedDate = helper.FormatTimeStamp(previousChange.CreatedDate)
+		violation.previousEventUserId = previousChange.UserId
+		violation.previousEventName = previousChange.SourceEventName
+	}
+
+	return violation
+}
+
+func isDateDecrease(oldRecord, newRecord string) bool {
+	oldDate, ok := parseDateValue(oldRecord)
+	if !ok {
+		return false
+	}
+	newDate, ok := parseDateValue(newRecord)
+	if !ok {
+		return false
+	}
+	return newDate.Before(oldDate)
+}
+
+func parseDateValue(value string) (time.Time, bool) {
+	for _, layout := range dateValueLayouts {
+		if parsed, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
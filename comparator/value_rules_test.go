@@ -0,0 +1,90 @@
+package comparator
+
+import (
+	"ccd-comparator-data-diff-rapid/helper"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMatchFieldPattern(t *testing.T) {
+	assert.True(t, matchFieldPattern("hearingDate", ".hearingDate"))
+	assert.True(t, matchFieldPattern(".hearingDate", ".hearingDate"))
+	assert.True(t, matchFieldPattern("*.dateIssued", ".claim.dateIssued"))
+	assert.False(t, matchFieldPattern("*.dateIssued", ".dateIssued"))
+	assert.True(t, matchFieldPattern("**.dateIssued", ".dateIssued"))
+	assert.True(t, matchFieldPattern("**.dateIssued", ".claims.value.dateIssued"))
+	assert.True(t, matchFieldPattern("claims.**", ".claims.value.dateIssued"))
+	assert.False(t, matchFieldPattern("hearingDate", ".hearingDateTime"))
+}
+
+func TestDateRegressionRule_FlagsDecrease(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+
+	fieldChanges := []EventFieldChange{
+		{OldRecord: "2023-03-01", NewRecord: "2023-04-01", CreatedDate: createdDateBase, SourceEventId: 1,
+			SourceEventName: "listHearing", OperationType: Modified, UserId: "user1"},
+		{OldRecord: "2023-04-01", NewRecord: "2023-02-01T10:00:00.000", CreatedDate: createdDateBase.Add(time.Hour),
+			SourceEventId: 2, SourceEventName: "relistHearing", OperationType: Modified, UserId: "user2"},
+		{OldRecord: "2023-02-01T10:00:00.000", NewRecord: "not a date", CreatedDate: createdDateBase.Add(2 * time.Hour),
+			SourceEventId: 3, SourceEventName: "relistHearing", OperationType: Modified},
+	}
+
+	rule := NewDateRegressionRule([]string{"hearingDate"}, nil, false)
+	result := rule.CheckForViolation(".hearingDate", fieldChanges)
+
+	assert.Len(t, result, 1)
+	assert.Equal(t, int64(2), result[0].sourceEventId)
+	assert.Equal(t, int64(1), result[0].previousEventId)
+	assert.Equal(t, "user1", result[0].previousEventUserId)
+	assert.Equal(t, "Date field '.hearingDate' moved backwards from '2023-04-01' to '2023-02-01T10:00:00.000' "+
+		"in event id 2 on "+helper.FormatTimeStamp(createdDateBase.Add(time.Hour)), result[0].message)
+
+	assert.Nil(t, rule.CheckForViolation(".otherDate", fieldChanges))
+}
+
+func TestDateRegressionRule_FlagsChangeInFrozenState(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+
+	fieldChanges := []EventFieldChange{
+		{OldRecord: "", NewRecord: "2023-03-01", CreatedDate: createdDateBase, SourceEventId: 1,
+			OperationType: Added, PreviousStateId: "Open"},
+		{OldRecord: "2023-03-01", NewRecord: "2023-05-01", CreatedDate: createdDateBase.Add(time.Hour),
+			SourceEventId: 2, OperationType: Modified, PreviousStateId: "Closed"},
+	}
+
+	result := NewDateRegressionRule([]string{"**.dateIssued"}, []string{"closed"}, true).
+		CheckForViolation(".claim.dateIssued", fieldChanges)
+
+	assert.Len(t, result, 1)
+	assert.Equal(t, int64(2), result[0].sourceEventId)
+	assert.Equal(t, "Field '.claim.dateIssued' changed from '***' to '***' in event id 2 on "+
+		helper.FormatTimeStamp(createdDateBase.Add(time.Hour))+" while the case was in state 'Closed'",
+		result[0].message)
+}
+
+func TestNumericDriftRule_CheckForViolation(t *testing.T) {
+	createdDateBase := helper.MustParseTime(ruleSetLayout, "2023-01-01T00:00:00.000")
+
+	fieldChanges := []EventFieldChange{
+		{OldRecord: "1000", NewRecord: "1200", CreatedDate: createdDateBase, SourceEventId: 1,
+			OperationType: Modified},
+		{OldRecord: "1200", NewRecord: "2000", CreatedDate: createdDateBase.Add(time.Hour), SourceEventId: 2,
+			OperationType: Modified},
+		{OldRecord: "2000", NewRecord: "", CreatedDate: createdDateBase.Add(2 * time.Hour), SourceEventId: 3,
+			OperationType: Deleted},
+	}
+
+	result := NewNumericDriftRule([]string{"claimAmount"}, 50, 0, false).
+		CheckForViolation(".claimAmount", fieldChanges)
+	assert.Len(t, result, 1)
+	assert.Equal(t, int64(2), result[0].sourceEventId)
+	assert.Equal(t, "Numeric field '.claimAmount' changed from '1200' to '2000' in event id 2 on "+
+		helper.FormatTimeStamp(createdDateBase.Add(time.Hour))+": relative delta 66.67% exceeds 50%",
+		result[0].message)
+
+	result = NewNumericDriftRule([]string{"claimAmount"}, 0, 100, false).
+		CheckForViolation(".claimAmount", fieldChanges)
+	assert.Len(t, result, 2)
+	assert.Contains(t, result[0].message, "absolute delta 200 exceeds 100")
+}
@@ -1,11 +1,13 @@
 package config
 
 import (
+	"ccd-comparator-data-diff-rapid/helper"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Configurations struct {
@@ -15,40 +17,204 @@ type Configurations struct {
 	Rule
 	Scan
 	Log
+	Api
 }
 
+// Database configures the source connection the cases are read from, and the sink connection the report is written
+// to. The sink falls back to the source connection for every value it doesn't set.
 type Database struct {
-	Username       string
-	Password       string
-	Host           string
-	Port           int
-	Name           string
+	Connection     `mapstructure:",squash"`
+	Sink           Connection
 	Driver         string
-	SslMode        string
 	BatchSize      int
 	EventDataTable string
+	WatermarkTable string
+
+	StatementTimeoutSeconds int
+	Retry                   Retry
+}
+
+// Connection configures a database connection pool. Pool sizes of 0 use the defaults.
+type Connection struct {
+	Username           string
+	Password           string
+	Host               string
+	Port               int
+	Name               string
+	SslMode            string
+	SslRootCert        string
+	SslCert            string
+	SslKey             string
+	ApplicationName    string
+	MaxOpenConnections int
+	MaxIdleConnections int
 }
 
+// SinkConnection returns the sink connection with the unset values taken from the source connection.
+func (d Database) SinkConnection() Connection {
+	sink := d.Sink
+	source := d.Connection
+	if sink.Host == "" {
+		sink.Host = source.Host
+	}
+	if sink.Port == 0 {
+		sink.Port = source.Port
+	}
+	if sink.Name == "" {
+		sink.Name = source.Name
+	}
+	if sink.Username == "" {
+		sink.Username = source.Username
+		if sink.Password == "" {
+			sink.Password = source.Password
+		}
+	}
+	if sink.SslMode == "" {
+		sink.SslMode = source.SslMode
+		sink.SslRootCert, sink.SslCert, sink.SslKey = source.SslRootCert, source.SslCert, source.SslKey
+	}
+	if sink.ApplicationName == "" {
+		sink.ApplicationName = source.ApplicationName
+	}
+	if sink.MaxOpenConnections == 0 {
+		sink.MaxOpenConnections = source.MaxOpenConnections
+	}
+	if sink.MaxIdleConnections == 0 {
+		sink.MaxIdleConnections = source.MaxIdleConnections
+	}
+	return sink
+}
+
+// Retry configures the retries of batches failing with transient database errors and the circuit breaker that
+// stops the database access after CircuitBreakerThreshold consecutive transient failures.
+type Retry struct {
+	MaxAttempts                   int
+	InitialBackoffMilliseconds    int64
+	MaxBackoffMilliseconds        int64
+	CircuitBreakerThreshold       int
+	CircuitBreakerCooldownSeconds int
+}
+
+// Api configures the CCD data store API used as the event source when BaseUrl is set.
+type Api struct {
+	BaseUrl           string
+	S2SToken          string
+	IdamToken         string
+	RequestsPerSecond float64
+	TimeoutSeconds    int
+}
+
+// Period configures the scanned period. An incremental scan starts after the watermark of the previous scan of the
+// jurisdiction and case type, and from StartTime only the first time. It ends IncrementalLagSeconds before it runs at
+// the latest, so that the events still being committed, or replicated, when it runs are left to the next scan.
 type Period struct {
-	StartTime string
-	EndTime   string
+	StartTime             string
+	EndTime               string
+	Timezone              string
+	Incremental           bool
+	IncrementalLagSeconds int
 }
 
+// Resolve returns the start and the end of the period in UTC, the end being now when it isn't set. The times without
+// an offset and the relative expressions are read in Timezone, UTC by default.
+func (p Period) Resolve(now time.Time) (time.Time, time.Time, error) {
+	location := time.UTC
+	if timezone := strings.TrimSpace(p.Timezone); timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return time.Time{}, time.Time{}, errors.Wrapf(err, "invalid period timezone '%s'", timezone)
+		}
+	}
+
+	if strings.TrimSpace(p.StartTime) == "" {
+		return time.Time{}, time.Time{}, errors.New("the period start time is empty")
+	}
+	start, err := helper.ParsePeriodTime(p.StartTime, now, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, "invalid period start time")
+	}
+
+	end := now.UTC()
+	if strings.TrimSpace(p.EndTime) != "" {
+		if end, err = helper.ParsePeriodTime(p.EndTime, now, location); err != nil {
+			return time.Time{}, time.Time{}, errors.Wrap(err, "invalid period end time")
+		}
+	}
+
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, errors.Errorf("the period start %s is not before its end %s",
+			start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return start, end, nil
+}
+
+// Worker configures the comparison workers. Once a run is interrupted, the batches in progress have
+// ShutdownTimeoutSeconds to finish before they are cancelled.
 type Worker struct {
-	Pool int
+	Pool                   int
+	ShutdownTimeoutSeconds int
 }
 
 type Rule struct {
-	Active string
+	Active        string
+	DateFields    []DateFieldRule
+	NumericFields []NumericFieldRule
+	Severities    map[string]string // low, medium or high by rule name, overriding the default severities
+	Gate          Gate
+}
+
+// Gate fails the run, with a non-zero exit code, when it finds more than MaxViolations violations of rules rated
+// MinSeverity or higher.
+type Gate struct {
+	Enabled       bool
+	MinSeverity   string
+	MaxViolations int
+}
+
+// DateFieldRule binds date value checks to field path patterns such as "hearingDate" or "*.dateIssued".
+type DateFieldRule struct {
+	Fields       []string
+	FrozenStates []string
+}
+
+// NumericFieldRule binds numeric drift checks to field path patterns. Deltas of 0 are not checked.
+type NumericFieldRule struct {
+	Fields           []string
+	MaxRelativeDelta float64 // percentage of the old value
+	MaxAbsoluteDelta float64
+}
+
+// EventFilter narrows the scanned cases and reported changes to events by name and user id. Empty lists don't
+// filter anything and the exclude lists win over the include lists.
+type EventFilter struct {
+	IncludeEvents []string
+	ExcludeEvents []string
+	IncludeUsers  []string
+	ExcludeUsers  []string
 }
 
 type Scan struct {
 	Jurisdiction         string
 	CaseType             string
 	CaseId               string
+	CaseIdFile           string
 	MaxEventProcessCount int
-	BatchSize            int
-	Concurrent           struct {
+	MaxCasePayloadBytes  int64
+	OversizedCase        string
+	// OversizedChunkEventCount is the number of events of an isolated case whose changes are analysed and saved
+	// together.
+	OversizedChunkEventCount int
+	// ReportBufferSize is the number of report rows of a batch held before they are saved.
+	ReportBufferSize  int
+	DeadLetterFile    string
+	CheckpointFile    string
+	SummaryFile       string
+	BatchSize         int
+	DiscoveryPageSize int
+	EventFilter       EventFilter
+	WatchedFields     []string
+	Sample            Sample
+	Concurrent        struct {
 		Event struct {
 			ThresholdMilliseconds int64
 		}
@@ -56,6 +222,18 @@ type Scan struct {
 	FieldChange struct {
 		Threshold int
 	}
+	DuplicateEvent struct {
+		BurstThresholdMilliseconds int64
+		BurstMinCount              int
+	}
+	TextIntegrity struct {
+		MinTruncationLength int
+	}
+	EventBurst struct {
+		WindowMilliseconds int64
+		MinEventCount      int
+		MinUserCount       int
+	}
 
 	Report struct {
 		Enabled            bool
@@ -65,6 +243,22 @@ type Scan struct {
 	}
 }
 
+// Sample configures the scan of a random sample of the discovered cases, either Size cases or Percentage percent of
+// them. Stratify by "casetype" or "eventcount" draws from every stratum in proportion to its number of cases.
+type Sample struct {
+	Size              int
+	Percentage        float64
+	Seed              int64
+	Stratify          string
+	EventCountBuckets []int
+	ConfidenceLevel   float64
+}
+
+// IsEnabled reports whether a sample size or percentage is set.
+func (s Sample) IsEnabled() bool {
+	return s.Size > 0 || s.Percentage > 0
+}
+
 type Log struct {
 	Level string
 	Type  string
@@ -104,6 +298,30 @@ func loadConfig(path, fileName string) *Configurations {
 
 func defaultBindings() {
 	viper.SetDefault("database.sslmode", "disable")
+	viper.SetDefault("database.watermarktable", "comparator_watermark")
+	viper.SetDefault("period.incrementallagseconds", 300)
+	viper.SetDefault("scan.duplicateevent.burstthresholdmilliseconds", 5000)
+	viper.SetDefault("scan.duplicateevent.burstmincount", 2)
+	viper.SetDefault("scan.textintegrity.mintruncationlength", 20)
+	viper.SetDefault("scan.eventburst.windowmilliseconds", 60000)
+	viper.SetDefault("scan.eventburst.mineventcount", 3)
+	viper.SetDefault("scan.eventburst.minusercount", 2)
+	viper.SetDefault("scan.oversizedcase", "isolate")
+	viper.SetDefault("scan.oversizedchunkeventcount", 500)
+	viper.SetDefault("scan.reportbuffersize", 1000)
+	viper.SetDefault("scan.deadletterfile", "failed_batches.ndjson")
+	viper.SetDefault("scan.checkpointfile", "checkpoints.ndjson")
+	viper.SetDefault("scan.summaryfile", "run_summary.json")
+	viper.SetDefault("worker.shutdowntimeoutseconds", 60)
+	viper.SetDefault("rule.gate.minseverity", "high")
+	viper.SetDefault("scan.sample.eventcountbuckets", []int{5, 10, 50, 100, 500})
+	viper.SetDefault("scan.sample.confidencelevel", 0.95)
+	viper.SetDefault("database.retry.maxattempts", 3)
+	viper.SetDefault("database.retry.initialbackoffmilliseconds", 500)
+	viper.SetDefault("database.retry.maxbackoffmilliseconds", 10000)
+	viper.SetDefault("database.retry.circuitbreakercooldownseconds", 60)
+	viper.SetDefault("api.requestspersecond", 5)
+	viper.SetDefault("api.timeoutseconds", 30)
 }
 
 func bindEnvironmentVariables() error {
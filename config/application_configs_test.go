@@ -0,0 +1,41 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestPeriodResolve(t *testing.T) {
+	now := time.Date(2024, 7, 2, 6, 0, 0, 0, time.UTC)
+
+	start, end, err := Period{StartTime: "yesterday", EndTime: "today", Timezone: "Europe/London"}.Resolve(now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 6, 30, 23, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2024, 7, 1, 23, 0, 0, 0, time.UTC), end)
+
+	start, end, err = Period{StartTime: "2024-07-01T00:00:00.000"}.Resolve(now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, now, end)
+}
+
+func TestPeriodResolve_InvalidPeriod(t *testing.T) {
+	now := time.Date(2024, 7, 2, 6, 0, 0, 0, time.UTC)
+
+	_, _, err := Period{StartTime: "today", EndTime: "yesterday"}.Resolve(now)
+	assert.EqualError(t, err, "the period start 2024-07-02T00:00:00Z is not before its end 2024-07-01T00:00:00Z")
+
+	_, _, err = Period{StartTime: "now"}.Resolve(now)
+	assert.ErrorContains(t, err, "is not before its end")
+
+	_, _, err = Period{StartTime: " "}.Resolve(now)
+	assert.EqualError(t, err, "the period start time is empty")
+
+	_, _, err = Period{StartTime: "now-1d", Timezone: "Europe/Londres"}.Resolve(now)
+	assert.ErrorContains(t, err, "invalid period timezone 'Europe/Londres'")
+
+	_, _, err = Period{StartTime: "now-1d", EndTime: "later"}.Resolve(now)
+	assert.ErrorContains(t, err, "invalid period end time")
+}
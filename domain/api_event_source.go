@@ -0,0 +1,288 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	caseEventsAcceptHeader  = "application/vnd.uk.gov.hmcts.ccd-data-store-api.case-events.v2+json;charset=UTF-8"
+	maxTooManyRequestsRetry = 3
+)
+
+var errCaseNotFound = errors.New("case not found")
+
+type caseEventsResponse struct {
+	AuditEvents []auditEvent `json:"auditEvents"`
+}
+
+type auditEvent struct {
+	Id            int64           `json:"id"`
+	EventId       string          `json:"event_id"`
+	UserId        string          `json:"user_id"`
+	CreatedDate   string          `json:"created_date"`
+	StateId       string          `json:"state_id"`
+	StateName     string          `json:"state_name"`
+	Summary       string          `json:"summary"`
+	Description   string          `json:"description"`
+	UserFirstName string          `json:"user_first_name"`
+	UserLastName  string          `json:"user_last_name"`
+	ProxiedBy     string          `json:"proxied_by"`
+	CaseTypeId    string          `json:"case_type_id"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// apiEventSource reads the case history from the CCD data store API one case at a time. Cases are identified by
+// their reference, so cases can't be discovered by period and have to be configured.
+type apiEventSource struct {
+	baseUrl     string
+	s2sToken    string
+	idamToken   string
+	client      *http.Client
+	rateLimiter *rateLimiter
+}
+
+func NewApiEventSource(configuration config.Api) EventSource {
+	return &apiEventSource{
+		baseUrl:     strings.TrimSuffix(configuration.BaseUrl, "/"),
+		s2sToken:    configuration.S2SToken,
+		idamToken:   configuration.IdamToken,
+		client:      &http.Client{Timeout: time.Duration(configuration.TimeoutSeconds) * time.Second},
+		rateLimiter: newRateLimiter(configuration.RequestsPerSecond),
+	}
+}
+
+func (a apiEventSource) findCasesByEventsInImpactPeriod(_ context.Context, _ Comparison, _ int64,
+	_ int) ([]string, error) {
+	return nil, errors.New("the CCD data store API can't discover cases by period, " +
+		"set scan.caseId or scan.caseIdFile instead")
+}
+
+func (a apiEventSource) findSampleFrame(_ context.Context, _ Comparison, _ int64,
+	_ int) ([]SampleFrameEntity, error) {
+	return nil, errors.New("the CCD data store API can't discover cases to sample by period")
+}
+
+func (a apiEventSource) findLatestEvent(_ context.Context, _ Comparison) (*Watermark, error) {
+	return nil, errors.New("the CCD data store API can't find the latest event of a case type")
+}
+
+func (a apiEventSource) findCaseTypeStatistics(_ context.Context, _ Comparison,
+	_ DiscoveryFilter) ([]CaseTypeStatisticsEntity, error) {
+	return nil, errors.New("the CCD data store API can't discover case types by period")
+}
+
+// findCaseIdsByReferences maps every reference to itself as the API addresses cases by reference. Unknown
+// references are reported when their events are loaded.
+func (a apiEventSource) findCaseIdsByReferences(_ context.Context, references []string) (map[string]string,
+	error) {
+	caseIds := make(map[string]string, len(references))
+	for _, reference := range references {
+		caseIds[reference] = reference
+	}
+	return caseIds, nil
+}
+
+func (a apiEventSource) findCasesByJurisdictionInImpactPeriod(ctx context.Context, caseIds []string,
+	comparison Comparison) ([]CaseDataEntity, error) {
+	var caseData []CaseDataEntity
+
+	err := a.streamEventsInImpactPeriod(ctx, caseIds, comparison, func(event CaseDataEntity) error {
+		caseData = append(caseData, event)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error in findCasesByJurisdictionInImpactPeriod()")
+	}
+
+	return caseData, nil
+}
+
+func (a apiEventSource) findWatchedFieldChanges(ctx context.Context, caseIds []string, comparison Comparison,
+	fields []string) ([]WatchedFieldChangeEntity, error) {
+	return streamWatchedFieldChanges(ctx, a, caseIds, comparison, fields)
+}
+
+// streamEventsInImpactPeriod fetches the cases one at a time and hands over the events of each before fetching the
+// next. The pages of a case are all fetched first, as the events of the period are selected from the whole history.
+func (a apiEventSource) streamEventsInImpactPeriod(ctx context.Context, caseIds []string, comparison Comparison,
+	handleEvent func(event CaseDataEntity) error) error {
+	for _, caseId := range caseIds {
+		caseEvents, err := a.fetchCaseEvents(ctx, strings.TrimSpace(caseId), comparison.Jurisdiction)
+		if errors.Is(err, errCaseNotFound) {
+			log.Warn().Msgf("Case '%s' doesn't exist in the data store, skipping", caseId)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := streamSortedEvents(selectEventsInImpactPeriod(caseEvents, comparison), handleEvent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchCaseEvents fetches the history of the case, following the Link header of the responses to its next page
+// when the data store pages it.
+func (a apiEventSource) fetchCaseEvents(ctx context.Context, reference, jurisdiction string) ([]CaseDataEntity, error) {
+	caseReference, err := strconv.ParseInt(reference, 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid case reference %s", reference)
+	}
+
+	var events []CaseDataEntity
+	fetched := make(map[string]bool)
+	for pageUrl := a.baseUrl + "/cases/" + url.PathEscape(reference) + "/events"; pageUrl != ""; {
+		if fetched[pageUrl] {
+			return nil, errors.Errorf("the events of case %s link back to the page %s", reference, pageUrl)
+		}
+		fetched[pageUrl] = true
+
+		body, nextPageUrl, err := a.get(ctx, pageUrl)
+		if err != nil {
+			return nil, err
+		}
+		if events, err = appendCaseEvents(events, body, caseReference, jurisdiction); err != nil {
+			return nil, err
+		}
+		pageUrl = nextPageUrl
+	}
+
+	return events, nil
+}
+
+func appendCaseEvents(events []CaseDataEntity, body []byte, caseReference int64,
+	jurisdiction string) ([]CaseDataEntity, error) {
+	var response caseEventsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, errors.Wrapf(err, "invalid events response for case %d", caseReference)
+	}
+
+	for _, auditEvent := range response.AuditEvents {
+		createdDate, err := parseEventTime(auditEvent.CreatedDate)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid created_date of event %d in case %d", auditEvent.Id, caseReference)
+		}
+
+		eventData := string(auditEvent.Data)
+		if len(auditEvent.Data) == 0 || eventData == "null" {
+			eventData = "{}"
+		}
+
+		events = append(events, CaseDataEntity{
+			CaseId:           caseReference,
+			Jurisdiction:     jurisdiction,
+			CaseTypeId:       auditEvent.CaseTypeId,
+			CaseDataId:       caseReference,
+			Reference:        caseReference,
+			EventId:          auditEvent.Id,
+			EventName:        auditEvent.EventId,
+			EventCreatedDate: createdDate,
+			EventData:        eventData,
+			UserId:           auditEvent.UserId,
+			StateId:          auditEvent.StateId,
+			EventContextEntity: EventContextEntity{
+				StateName:     auditEvent.StateName,
+				Summary:       auditEvent.Summary,
+				Description:   auditEvent.Description,
+				UserFirstName: auditEvent.UserFirstName,
+				UserLastName:  auditEvent.UserLastName,
+				ProxiedBy:     auditEvent.ProxiedBy,
+			},
+		})
+	}
+
+	return events, nil
+}
+
+// get sends a rate limited request to the data store and waits for the Retry-After period when it is throttled. It
+// returns the body and the URL of the next page, empty on the last one. The request is cancelled when the context is
+// done.
+func (a apiEventSource) get(ctx context.Context, requestUrl string) ([]byte, string, error) {
+	for attempt := 0; ; attempt++ {
+		if err := a.rateLimiter.wait(ctx); err != nil {
+			return nil, "", err
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "failed to create the data store request")
+		}
+		request.Header.Set("Authorization", bearerToken(a.idamToken))
+		request.Header.Set("ServiceAuthorization", bearerToken(a.s2sToken))
+		request.Header.Set("Accept", caseEventsAcceptHeader)
+		request.Header.Set("experimental", "true")
+
+		response, err := a.client.Do(request)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "data store request %s failed", requestUrl)
+		}
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to read the data store response of %s", requestUrl)
+		}
+
+		switch {
+		case response.StatusCode == http.StatusOK:
+			return body, nextPageUrl(response), nil
+		case response.StatusCode == http.StatusNotFound:
+			return nil, "", errCaseNotFound
+		case response.StatusCode == http.StatusTooManyRequests && attempt < maxTooManyRequestsRetry:
+			retryAfter, err := strconv.Atoi(response.Header.Get("Retry-After"))
+			if err != nil || retryAfter <= 0 {
+				retryAfter = 1
+			}
+			log.Warn().Msgf("Data store throttled %s, retrying in %d s", requestUrl, retryAfter)
+			if err := sleep(ctx, time.Duration(retryAfter)*time.Second); err != nil {
+				return nil, "", err
+			}
+		default:
+			return nil, "", fmt.Errorf("data store request %s failed with status %d: %s", requestUrl,
+				response.StatusCode, strings.TrimSpace(string(body)))
+		}
+	}
+}
+
+// nextPageUrl returns the URL the Link header of the response links the next page with, resolved against the
+// request, or an empty string when there is none.
+func nextPageUrl(response *http.Response) string {
+	for _, header := range response.Header.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				if strings.ReplaceAll(strings.TrimSpace(param), `"`, "") != "rel=next" {
+					continue
+				}
+				if next, err := response.Request.URL.Parse(strings.Trim(target, "<>")); err == nil {
+					return next.String()
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func bearerToken(token string) string {
+	if token == "" || strings.HasPrefix(token, "Bearer ") {
+		return token
+	}
+	return "Bearer " + token
+}
@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const caseEventsBody = `{"auditEvents": [
+	{"id": 3, "event_id": "updateRespondent", "user_id": "user2", "created_date": "2023-08-01T10:00:00.000",
+		"state_id": "open", "case_type_id": "CT1", "data": {"name": "c"}},
+	{"id": 2, "event_id": "updateCase", "user_id": "user1", "created_date": "2023-07-15T10:00:00.000",
+		"state_id": "open", "case_type_id": "CT1", "data": {"name": "b"}},
+	{"id": 1, "event_id": "createCase", "user_id": "user1", "created_date": "2023-07-01T10:00:00.000",
+		"state_id": "open", "case_type_id": "CT1", "data": {"name": "a"}}
+]}`
+
+func newDataStoreStub(t *testing.T, requestCount *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requestCount, 1)
+		assert.Equal(t, "Bearer idam-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "Bearer s2s-token", r.Header.Get("ServiceAuthorization"))
+		assert.Equal(t, caseEventsAcceptHeader, r.Header.Get("Accept"))
+
+		switch r.URL.Path {
+		case "/cases/1234567890123452/events":
+			_, _ = w.Write([]byte(caseEventsBody))
+		case "/cases/5000000000000000/events":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestApiEventSource_FindCasesByJurisdictionInImpactPeriod(t *testing.T) {
+	var requestCount int32
+	server := newDataStoreStub(t, &requestCount)
+	defer server.Close()
+
+	source := NewApiEventSource(config.Api{
+		BaseUrl:           server.URL + "/",
+		S2SToken:          "s2s-token",
+		IdamToken:         "Bearer idam-token",
+		RequestsPerSecond: 100,
+		TimeoutSeconds:    5,
+	})
+
+	cases, err := source.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"1234567890123452", "1698765432109877"},
+		newTestComparison())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), requestCount)
+	assert.Len(t, cases, 2)
+	assert.Equal(t, CaseDataEntity{
+		CaseId:           1234567890123452,
+		Jurisdiction:     "J1",
+		CaseTypeId:       "CT1",
+		CaseDataId:       1234567890123452,
+		Reference:        1234567890123452,
+		EventId:          3,
+		EventName:        "updateRespondent",
+		EventCreatedDate: time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC),
+		EventData:        `{"name": "c"}`,
+		UserId:           "user2",
+		StateId:          "open",
+	}, cases[1])
+	assert.Equal(t, int64(2), cases[0].EventId)
+
+	_, err = source.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"5000000000000000"}, newTestComparison())
+	assert.ErrorContains(t, err, "failed with status 500")
+
+	_, err = source.findCasesByEventsInImpactPeriod(context.Background(), newTestComparison(), 0, 10)
+	assert.Error(t, err)
+}
+
+func TestApiEventSource_FollowsTheNextPageOfTheEvents(t *testing.T) {
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requestCount, 1)
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", `</cases/1234567890123452/events?page=2>; rel="next"`)
+			_, _ = w.Write([]byte(`{"auditEvents": [{"id": 3, "event_id": "updateCase", ` +
+				`"created_date": "2023-08-01T10:00:00.000", "data": {"name": "c"}}]}`))
+		case "2":
+			_, _ = w.Write([]byte(`{"auditEvents": [{"id": 2, "event_id": "updateCase", ` +
+				`"created_date": "2023-07-15T10:00:00.000", "data": {"name": "b"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := NewApiEventSource(config.Api{BaseUrl: server.URL, RequestsPerSecond: 100, TimeoutSeconds: 5})
+
+	cases, err := source.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"1234567890123452"},
+		newTestComparison())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), requestCount)
+	assert.Len(t, cases, 2)
+	assert.Equal(t, int64(2), cases[0].EventId)
+	assert.Equal(t, int64(3), cases[1].EventId)
+}
+
+func TestRateLimiter_SpacesCalls(t *testing.T) {
+	limiter := newRateLimiter(50)
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_ = limiter.wait(context.Background())
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+
+	start = time.Now()
+	unlimited := newRateLimiter(0)
+	for i := 0; i < 100; i++ {
+		_ = unlimited.wait(context.Background())
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, newRateLimiter(0.001).wait(ctx), context.Canceled)
+}
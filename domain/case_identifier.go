@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"bufio"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const caseReferenceLength = 16
+
+// caseIdentifiers holds the user supplied case identifiers split into CCD case references and internal case_data ids.
+type caseIdentifiers struct {
+	references []string
+	caseIds    []string
+	invalid    []string
+}
+
+// parseCaseIdentifiers classifies each comma, space or newline separated value. Sixteen digit values, optionally
+// grouped with dashes, are treated as case references and must pass the Luhn check; other numbers are case_data ids.
+func parseCaseIdentifiers(values string) caseIdentifiers {
+	var identifiers caseIdentifiers
+
+	fields := strings.FieldsFunc(values, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	for _, field := range fields {
+		value := strings.ReplaceAll(field, "-", "")
+		switch {
+		case !isDigits(value):
+			identifiers.invalid = append(identifiers.invalid, field)
+		case len(value) == caseReferenceLength && isValidLuhn(value):
+			identifiers.references = append(identifiers.references, value)
+		case len(value) == caseReferenceLength:
+			identifiers.invalid = append(identifiers.invalid, field)
+		default:
+			identifiers.caseIds = append(identifiers.caseIds, value)
+		}
+	}
+
+	return identifiers
+}
+
+func readCaseIdentifierFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open the case id file")
+	}
+	defer file.Close()
+
+	var builder strings.Builder
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		builder.WriteString(line)
+		builder.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return "", errors.Wrap(err, "failed to read the case id file")
+	}
+
+	return builder.String(), nil
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidLuhn checks the trailing Luhn check digit that CCD appends to every case reference.
+func isValidLuhn(value string) bool {
+	sum := 0
+	double := false
+	for i := len(value) - 1; i >= 0; i-- {
+		digit := int(value[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
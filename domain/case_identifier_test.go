@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCaseIdentifiers(t *testing.T) {
+	identifiers := parseCaseIdentifiers("1234-5678-9012-3452, 42\n1234567890123453 ref-1,,1698765432109877")
+
+	assert.Equal(t, []string{"1234567890123452", "1698765432109877"}, identifiers.references)
+	assert.Equal(t, []string{"42"}, identifiers.caseIds)
+	assert.Equal(t, []string{"1234567890123453", "ref-1"}, identifiers.invalid)
+}
+
+func TestReadCaseIdentifierFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cases.txt")
+	err := os.WriteFile(filePath, []byte("# cases to rescan\n1234567890123452\n\n  42  \n"), 0600)
+	assert.NoError(t, err)
+
+	identifiers, err := readCaseIdentifierFile(filePath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890123452\n42\n", identifiers)
+
+	_, err = readCaseIdentifierFile(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
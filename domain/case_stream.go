@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	oversizedCaseIsolate = "isolate"
+	oversizedCaseSkip    = "skip"
+)
+
+// caseLimits bounds the events of a single case held in memory. Limits of 0 are not checked.
+type caseLimits struct {
+	maxEventCount   int
+	maxPayloadBytes int64
+}
+
+type oversizedCase struct {
+	caseId     int64
+	reference  int64
+	caseTypeId string
+	reason     string
+	comparison Comparison
+	// savedEventId is the last event whose report an earlier attempt saved, the case is compared from the next one.
+	savedEventId int64
+}
+
+func (l caseLimits) exceeded(eventCount int, payloadBytes int64) bool {
+	return (l.maxEventCount > 0 && eventCount > l.maxEventCount) ||
+		(l.maxPayloadBytes > 0 && payloadBytes > l.maxPayloadBytes)
+}
+
+func (l caseLimits) describe(eventCount int, payloadBytes int64) string {
+	var reasons []string
+	if l.maxEventCount > 0 && eventCount > l.maxEventCount {
+		reasons = append(reasons, fmt.Sprintf("%d events exceed maxEventProcessCount %d", eventCount,
+			l.maxEventCount))
+	}
+	if l.maxPayloadBytes > 0 && payloadBytes > l.maxPayloadBytes {
+		reasons = append(reasons, fmt.Sprintf("%d bytes of event data exceed maxCasePayloadBytes %d", payloadBytes,
+			l.maxPayloadBytes))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// streamCases groups the streamed events by case and hands each complete case to handleCase. The events of a case
+// are dropped as soon as it exceeds the limits, and the case is handed to handleOversized once all of its events
+// have been counted. It stops before the next case once the context is done.
+func streamCases(ctx context.Context, source EventSource, caseIds []string, comparison Comparison, limits caseLimits,
+	handleCase func(caseEvents []CaseDataEntity) error, handleOversized func(oversized oversizedCase)) error {
+	var caseEvents []CaseDataEntity
+	var current CaseDataEntity
+	var eventCount int
+	var payloadBytes int64
+	isOversized := false
+
+	completeCase := func() error {
+		if eventCount == 0 {
+			return nil
+		}
+		if isOversized {
+			handleOversized(oversizedCase{
+				caseId:     current.CaseId,
+				reference:  current.Reference,
+				caseTypeId: current.CaseTypeId,
+				reason:     limits.describe(eventCount, payloadBytes),
+				comparison: comparison,
+			})
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return handleCase(caseEvents)
+	}
+
+	err := source.streamEventsInImpactPeriod(ctx, caseIds, comparison, func(event CaseDataEntity) error {
+		if eventCount > 0 && event.CaseId != current.CaseId {
+			if err := completeCase(); err != nil {
+				return err
+			}
+			caseEvents, eventCount, payloadBytes, isOversized = nil, 0, 0, false
+		}
+		if eventCount == 0 {
+			current = event
+		}
+
+		eventCount++
+		payloadBytes += int64(len(event.EventData))
+		if isOversized {
+			return nil
+		}
+		if limits.exceeded(eventCount, payloadBytes) {
+			isOversized = true
+			caseEvents = nil
+			return nil
+		}
+		caseEvents = append(caseEvents, event)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return completeCase()
+}
+
+// unstreamedCaseIds returns the case ids that weren't handed over by streamCases yet.
+func unstreamedCaseIds(caseIds []string, streamed map[int64]bool) []string {
+	if len(streamed) == 0 {
+		return caseIds
+	}
+	var remaining []string
+	for _, caseId := range caseIds {
+		if id, err := strconv.ParseInt(caseId, 10, 64); err != nil || !streamed[id] {
+			remaining = append(remaining, caseId)
+		}
+	}
+	return remaining
+}
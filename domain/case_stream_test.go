@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func newStreamSource(events []CaseDataEntity) EventSource {
+	source := new(MockQueryRepository)
+	source.On("findCasesByJurisdictionInImpactPeriod", mock.Anything, mock.Anything).Return(events, nil)
+	return source
+}
+
+func TestStreamCases_HandsOverEachCase(t *testing.T) {
+	source := newStreamSource([]CaseDataEntity{
+		{CaseId: 1, EventId: 1},
+		{CaseId: 1, EventId: 2},
+		{CaseId: 2, EventId: 3},
+		{CaseId: 3, EventId: 4},
+		{CaseId: 3, EventId: 5},
+	})
+
+	var caseSizes []int
+	err := streamCases(context.Background(), source, nil, Comparison{}, caseLimits{},
+		func(caseEvents []CaseDataEntity) error {
+			for _, event := range caseEvents {
+				assert.Equal(t, caseEvents[0].CaseId, event.CaseId)
+			}
+			caseSizes = append(caseSizes, len(caseEvents))
+			return nil
+		},
+		func(oversized oversizedCase) { t.Fatalf("unexpected oversized case %d", oversized.caseId) })
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1, 2}, caseSizes)
+}
+
+func TestStreamCases_HandsOverOversizedCases(t *testing.T) {
+	source := newStreamSource([]CaseDataEntity{
+		{CaseId: 1, Reference: 11, CaseTypeId: "CT1", EventId: 1, EventData: `{"a":"1"}`},
+		{CaseId: 1, Reference: 11, CaseTypeId: "CT1", EventId: 2, EventData: `{"a":"2"}`},
+		{CaseId: 1, Reference: 11, CaseTypeId: "CT1", EventId: 3, EventData: `{"a":"3"}`},
+		{CaseId: 2, Reference: 22, EventId: 4, EventData: `{"a":"4"}`},
+		{CaseId: 3, Reference: 33, EventId: 5, EventData: `{"a":"` + string(make([]byte, 100)) + `"}`},
+	})
+
+	var handledCaseIds []int64
+	var oversizedCases []oversizedCase
+	err := streamCases(context.Background(), source, nil, Comparison{},
+		caseLimits{maxEventCount: 2, maxPayloadBytes: 50},
+		func(caseEvents []CaseDataEntity) error {
+			handledCaseIds = append(handledCaseIds, caseEvents[0].CaseId)
+			return nil
+		},
+		func(oversized oversizedCase) { oversizedCases = append(oversizedCases, oversized) })
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, handledCaseIds)
+	assert.Len(t, oversizedCases, 2)
+	assert.Equal(t, int64(11), oversizedCases[0].reference)
+	assert.Equal(t, "CT1", oversizedCases[0].caseTypeId)
+	assert.Equal(t, "3 events exceed maxEventProcessCount 2", oversizedCases[0].reason)
+	assert.Equal(t, int64(3), oversizedCases[1].caseId)
+	assert.Equal(t, "108 bytes of event data exceed maxCasePayloadBytes 50", oversizedCases[1].reason)
+}
+
+func TestStreamCases_StopsOnceTheContextIsDone(t *testing.T) {
+	source := newStreamSource([]CaseDataEntity{
+		{CaseId: 1, EventId: 1},
+		{CaseId: 2, EventId: 2},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var handledCaseIds []int64
+	err := streamCases(ctx, source, nil, Comparison{}, caseLimits{},
+		func(caseEvents []CaseDataEntity) error {
+			handledCaseIds = append(handledCaseIds, caseEvents[0].CaseId)
+			cancel()
+			return nil
+		},
+		func(oversized oversizedCase) { t.Fatalf("unexpected oversized case %d", oversized.caseId) })
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []int64{1}, handledCaseIds)
+}
+
+func TestUnstreamedCaseIds(t *testing.T) {
+	caseIds := []string{"1", "2", "3"}
+
+	assert.Equal(t, caseIds, unstreamedCaseIds(caseIds, map[int64]bool{}))
+	assert.Equal(t, []string{"2"}, unstreamedCaseIds(caseIds, map[int64]bool{1: true, 3: true}))
+}
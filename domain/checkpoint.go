@@ -0,0 +1,322 @@
+package domain
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkpoint is a line of the checkpoint file. The first line of a run records when it started and the seed of its
+// sample, so that a resumed run resolves the same period and draws the same sample, and the next ones the case id
+// ranges of the batches that are done, or the last event of an isolated case whose report is saved.
+type checkpoint struct {
+	RunId          string     `json:"run_id"`
+	RunStartedAt   time.Time  `json:"run_started_at"`
+	SampleSeed     int64      `json:"sample_seed,omitempty"`
+	Jurisdiction   string     `json:"jurisdiction,omitempty"`
+	CaseTypeId     string     `json:"case_type_id,omitempty"`
+	FirstCaseId    int64      `json:"first_case_id,omitempty"`
+	LastCaseId     int64      `json:"last_case_id,omitempty"`
+	PendingCaseIds []int64    `json:"pending_case_ids,omitempty"`
+	SavedEventId   int64      `json:"saved_event_id,omitempty"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+// covers reports whether the case was compared by the batch, or recorded in the dead letter file, and isn't one of
+// its oversized cases still to be compared on their own.
+func (c checkpoint) covers(caseId int64) bool {
+	return c.CompletedAt != nil && caseId >= c.FirstCaseId && caseId <= c.LastCaseId &&
+		!slices.Contains(c.PendingCaseIds, caseId)
+}
+
+// Checkpoints appends the batches of a run that are done to the checkpoint file, and skips the batches an earlier
+// attempt of the run completed. Nothing is recorded when the path is empty.
+type Checkpoints struct {
+	path         string
+	runId        string
+	runStartedAt time.Time
+	sampleSeed   int64
+	mutex        sync.Mutex
+	completed    map[[2]string]*completedBatches
+	// savedEventIds holds the last event of the isolated cases of each jurisdiction and case type whose report is
+	// saved.
+	savedEventIds map[[2]string]map[int64]int64
+}
+
+// completedBatches holds the completed batches of a jurisdiction and case type in first case id order, and for
+// each of them the last case id any batch up to it reaches, so that the batches covering a case are binary-searched.
+type completedBatches struct {
+	checkpoints []checkpoint
+	reach       []int64
+}
+
+func (b *completedBatches) sort() {
+	sort.Slice(b.checkpoints, func(i, j int) bool {
+		return b.checkpoints[i].FirstCaseId < b.checkpoints[j].FirstCaseId
+	})
+	b.reach = make([]int64, len(b.checkpoints))
+	for i, record := range b.checkpoints {
+		b.reach[i] = record.LastCaseId
+		if i > 0 && b.reach[i-1] > record.LastCaseId {
+			b.reach[i] = b.reach[i-1]
+		}
+	}
+}
+
+// covers reports whether a batch covers the case. Only the batches starting at or before the case that a batch up
+// to them reaches the case from are checked, which is a single one when the batches don't overlap.
+func (b *completedBatches) covers(caseId int64) bool {
+	i := sort.Search(len(b.checkpoints), func(i int) bool { return b.checkpoints[i].FirstCaseId > caseId }) - 1
+	for ; i >= 0 && b.reach[i] >= caseId; i-- {
+		if b.checkpoints[i].covers(caseId) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCheckpoints starts a new run in the checkpoint file at path, recording the seed its sample is drawn with.
+func NewCheckpoints(path string, runStartedAt time.Time, sampleSeed int64) (*Checkpoints, error) {
+	c := &Checkpoints{
+		path:          strings.TrimSpace(path),
+		runId:         uuid.New().String(),
+		runStartedAt:  runStartedAt.UTC(),
+		sampleSeed:    sampleSeed,
+		completed:     make(map[[2]string]*completedBatches),
+		savedEventIds: make(map[[2]string]map[int64]int64),
+	}
+	return c, c.write(checkpoint{RunId: c.runId, RunStartedAt: c.runStartedAt, SampleSeed: sampleSeed})
+}
+
+// ResumeCheckpoints resumes the run from the checkpoint file at path, together with the batches it completed.
+func ResumeCheckpoints(path, runId string) (*Checkpoints, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, errors.New("the checkpoint file is not configured")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the checkpoint file")
+	}
+	defer file.Close()
+
+	var c *Checkpoints
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var record checkpoint
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return nil, errors.Wrapf(err, "invalid checkpoint on line %d", lineNumber)
+		}
+		if record.RunId != runId {
+			continue
+		}
+		if c == nil {
+			c = &Checkpoints{path: path, runId: runId, runStartedAt: record.RunStartedAt,
+				sampleSeed: record.SampleSeed, completed: make(map[[2]string]*completedBatches),
+				savedEventIds: make(map[[2]string]map[int64]int64)}
+		}
+		key := [2]string{record.Jurisdiction, record.CaseTypeId}
+		if record.CompletedAt != nil {
+			if c.completed[key] == nil {
+				c.completed[key] = &completedBatches{}
+			}
+			c.completed[key].checkpoints = append(c.completed[key].checkpoints, record)
+		} else if record.SavedEventId > 0 {
+			if c.savedEventIds[key] == nil {
+				c.savedEventIds[key] = make(map[int64]int64)
+			}
+			c.savedEventIds[key][record.FirstCaseId] = max(c.savedEventIds[key][record.FirstCaseId],
+				record.SavedEventId)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read the checkpoint file")
+	}
+	if c == nil {
+		return nil, errors.Errorf("the run %s is not in the checkpoint file %s", runId, path)
+	}
+	for _, batches := range c.completed {
+		batches.sort()
+	}
+
+	return c, nil
+}
+
+func (c *Checkpoints) RunId() string {
+	return c.runId
+}
+
+// RunStartedAt returns when the run first started, the time its relative period is resolved at.
+func (c *Checkpoints) RunStartedAt() time.Time {
+	return c.runStartedAt
+}
+
+// SampleSeed returns the seed the sample of the run is drawn with, 0 when the run didn't record one.
+func (c *Checkpoints) SampleSeed() int64 {
+	return c.sampleSeed
+}
+
+// CompletedBatchCount returns the number of batches the earlier attempts of the run completed.
+func (c *Checkpoints) CompletedBatchCount() int {
+	count := 0
+	for _, batches := range c.completed {
+		count += len(batches.checkpoints)
+	}
+	return count
+}
+
+// isEnabled reports whether the batches of the run are checkpointed.
+func (c *Checkpoints) isEnabled() bool {
+	return c != nil && c.path != ""
+}
+
+// remaining returns the case ids of the comparison that no completed batch covers. It returns all of them when the
+// run isn't checkpointed.
+func (c *Checkpoints) remaining(comparison Comparison, caseIds []string) []string {
+	if c == nil {
+		return caseIds
+	}
+	completed := c.completed[[2]string{comparison.Jurisdiction, comparison.CaseTypeId}]
+	if completed == nil {
+		return caseIds
+	}
+
+	remaining := make([]string, 0, len(caseIds))
+	for _, caseId := range caseIds {
+		id, err := strconv.ParseInt(caseId, 10, 64)
+		if err != nil || !completed.covers(id) {
+			remaining = append(remaining, caseId)
+		}
+	}
+	return remaining
+}
+
+// complete records the batch as done, except for its oversized cases still to be compared on their own. It is a
+// no-op when the run isn't checkpointed.
+func (c *Checkpoints) complete(w comparisonWork, pendingCaseIds []int64) error {
+	if c == nil || c.path == "" || len(w.caseIds) == 0 {
+		return nil
+	}
+
+	var firstCaseId, lastCaseId int64
+	for i, caseId := range w.caseIds {
+		id, err := strconv.ParseInt(caseId, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid case id %s", caseId)
+		}
+		if i == 0 || id < firstCaseId {
+			firstCaseId = id
+		}
+		if i == 0 || id > lastCaseId {
+			lastCaseId = id
+		}
+	}
+
+	return c.write(c.completedRecord(w.comparison, firstCaseId, lastCaseId, pendingCaseIds))
+}
+
+// completeCases records the cases of the batch whose report is saved as done before the rest of the batch, leaving
+// the other cases of the batch between them pending. It is a no-op when the run isn't checkpointed.
+func (c *Checkpoints) completeCases(w comparisonWork, caseIds []int64) error {
+	if c == nil || c.path == "" || len(caseIds) == 0 {
+		return nil
+	}
+
+	firstCaseId, lastCaseId := slices.Min(caseIds), slices.Max(caseIds)
+	done := make(map[int64]bool, len(caseIds))
+	for _, caseId := range caseIds {
+		done[caseId] = true
+	}
+	var pendingCaseIds []int64
+	for _, caseId := range w.caseIds {
+		id, err := strconv.ParseInt(caseId, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid case id %s", caseId)
+		}
+		if id >= firstCaseId && id <= lastCaseId && !done[id] {
+			pendingCaseIds = append(pendingCaseIds, id)
+		}
+	}
+
+	return c.write(c.completedRecord(w.comparison, firstCaseId, lastCaseId, pendingCaseIds))
+}
+
+// saveProgress records the last event of the isolated case whose report is saved, so that a resumed run compares
+// the case from the next event. It is a no-op when the run isn't checkpointed.
+func (c *Checkpoints) saveProgress(comparison Comparison, caseId, savedEventId int64) error {
+	if c == nil || c.path == "" {
+		return nil
+	}
+
+	return c.write(checkpoint{
+		RunId:        c.runId,
+		RunStartedAt: c.runStartedAt,
+		Jurisdiction: comparison.Jurisdiction,
+		CaseTypeId:   comparison.CaseTypeId,
+		FirstCaseId:  caseId,
+		LastCaseId:   caseId,
+		SavedEventId: savedEventId,
+	})
+}
+
+// savedEventId returns the last event of the isolated case whose report an earlier attempt of the run saved, 0 when
+// none did.
+func (c *Checkpoints) savedEventId(comparison Comparison, caseId int64) int64 {
+	if c == nil {
+		return 0
+	}
+	return c.savedEventIds[[2]string{comparison.Jurisdiction, comparison.CaseTypeId}][caseId]
+}
+
+func (c *Checkpoints) completedRecord(comparison Comparison, firstCaseId, lastCaseId int64,
+	pendingCaseIds []int64) checkpoint {
+	completedAt := time.Now().UTC()
+	return checkpoint{
+		RunId:          c.runId,
+		RunStartedAt:   c.runStartedAt,
+		Jurisdiction:   comparison.Jurisdiction,
+		CaseTypeId:     comparison.CaseTypeId,
+		FirstCaseId:    firstCaseId,
+		LastCaseId:     lastCaseId,
+		PendingCaseIds: pendingCaseIds,
+		CompletedAt:    &completedAt,
+	}
+}
+
+func (c *Checkpoints) write(record checkpoint) error {
+	if c.path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the checkpoint")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	file, err := os.OpenFile(c.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the checkpoint file")
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return errors.Wrap(err, "failed to write the checkpoint file")
+}
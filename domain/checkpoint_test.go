@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpoints_ResumedRunSkipsCompletedBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.ndjson")
+	runStartedAt := time.Date(2024, 7, 2, 6, 0, 0, 0, time.UTC)
+	comparison := Comparison{Jurisdiction: "J1", CaseTypeId: "CT1"}
+
+	checkpoints, err := NewCheckpoints(path, runStartedAt, 42)
+	assert.NoError(t, err)
+	other, err := NewCheckpoints(path, runStartedAt, 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, checkpoints.complete(comparisonWork{comparison: comparison,
+		caseIds: []string{"12", "10", "15"}}, []int64{12}))
+	assert.NoError(t, other.complete(comparisonWork{comparison: comparison, caseIds: []string{"20"}}, nil))
+
+	resumed, err := ResumeCheckpoints(path, checkpoints.RunId())
+
+	assert.NoError(t, err)
+	assert.Equal(t, runStartedAt, resumed.RunStartedAt())
+	assert.Equal(t, int64(42), resumed.SampleSeed())
+	assert.Equal(t, 1, resumed.CompletedBatchCount())
+	assert.Equal(t, []string{"9", "12", "16", "20"},
+		resumed.remaining(comparison, []string{"9", "10", "12", "13", "15", "16", "20"}))
+	assert.Equal(t, []string{"10"}, resumed.remaining(Comparison{Jurisdiction: "J1"}, []string{"10"}))
+}
+
+func TestCheckpoints_ResumedRunSkipsCasesOfOverlappingBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.ndjson")
+	comparison := Comparison{Jurisdiction: "J1", CaseTypeId: "CT1"}
+
+	checkpoints, err := NewCheckpoints(path, time.Now(), 0)
+	assert.NoError(t, err)
+	for _, caseIds := range [][]string{{"30", "40"}, {"1", "50"}, {"5", "8"}, {"60", "70"}} {
+		assert.NoError(t, checkpoints.complete(comparisonWork{comparison: comparison, caseIds: caseIds}, nil))
+	}
+	assert.NoError(t, checkpoints.complete(comparisonWork{comparison: comparison, caseIds: []string{"6", "7"}},
+		[]int64{7}))
+
+	resumed, err := ResumeCheckpoints(path, checkpoints.RunId())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, resumed.CompletedBatchCount())
+	assert.Equal(t, []string{"0", "55", "71"},
+		resumed.remaining(comparison, []string{"0", "1", "7", "45", "50", "55", "60", "65", "71"}))
+}
+
+func TestCheckpoints_ResumedRunComparesIsolatedCasesAfterTheirSavedEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.ndjson")
+	comparison := Comparison{Jurisdiction: "J1", CaseTypeId: "CT1"}
+
+	checkpoints, err := NewCheckpoints(path, time.Now(), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, checkpoints.complete(comparisonWork{comparison: comparison, caseIds: []string{"10", "11"}},
+		[]int64{11}))
+	assert.NoError(t, checkpoints.saveProgress(comparison, 11, 500))
+	assert.NoError(t, checkpoints.saveProgress(comparison, 11, 1000))
+
+	resumed, err := ResumeCheckpoints(path, checkpoints.RunId())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resumed.CompletedBatchCount())
+	assert.Equal(t, []string{"11"}, resumed.remaining(comparison, []string{"10", "11"}))
+	assert.Equal(t, int64(1000), resumed.savedEventId(comparison, 11))
+	assert.Equal(t, int64(0), resumed.savedEventId(comparison, 10))
+}
+
+func TestCheckpoints_ResumeUnknownRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.ndjson")
+	_, err := NewCheckpoints(path, time.Now(), 0)
+	assert.NoError(t, err)
+
+	_, err = ResumeCheckpoints(path, "unknown")
+	assert.EqualError(t, err, "the run unknown is not in the checkpoint file "+path)
+
+	_, err = ResumeCheckpoints("", "unknown")
+	assert.EqualError(t, err, "the checkpoint file is not configured")
+}
+
+func TestCheckpoints_NothingIsRecordedWithoutPath(t *testing.T) {
+	dir := t.TempDir()
+	checkpoints, err := NewCheckpoints("", time.Now(), 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, checkpoints.complete(comparisonWork{caseIds: []string{"1"}}, nil))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Equal(t, []string{"1"}, (*Checkpoints)(nil).remaining(Comparison{}, []string{"1"}))
+}
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"bufio"
+	"ccd-comparator-data-diff-rapid/config"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// failedBatch is a line of the dead letter file. It holds everything needed to compare the batch again, and for an
+// isolated case the last event whose report is saved.
+type failedBatch struct {
+	TransactionId string             `json:"transaction_id"`
+	Jurisdiction  string             `json:"jurisdiction"`
+	CaseTypeId    string             `json:"case_type_id"`
+	StartTime     time.Time          `json:"start_time"`
+	EndTime       time.Time          `json:"end_time"`
+	EventFilter   config.EventFilter `json:"event_filter"`
+	AfterEventId  int64              `json:"after_event_id,omitempty"`
+	UntilEventId  int64              `json:"until_event_id,omitempty"`
+	Incremental   bool               `json:"incremental,omitempty"`
+	CaseIds       []string           `json:"case_ids"`
+	SavedEventId  int64              `json:"saved_event_id,omitempty"`
+	Error         string             `json:"error"`
+	FailedAt      time.Time          `json:"failed_at"`
+}
+
+// deadLetterFile appends the failed batches to an NDJSON file. The file is only created when a batch fails and
+// nothing is recorded when the path is empty.
+type deadLetterFile struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func newDeadLetterFile(path string) *deadLetterFile {
+	return &deadLetterFile{path: strings.TrimSpace(path)}
+}
+
+func (d *deadLetterFile) write(w comparisonWork, cause error) error {
+	if d.path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(failedBatch{
+		TransactionId: w.transactionId,
+		Jurisdiction:  w.comparison.Jurisdiction,
+		CaseTypeId:    w.comparison.CaseTypeId,
+		StartTime:     w.comparison.StartTime,
+		EndTime:       w.comparison.SearchPeriodEndTime,
+		EventFilter:   w.comparison.EventFilter,
+		AfterEventId:  w.comparison.AfterEventId,
+		UntilEventId:  w.comparison.UntilEventId,
+		Incremental:   w.comparison.Incremental,
+		CaseIds:       w.caseIds,
+		SavedEventId:  w.savedEventId,
+		Error:         cause.Error(),
+		FailedAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the failed batch")
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	file, err := os.OpenFile(d.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the dead letter file")
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return errors.Wrap(err, "failed to write the dead letter file")
+}
+
+// readFailedBatches reads the batches recorded in the dead letter file back as comparison work.
+func readFailedBatches(path string) ([]comparisonWork, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the dead letter file")
+	}
+	defer file.Close()
+
+	var works []comparisonWork
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var batch failedBatch
+		if err := json.Unmarshal([]byte(line), &batch); err != nil {
+			return nil, errors.Wrapf(err, "invalid failed batch on line %d", lineNumber)
+		}
+		works = append(works, comparisonWork{
+			transactionId: batch.TransactionId,
+			caseIds:       batch.CaseIds,
+			savedEventId:  batch.SavedEventId,
+			comparison: Comparison{
+				Jurisdiction:        batch.Jurisdiction,
+				CaseTypeId:          batch.CaseTypeId,
+				StartTime:           batch.StartTime,
+				SearchPeriodEndTime: batch.EndTime,
+				EventFilter:         batch.EventFilter,
+				AfterEventId:        batch.AfterEventId,
+				UntilEventId:        batch.UntilEventId,
+				Incremental:         batch.Incremental,
+			},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read the dead letter file")
+	}
+
+	return works, nil
+}
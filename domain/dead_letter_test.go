@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterFile_WritesBatchesThatCanBeReadBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed_batches.ndjson")
+	deadLetters := newDeadLetterFile(path)
+
+	work := comparisonWork{
+		transactionId: "tid-1",
+		caseIds:       []string{"1", "2"},
+		comparison: Comparison{
+			Jurisdiction:        "J1",
+			CaseTypeId:          "CT1",
+			StartTime:           time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC),
+			SearchPeriodEndTime: time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC),
+			EventFilter:         config.EventFilter{IncludeEvents: []string{"updateCase"}},
+			AfterEventId:        10,
+			UntilEventId:        20,
+			Incremental:         true,
+		},
+	}
+	assert.NoError(t, deadLetters.write(work, errors.New("connection reset")))
+	assert.NoError(t, deadLetters.write(comparisonWork{transactionId: "tid-2", caseIds: []string{"3"}},
+		errors.New("connection reset")))
+
+	works, err := readFailedBatches(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []comparisonWork{work,
+		{transactionId: "tid-2", caseIds: []string{"3"}, comparison: Comparison{
+			StartTime: time.Time{}.UTC(), SearchPeriodEndTime: time.Time{}.UTC()}}}, works)
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"slices"
+	"strconv"
+)
+
+const bytesPerMegabyte = 1024 * 1024
+
+// CaseTypeStatisticsEntity counts the cases of a case type with more than one event in the period, and their events.
+type CaseTypeStatisticsEntity struct {
+	Jurisdiction   string `db:"jurisdiction"`
+	CaseTypeId     string `db:"case_type_id"`
+	CaseCount      int64  `db:"case_count"`
+	EventCount     int64  `db:"event_count"`
+	EventDataBytes int64  `db:"event_data_bytes"`
+}
+
+// DiscoveryFilter narrows the discovered case types. Empty lists don't filter anything and the exclude lists win
+// over the include lists.
+type DiscoveryFilter struct {
+	IncludeJurisdictions []string
+	ExcludeJurisdictions []string
+	IncludeCaseTypes     []string
+	ExcludeCaseTypes     []string
+}
+
+func (f DiscoveryFilter) isIncluded(jurisdiction, caseTypeId string) bool {
+	return isValueIncluded(f.IncludeJurisdictions, f.ExcludeJurisdictions, jurisdiction) &&
+		isValueIncluded(f.IncludeCaseTypes, f.ExcludeCaseTypes, caseTypeId)
+}
+
+func isValueIncluded(include, exclude []string, value string) bool {
+	return !slices.Contains(exclude, value) && (len(include) == 0 || slices.Contains(include, value))
+}
+
+// DiscoverCaseTypes writes every jurisdiction and case type with multi event cases in the period as a CSV that can
+// be passed as -sourceFile. Besides the case count, it holds the event count and the estimated scan cost, the
+// megabytes of event data to load.
+func DiscoverCaseTypes(ctx context.Context, source EventSource, comparison Comparison, filter DiscoveryFilter,
+	output io.Writer) (int, error) {
+	statistics, err := source.findCaseTypeStatistics(ctx, comparison, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	writer := csv.NewWriter(output)
+	_ = writer.Write([]string{"jurisdiction", "case_type_id", "count", "event_count", "estimated_cost_mb"})
+	for _, statistic := range statistics {
+		_ = writer.Write([]string{
+			statistic.Jurisdiction,
+			statistic.CaseTypeId,
+			strconv.FormatInt(statistic.CaseCount, 10),
+			strconv.FormatInt(statistic.EventCount, 10),
+			fmt.Sprintf("%.1f", float64(statistic.EventDataBytes)/bytesPerMegabyte),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return 0, errors.Wrap(err, "failed to write the case types")
+	}
+
+	return len(statistics), nil
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoverCaseTypes_WritesSourceFile(t *testing.T) {
+	source := new(MockQueryRepository)
+	filter := DiscoveryFilter{ExcludeJurisdictions: []string{"J3"}}
+	source.On("findCaseTypeStatistics", Comparison{}, filter).Return([]CaseTypeStatisticsEntity{
+		{Jurisdiction: "J1", CaseTypeId: "CT1", CaseCount: 103, EventCount: 412, EventDataBytes: 3 * bytesPerMegabyte},
+		{Jurisdiction: "J2", CaseTypeId: "CT2", CaseCount: 2, EventCount: 5, EventDataBytes: 52429},
+	}, nil)
+
+	var output bytes.Buffer
+	count, err := DiscoverCaseTypes(context.Background(), source, Comparison{}, filter, &output)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "jurisdiction,case_type_id,count,event_count,estimated_cost_mb\n"+
+		"J1,CT1,103,412,3.0\n"+
+		"J2,CT2,2,5,0.1\n", output.String())
+}
+
+func TestFindCaseTypeStatistics_FiltersJurisdictionsAndCaseTypes(t *testing.T) {
+	mockDB := new(MockDB)
+	queryRepo := NewQueryRepository(mockDB)
+
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]domain.CaseTypeStatisticsEntity"),
+		mock.MatchedBy(func(query string) bool {
+			return strings.Contains(query, "AND cd.jurisdiction = ANY($3) AND NOT (cd.case_type_id = ANY($4))") &&
+				strings.Contains(query, "HAVING COUNT(ce.id) > 1")
+		}),
+		mock.MatchedBy(func(args []interface{}) bool { return len(args) == 4 })).
+		Return(nil)
+
+	_, err := queryRepo.findCaseTypeStatistics(context.Background(), Comparison{},
+		DiscoveryFilter{IncludeJurisdictions: []string{"J1"}, ExcludeCaseTypes: []string{"CT2"}})
+
+	assert.NoError(t, err)
+	mockDB.AssertExpectations(t)
+}
+
+func TestFileEventSource_FindCaseTypeStatistics(t *testing.T) {
+	inPeriod := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	source := fileEventSource{events: []CaseDataEntity{
+		{CaseId: 1, Jurisdiction: "J1", CaseTypeId: "CT1", EventId: 1, EventCreatedDate: inPeriod, EventData: "{}"},
+		{CaseId: 1, Jurisdiction: "J1", CaseTypeId: "CT1", EventId: 2, EventCreatedDate: inPeriod, EventData: "{}"},
+		{CaseId: 2, Jurisdiction: "J1", CaseTypeId: "CT1", EventId: 3, EventCreatedDate: inPeriod, EventData: "{}"},
+		{CaseId: 3, Jurisdiction: "J2", CaseTypeId: "CT2", EventId: 4, EventCreatedDate: inPeriod, EventData: "{}"},
+		{CaseId: 3, Jurisdiction: "J2", CaseTypeId: "CT2", EventId: 5, EventCreatedDate: inPeriod, EventData: "{}"},
+	}}
+	comparison := Comparison{StartTime: inPeriod.Add(-time.Hour), SearchPeriodEndTime: inPeriod.Add(time.Hour)}
+
+	statistics, err := source.findCaseTypeStatistics(context.Background(), comparison, DiscoveryFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []CaseTypeStatisticsEntity{
+		{Jurisdiction: "J1", CaseTypeId: "CT1", CaseCount: 1, EventCount: 2, EventDataBytes: 4},
+		{Jurisdiction: "J2", CaseTypeId: "CT2", CaseCount: 1, EventCount: 2, EventDataBytes: 4},
+	}, statistics)
+
+	statistics, err = source.findCaseTypeStatistics(context.Background(), comparison, DiscoveryFilter{ExcludeCaseTypes: []string{"CT1"}})
+	assert.NoError(t, err)
+	assert.Len(t, statistics, 1)
+	assert.Equal(t, "J2", statistics[0].Jurisdiction)
+}
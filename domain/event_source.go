@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"context"
+	"sort"
+)
+
+// EventSource provides the cases and events to compare. It is backed by the CCD database or by an exported file.
+// The database queries and the data store requests are cancelled when the context is done.
+type EventSource interface {
+	findCasesByJurisdictionInImpactPeriod(ctx context.Context, caseIds []string,
+		comparison Comparison) ([]CaseDataEntity, error)
+	findCasesByEventsInImpactPeriod(ctx context.Context, comparison Comparison, lastCaseId int64,
+		limit int) ([]string, error)
+	// findSampleFrame pages through the cases selected by findCasesByEventsInImpactPeriod with their case type and
+	// number of matching events in the period.
+	findSampleFrame(ctx context.Context, comparison Comparison, lastCaseId int64,
+		limit int) ([]SampleFrameEntity, error)
+	findCaseIdsByReferences(ctx context.Context, references []string) (map[string]string, error)
+	// streamEventsInImpactPeriod hands the events selected by findCasesByJurisdictionInImpactPeriod to handleEvent
+	// one at a time, in case and event order.
+	streamEventsInImpactPeriod(ctx context.Context, caseIds []string, comparison Comparison,
+		handleEvent func(event CaseDataEntity) error) error
+	// findWatchedFieldChanges returns the changes of the fields between consecutive events selected by
+	// findCasesByJurisdictionInImpactPeriod, in case and event order.
+	findWatchedFieldChanges(ctx context.Context, caseIds []string, comparison Comparison,
+		fields []string) ([]WatchedFieldChangeEntity, error)
+	// findCaseTypeStatistics counts the cases with more than one event in the comparison period by jurisdiction and
+	// case type, ignoring the jurisdiction and case type of the comparison.
+	findCaseTypeStatistics(ctx context.Context, comparison Comparison,
+		filter DiscoveryFilter) ([]CaseTypeStatisticsEntity, error)
+	// findLatestEvent returns the latest event of the jurisdiction and case types of the comparison created up to the
+	// end of its period, or nil when there is none.
+	findLatestEvent(ctx context.Context, comparison Comparison) (*Watermark, error)
+}
+
+// streamSortedEvents hands the events to handleEvent in case and event order.
+func streamSortedEvents(events []CaseDataEntity, handleEvent func(event CaseDataEntity) error) error {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].CaseId != events[j].CaseId {
+			return events[i].CaseId < events[j].CaseId
+		}
+		return events[i].EventId < events[j].EventId
+	})
+
+	for _, event := range events {
+		if err := handleEvent(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,425 @@
+package domain
+
+import (
+	"bufio"
+	"bytes"
+	"ccd-comparator-data-diff-rapid/comparator"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var eventTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// fileEventSource serves the cases and events of an NDJSON or CSV export whose columns are named after the
+// CaseDataEntity db tags. The export is loaded into memory once and queried like the database.
+type fileEventSource struct {
+	events []CaseDataEntity
+}
+
+// NewFileEventSource loads the export at filePath. Files ending in .csv (or .csv.gz) are read as CSV with a header
+// row, any other file as NDJSON. Gzip compressed files are detected from their content.
+func NewFileEventSource(filePath string) (EventSource, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the event file")
+	}
+	defer file.Close()
+
+	reader, err := newDecompressingReader(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the event file %s", filePath)
+	}
+
+	var events []CaseDataEntity
+	if strings.EqualFold(filepath.Ext(strings.TrimSuffix(filePath, ".gz")), ".csv") {
+		events, err = readCsvEvents(reader)
+	} else {
+		events, err = readNdjsonEvents(reader)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the event file %s", filePath)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].CaseId != events[j].CaseId {
+			return events[i].CaseId < events[j].CaseId
+		}
+		return events[i].EventId < events[j].EventId
+	})
+
+	return &fileEventSource{events: events}, nil
+}
+
+func newDecompressingReader(file io.Reader) (io.Reader, error) {
+	reader := bufio.NewReader(file)
+	magic, err := reader.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(reader)
+	}
+	return reader, nil
+}
+
+func readNdjsonEvents(reader io.Reader) ([]CaseDataEntity, error) {
+	var events []CaseDataEntity
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var row map[string]json.RawMessage
+		if err := json.Unmarshal(data, &row); err != nil {
+			return nil, errors.Wrapf(err, "invalid json on line %d", line)
+		}
+
+		values := make(map[string]string, len(row))
+		for column, raw := range row {
+			values[column] = rawJsonValue(raw)
+		}
+
+		event, err := newCaseDataEntity(values)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid event on line %d", line)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// rawJsonValue returns strings unquoted and any other value, such as an event_data object, as its json text.
+func rawJsonValue(raw json.RawMessage) string {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+func readCsvEvents(reader io.Reader) ([]CaseDataEntity, error) {
+	csvReader := csv.NewReader(reader)
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid csv header")
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	var events []CaseDataEntity
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]string, len(header))
+		for i, column := range header {
+			values[column] = record[i]
+		}
+
+		event, err := newCaseDataEntity(values)
+		if err != nil {
+			line, _ := csvReader.FieldPos(0)
+			return nil, errors.Wrapf(err, "invalid event on line %d", line)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func newCaseDataEntity(values map[string]string) (CaseDataEntity, error) {
+	var event CaseDataEntity
+	var err error
+
+	parseInt := func(column string, required bool) int64 {
+		value := strings.TrimSpace(values[column])
+		if err != nil || (value == "" && !required) {
+			return 0
+		}
+		parsed, parseErr := strconv.ParseInt(value, 10, 64)
+		if parseErr != nil {
+			err = fmt.Errorf("column %s: %w", column, parseErr)
+		}
+		return parsed
+	}
+	parseTime := func(column string, required bool) time.Time {
+		value := strings.TrimSpace(values[column])
+		if err != nil || (value == "" && !required) {
+			return time.Time{}
+		}
+		parsed, parseErr := parseEventTime(value)
+		if parseErr != nil {
+			err = fmt.Errorf("column %s: %w", column, parseErr)
+		}
+		return parsed
+	}
+
+	event.CaseId = parseInt("case_id", true)
+	event.CaseDataId = parseInt("case_data_id", false)
+	event.Reference = parseInt("reference", false)
+	event.EventId = parseInt("event_id", true)
+	event.CaseCreatedDate = parseTime("case_created_date", false)
+	event.EventCreatedDate = parseTime("event_created_date", true)
+	if err != nil {
+		return CaseDataEntity{}, err
+	}
+
+	event.Jurisdiction = values["jurisdiction"]
+	event.CaseTypeId = values["case_type_id"]
+	event.EventName = values["event_name"]
+	event.EventData = values["event_data"]
+	event.UserId = values["user_id"]
+	event.StateId = values["state_id"]
+	event.StateName = values["state_name"]
+	event.Summary = values["summary"]
+	event.Description = values["description"]
+	event.UserFirstName = values["user_first_name"]
+	event.UserLastName = values["user_last_name"]
+	event.ProxiedBy = values["proxied_by"]
+	if event.EventData == "" {
+		event.EventData = "{}"
+	}
+	if event.CaseDataId == 0 {
+		event.CaseDataId = event.CaseId
+	}
+	if event.Reference == 0 {
+		event.Reference = event.CaseId
+	}
+
+	return event, nil
+}
+
+func parseEventTime(value string) (time.Time, error) {
+	for _, layout := range eventTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported time format '%s'", value)
+}
+
+func (f fileEventSource) findCasesByEventsInImpactPeriod(ctx context.Context, comparison Comparison, lastCaseId int64,
+	limit int) ([]string, error) {
+	frame, err := f.findSampleFrame(ctx, comparison, lastCaseId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	caseIds := make([]string, 0, len(frame))
+	for _, entity := range frame {
+		caseIds = append(caseIds, entity.CaseId)
+	}
+	return caseIds, nil
+}
+
+func (f fileEventSource) findSampleFrame(_ context.Context, comparison Comparison, lastCaseId int64,
+	limit int) ([]SampleFrameEntity, error) {
+	var caseTypeIds []string
+	if comparison.CaseTypeId != "" {
+		caseTypeIds = strings.Split(comparison.CaseTypeId, ",")
+	}
+	filter := comparison.EventFilter
+	isFiltered := len(filter.IncludeEvents) > 0 || len(filter.ExcludeEvents) > 0 ||
+		len(filter.IncludeUsers) > 0 || len(filter.ExcludeUsers) > 0
+
+	var frame []SampleFrameEntity
+	eventCount := 0
+	for i, event := range f.events {
+		if event.CaseId > lastCaseId && event.Jurisdiction == comparison.Jurisdiction &&
+			(caseTypeIds == nil || slices.Contains(caseTypeIds, event.CaseTypeId)) &&
+			comparison.includesEvent(event.EventCreatedDate, event.EventId) &&
+			comparator.IsEventIncluded(filter, event.EventName, event.UserId) {
+			eventCount++
+		}
+
+		isLastEventOfCase := i == len(f.events)-1 || f.events[i+1].CaseId != event.CaseId
+		if !isLastEventOfCase {
+			continue
+		}
+		if eventCount > 1 || ((isFiltered || comparison.Incremental) && eventCount > 0) {
+			frame = append(frame, SampleFrameEntity{
+				CaseId:     strconv.FormatInt(event.CaseId, 10),
+				CaseTypeId: event.CaseTypeId,
+				EventCount: eventCount,
+			})
+			if len(frame) == limit {
+				break
+			}
+		}
+		eventCount = 0
+	}
+
+	return frame, nil
+}
+
+func (f fileEventSource) findLatestEvent(_ context.Context, comparison Comparison) (*Watermark, error) {
+	var caseTypeIds []string
+	if comparison.CaseTypeId != "" {
+		caseTypeIds = strings.Split(comparison.CaseTypeId, ",")
+	}
+
+	var latest *Watermark
+	for _, event := range f.events {
+		if event.Jurisdiction != comparison.Jurisdiction ||
+			(caseTypeIds != nil && !slices.Contains(caseTypeIds, event.CaseTypeId)) ||
+			event.EventCreatedDate.After(comparison.SearchPeriodEndTime) {
+			continue
+		}
+		position := Watermark{Jurisdiction: comparison.Jurisdiction, CaseTypeId: comparison.CaseTypeId,
+			LastEventCreatedDate: event.EventCreatedDate, LastEventId: event.EventId}
+		if latest == nil || latest.precedes(position) {
+			latest = &position
+		}
+	}
+
+	return latest, nil
+}
+
+func (f fileEventSource) findCaseTypeStatistics(_ context.Context, comparison Comparison,
+	filter DiscoveryFilter) ([]CaseTypeStatisticsEntity, error) {
+	var statistics []CaseTypeStatisticsEntity
+	indexes := make(map[[2]string]int)
+
+	var eventCount, eventDataBytes int64
+	for i, event := range f.events {
+		if comparison.includesEvent(event.EventCreatedDate, event.EventId) &&
+			filter.isIncluded(event.Jurisdiction, event.CaseTypeId) {
+			eventCount++
+			eventDataBytes += int64(len(event.EventData))
+		}
+
+		isLastEventOfCase := i == len(f.events)-1 || f.events[i+1].CaseId != event.CaseId
+		if !isLastEventOfCase {
+			continue
+		}
+		if eventCount > 1 {
+			key := [2]string{event.Jurisdiction, event.CaseTypeId}
+			index, found := indexes[key]
+			if !found {
+				index = len(statistics)
+				indexes[key] = index
+				statistics = append(statistics, CaseTypeStatisticsEntity{Jurisdiction: key[0], CaseTypeId: key[1]})
+			}
+			statistics[index].CaseCount++
+			statistics[index].EventCount += eventCount
+			statistics[index].EventDataBytes += eventDataBytes
+		}
+		eventCount, eventDataBytes = 0, 0
+	}
+
+	sort.Slice(statistics, func(i, j int) bool {
+		if statistics[i].Jurisdiction != statistics[j].Jurisdiction {
+			return statistics[i].Jurisdiction < statistics[j].Jurisdiction
+		}
+		return statistics[i].CaseTypeId < statistics[j].CaseTypeId
+	})
+	return statistics, nil
+}
+
+func (f fileEventSource) findCasesByJurisdictionInImpactPeriod(_ context.Context, caseIds []string,
+	comparison Comparison) ([]CaseDataEntity, error) {
+	requestedIds := make(map[int64]bool, len(caseIds))
+	for _, caseId := range caseIds {
+		id, err := strconv.ParseInt(strings.TrimSpace(caseId), 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid case id %s", caseId)
+		}
+		requestedIds[id] = true
+	}
+
+	var requestedEvents []CaseDataEntity
+	for _, event := range f.events {
+		if requestedIds[event.CaseId] {
+			requestedEvents = append(requestedEvents, event)
+		}
+	}
+
+	return selectEventsInImpactPeriod(requestedEvents, comparison), nil
+}
+
+func (f fileEventSource) streamEventsInImpactPeriod(ctx context.Context, caseIds []string, comparison Comparison,
+	handleEvent func(event CaseDataEntity) error) error {
+	caseData, err := f.findCasesByJurisdictionInImpactPeriod(ctx, caseIds, comparison)
+	if err != nil {
+		return err
+	}
+	return streamSortedEvents(caseData, handleEvent)
+}
+
+func (f fileEventSource) findWatchedFieldChanges(ctx context.Context, caseIds []string, comparison Comparison,
+	fields []string) ([]WatchedFieldChangeEntity, error) {
+	return streamWatchedFieldChanges(ctx, f, caseIds, comparison, fields)
+}
+
+func (f fileEventSource) findCaseIdsByReferences(_ context.Context, references []string) (map[string]string, error) {
+	caseIds := make(map[string]string, len(references))
+	for _, event := range f.events {
+		reference := strconv.FormatInt(event.Reference, 10)
+		if slices.Contains(references, reference) {
+			caseIds[reference] = strconv.FormatInt(event.CaseId, 10)
+		}
+	}
+
+	return caseIds, nil
+}
+
+// selectEventsInImpactPeriod keeps the events created within the comparison period and the latest event of each case
+// before the period, the same way findCasesByJurisdictionInImpactPeriod selects them from the database.
+func selectEventsInImpactPeriod(events []CaseDataEntity, comparison Comparison) []CaseDataEntity {
+	var caseData []CaseDataEntity
+	baselines := make(map[int64]CaseDataEntity)
+	for _, event := range events {
+		if comparison.includesEvent(event.EventCreatedDate, event.EventId) {
+			caseData = append(caseData, event)
+		} else if comparison.precedesEvent(event.EventCreatedDate, event.EventId) {
+			baseline, found := baselines[event.CaseId]
+			if !found || event.EventCreatedDate.After(baseline.EventCreatedDate) ||
+				(event.EventCreatedDate.Equal(baseline.EventCreatedDate) && event.EventId > baseline.EventId) {
+				baselines[event.CaseId] = event
+			}
+		}
+	}
+	for _, baseline := range baselines {
+		caseData = append(caseData, baseline)
+	}
+
+	return caseData
+}
@@ -0,0 +1,201 @@
+package domain
+
+import (
+	"bufio"
+	"ccd-comparator-data-diff-rapid/comparator"
+	"ccd-comparator-data-diff-rapid/config"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const ndjsonEvents = `{"case_id": 1, "reference": 1234567890123452, "jurisdiction": "J1", "case_type_id": "CT1", "event_id": 10, "event_name": "createCase", "user_id": "user1", "event_created_date": "2023-07-01T10:00:00Z", "event_data": {"name": "a"}}
+{"case_id": 1, "reference": 1234567890123452, "jurisdiction": "J1", "case_type_id": "CT1", "event_id": 11, "event_name": "updateCase", "user_id": "user1", "event_created_date": "2023-07-15 10:00:00", "event_data": {"name": "b"}}
+{"case_id": 1, "reference": 1234567890123452, "jurisdiction": "J1", "case_type_id": "CT1", "event_id": 12, "event_name": "updateRespondent", "user_id": "user2", "event_created_date": "2023-08-01T10:00:00.000", "event_data": {"name": "c"}}
+
+{"case_id": 2, "reference": 1698765432109877, "jurisdiction": "J1", "case_type_id": "CT2", "event_id": 20, "event_name": "createCase", "user_id": "user1", "event_created_date": "2023-08-01T09:00:00Z", "event_data": "{\"name\": \"x\"}"}
+{"case_id": 2, "reference": 1698765432109877, "jurisdiction": "J1", "case_type_id": "CT2", "event_id": 21, "event_name": "updateCase", "user_id": "user1", "event_created_date": "2023-08-01T11:00:00Z", "event_data": "{\"name\": \"y\"}"}
+{"case_id": 3, "jurisdiction": "J2", "case_type_id": "CT1", "event_id": 30, "event_name": "createCase", "event_created_date": "2023-08-01T09:00:00Z", "event_data": null}
+`
+
+const csvEvents = "case_id,reference,jurisdiction,case_type_id,event_id,event_name,user_id,event_created_date,event_data\n" +
+	"5,1111222233334444,J1,CT1,50,createCase,user1,2023-08-01 09:00:00+00,\"{\"\"name\"\": \"\"a\"\"}\"\n" +
+	"5,1111222233334444,J1,CT1,51,updateCase,user1,2023-08-01 10:00:00+00,\"{\"\"name\"\": \"\"b\"\"}\"\n"
+
+func newTestComparison() Comparison {
+	return Comparison{
+		Jurisdiction:        "J1",
+		StartTime:           time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC),
+		SearchPeriodEndTime: time.Date(2023, 8, 2, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func writeGzipFile(t *testing.T, filePath, content string) {
+	file, err := os.Create(filePath)
+	assert.NoError(t, err)
+	writer := gzip.NewWriter(file)
+	_, err = writer.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+	assert.NoError(t, file.Close())
+}
+
+func TestFileEventSource_FindCasesByEventsInImpactPeriod(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "events.ndjson.gz")
+	writeGzipFile(t, filePath, ndjsonEvents)
+
+	source, err := NewFileEventSource(filePath)
+	assert.NoError(t, err)
+
+	c := newTestComparison()
+	caseIds, err := source.findCasesByEventsInImpactPeriod(context.Background(), c, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2"}, caseIds)
+
+	c.EventFilter = config.EventFilter{IncludeEvents: []string{"updateRespondent"}}
+	caseIds, err = source.findCasesByEventsInImpactPeriod(context.Background(), c, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, caseIds)
+
+	c.EventFilter = config.EventFilter{ExcludeUsers: []string{"nobody"}}
+	caseIds, err = source.findCasesByEventsInImpactPeriod(context.Background(), c, 0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, caseIds)
+	caseIds, err = source.findCasesByEventsInImpactPeriod(context.Background(), c, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2"}, caseIds)
+}
+
+func TestFileEventSource_FindSampleFrame(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "events.ndjson")
+	assert.NoError(t, os.WriteFile(filePath, []byte(ndjsonEvents), 0600))
+
+	source, err := NewFileEventSource(filePath)
+	assert.NoError(t, err)
+
+	c := newTestComparison()
+	c.EventFilter = config.EventFilter{ExcludeUsers: []string{"nobody"}}
+	frame, err := source.findSampleFrame(context.Background(), c, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []SampleFrameEntity{
+		{CaseId: "1", CaseTypeId: "CT1", EventCount: 1},
+		{CaseId: "2", CaseTypeId: "CT2", EventCount: 2},
+	}, frame)
+}
+
+func TestFileEventSource_FindCasesByJurisdictionInImpactPeriod(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "events.ndjson")
+	assert.NoError(t, os.WriteFile(filePath, []byte(ndjsonEvents), 0600))
+
+	source, err := NewFileEventSource(filePath)
+	assert.NoError(t, err)
+
+	cases, err := source.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"1", "3"}, newTestComparison())
+	assert.NoError(t, err)
+
+	var eventIds []int64
+	for _, caseData := range cases {
+		eventIds = append(eventIds, caseData.EventId)
+	}
+	assert.ElementsMatch(t, []int64{12, 11, 30}, eventIds)
+	assert.Equal(t, `{"name": "c"}`, cases[0].EventData)
+	assert.Equal(t, int64(1234567890123452), cases[0].Reference)
+
+	caseIds, err := source.findCaseIdsByReferences(context.Background(), []string{"1234567890123452", "1111222233334444"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"1234567890123452": "1"}, caseIds)
+}
+
+func TestFileEventSource_ScansAfterTheWatermark(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "events.ndjson")
+	assert.NoError(t, os.WriteFile(filePath, []byte(ndjsonEvents), 0600))
+
+	source, err := NewFileEventSource(filePath)
+	assert.NoError(t, err)
+
+	latest, err := source.findLatestEvent(context.Background(), newTestComparison())
+	assert.NoError(t, err)
+	assert.Equal(t, &Watermark{Jurisdiction: "J1", LastEventCreatedDate: time.Date(2023, 8, 1, 11, 0, 0, 0, time.UTC),
+		LastEventId: 21}, latest)
+
+	c := newTestComparison()
+	c.StartTime = time.Date(2023, 8, 1, 9, 0, 0, 0, time.UTC)
+	c.AfterEventId = 20
+	c.SearchPeriodEndTime = time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	c.UntilEventId = 12
+	c.Incremental = true
+
+	caseIds, err := source.findCasesByEventsInImpactPeriod(context.Background(), c, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, caseIds)
+
+	cases, err := source.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"2"}, c)
+	assert.NoError(t, err)
+	assert.Len(t, cases, 1)
+	assert.Equal(t, int64(20), cases[0].EventId)
+}
+
+func TestFileEventSource_ReadsCsv(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "events.csv")
+	assert.NoError(t, os.WriteFile(filePath, []byte(csvEvents), 0600))
+
+	source, err := NewFileEventSource(filePath)
+	assert.NoError(t, err)
+
+	cases, err := source.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"5"}, newTestComparison())
+	assert.NoError(t, err)
+	assert.Len(t, cases, 2)
+	assert.Equal(t, `{"name": "b"}`, cases[1].EventData)
+	assert.Equal(t, time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC), cases[1].EventCreatedDate.UTC())
+}
+
+func TestFileEventSource_InvalidRow(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "events.ndjson")
+	assert.NoError(t, os.WriteFile(filePath, []byte(`{"case_id": 1, "event_id": "x"}`), 0600))
+
+	_, err := NewFileEventSource(filePath)
+	assert.ErrorContains(t, err, "invalid event on line 1: column event_id")
+}
+
+func TestService_CompareEventsInImpactPeriodOffline(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	eventFilePath := filepath.Join(t.TempDir(), "events.ndjson")
+	reportFilePath := filepath.Join(t.TempDir(), "report.ndjson")
+	assert.NoError(t, os.WriteFile(eventFilePath, []byte(ndjsonEvents), 0600))
+
+	eventSource, err := NewFileEventSource(eventFilePath)
+	assert.NoError(t, err)
+	saveRepo, err := NewFileSaveRepository(reportFilePath)
+	assert.NoError(t, err)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+	service := NewService(cfg, &enabledRuleList, nil, eventSource, saveRepo, nil, nil)
+
+	service.CompareEventsInImpactPeriod(context.Background(), newTestComparison())
+
+	file, err := os.Open(reportFilePath)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	var entities []comparator.EventDataReportEntity
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entity comparator.EventDataReportEntity
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entity))
+		entities = append(entities, entity)
+	}
+
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "1698765432109877", entities[0].Reference)
+	assert.Equal(t, int64(21), entities[0].EventId)
+	assert.Equal(t, ".name", entities[0].FieldName)
+	assert.Equal(t, "x", entities[0].OldRecord)
+	assert.Equal(t, "y", entities[0].NewRecord)
+}
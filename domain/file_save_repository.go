@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"bufio"
+	"ccd-comparator-data-diff-rapid/comparator"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"sync"
+)
+
+// fileSaveRepository appends the report rows to an NDJSON file instead of the report table.
+type fileSaveRepository struct {
+	filePath string
+	mutex    sync.Mutex
+}
+
+// NewFileSaveRepository creates, or truncates, the report file at filePath.
+func NewFileSaveRepository(filePath string) (SaveRepository, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the report file")
+	}
+	if err := file.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to create the report file")
+	}
+
+	return &fileSaveRepository{filePath: filePath}, nil
+}
+
+func (f *fileSaveRepository) saveAllEventDataReport(_ context.Context, _ int, _ string,
+	eventDataReportEntities []comparator.EventDataReportEntity) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	file, err := os.OpenFile(f.filePath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the report file")
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entity := range eventDataReportEntities {
+		if err := encoder.Encode(entity); err != nil {
+			return errors.Wrap(err, "failed to write the report file")
+		}
+	}
+
+	return errors.Wrap(writer.Flush(), "failed to write the report file")
+}
+
+func (f *fileSaveRepository) findWatermark(_ context.Context, _, _, _ string) (*Watermark, error) {
+	return nil, errors.New("incremental scans keep their watermark in the report database")
+}
+
+func (f *fileSaveRepository) saveWatermark(_ context.Context, _ string, _ Watermark) error {
+	return errors.New("incremental scans keep their watermark in the report database")
+}
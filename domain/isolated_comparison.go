@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/comparator"
+	"ccd-comparator-data-diff-rapid/config"
+	"context"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const defaultOversizedChunkEventCount = 500
+
+// isolatedComparison analyses and saves the changes of an oversized case every chunk of events. The rules compare
+// the changes of a chunk with up to a chunk of the earlier changes of their field, and case rules are not applied.
+// The last event of every saved chunk is checkpointed, and kept in w, so that a resumed run or a retry of the failed
+// case starts after it.
+type isolatedComparison struct {
+	service            Service
+	w                  comparisonWork
+	oversized          oversizedCase
+	stream             *comparator.CaseEventStream
+	chunkSize          int
+	lastEventId        int64
+	replayedEventCount int
+	chunkEventCount    int
+	fieldChangeCount   int
+	violationCount     int
+	savedCount         int
+	hasViolation       bool
+	totals             *comparisonTotals
+}
+
+func newIsolatedComparison(s Service, w comparisonWork, oversized oversizedCase) *isolatedComparison {
+	chunkSize := s.configuration.OversizedChunkEventCount
+	if chunkSize <= 0 {
+		chunkSize = defaultOversizedChunkEventCount
+	}
+	totals := newComparisonTotals()
+	totals.cases = 1
+	return &isolatedComparison{
+		service:   s,
+		w:         w,
+		oversized: oversized,
+		stream:    comparator.NewCaseEventStream(oversized.reference),
+		chunkSize: chunkSize,
+		totals:    totals,
+	}
+}
+
+// add diffs the event with the previous one, and flushes the chunk once it is full. Events up to the last one added
+// are skipped, as they are read again when the read is retried.
+func (i *isolatedComparison) add(ctx context.Context, event CaseDataEntity) error {
+	if event.EventId <= i.lastEventId {
+		return nil
+	}
+	i.stream.Add(newEventDetails(event))
+	i.lastEventId = event.EventId
+	if event.EventId <= i.w.savedEventId {
+		i.replay(event.EventId)
+		return nil
+	}
+	i.totals.events++
+	i.chunkEventCount++
+	if i.chunkEventCount < i.chunkSize {
+		return nil
+	}
+	return i.flush(ctx, false)
+}
+
+// replay releases the changes of the events whose report an earlier attempt saved at the end of each of their
+// chunks, without analysing them, so that the next chunks are compared with the same earlier changes.
+func (i *isolatedComparison) replay(eventId int64) {
+	i.replayedEventCount++
+	if i.replayedEventCount%i.chunkSize == 0 || eventId == i.w.savedEventId {
+		i.stream.Flush(i.chunkSize)
+	}
+}
+
+// flush analyses the changes of the chunk, saves their report rows and releases them. The last chunk also records
+// that the case rules were not applied.
+func (i *isolatedComparison) flush(ctx context.Context, last bool) error {
+	i.chunkEventCount = 0
+	compareStart := time.Now()
+	changes, changesWithContext := i.stream.Flush(i.chunkSize)
+	analyzeResult := comparator.NewEventChangesAnalyze(i.service.activeRules, changesWithContext).
+		AnalyzeEventFieldChanges()
+	entities, err := i.service.prepareReportEntities(analyzeResult, changes)
+	if err != nil {
+		return err
+	}
+	if last && i.service.configuration.Report.Enabled && i.service.activeCaseRules != nil &&
+		len(*i.service.activeCaseRules) > 0 {
+		entities = append(entities, comparator.NewSkippedCaseRulesReportEntity(i.oversized.reference,
+			i.oversized.caseTypeId, fmt.Sprintf("the case was compared in isolation as %s", i.oversized.reason)))
+	}
+
+	chunkViolations := 0
+	for _, count := range analyzeResult.ViolationCounts(changes, config.EventFilter{}) {
+		chunkViolations += count
+	}
+	i.violationCount += chunkViolations
+	i.hasViolation = i.hasViolation || chunkViolations > 0
+	i.fieldChangeCount += len(changes)
+	i.totals.add(changes, analyzeResult, i.service.configuration.EventFilter)
+	i.totals.timeStage(stageCompare, compareStart)
+
+	if len(entities) == 0 || !i.service.configuration.Report.Enabled {
+		return nil
+	}
+	saveStart := time.Now()
+	if err := i.service.saveReport(ctx, i.w.transactionId, entities); err != nil {
+		return err
+	}
+	i.totals.timeStage(stageSave, saveStart)
+	i.savedCount += len(entities)
+	if !last {
+		i.saveProgress()
+	}
+	return nil
+}
+
+// saveProgress records that the report of the case is saved up to the last event added.
+func (i *isolatedComparison) saveProgress() {
+	i.w.savedEventId = i.lastEventId
+	err := i.service.checkpoints.saveProgress(i.w.comparison, i.oversized.caseId, i.lastEventId)
+	if err != nil {
+		log.Error().Msgf("tid:%s - Couldn't checkpoint the caseId: %d up to event id %d. ERROR: %s",
+			i.w.transactionId, i.oversized.caseId, i.lastEventId, err)
+	}
+}
+
+func (i *isolatedComparison) sendResult(resultChan chan<- comparisonResult) {
+	switch {
+	case i.fieldChangeCount == 0 && i.violationCount == 0 && i.savedCount == 0:
+		sendResult(resultChan, i.w.transactionId, "No differences found in events for specified cases based on "+
+			"the search criteria provided")
+	case !i.service.configuration.Report.Enabled:
+		sendResult(resultChan, i.w.transactionId, "Analysis completed without saving the report. Total records in "+
+			"analyzeResult: %d. Total number of field change: %d", i.violationCount, i.fieldChangeCount)
+	default:
+		sendResult(resultChan, i.w.transactionId, "Operation completed successfully. %d report records saved",
+			i.savedCount)
+	}
+}
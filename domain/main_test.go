@@ -3,7 +3,9 @@ package domain
 import (
 	"ccd-comparator-data-diff-rapid/config"
 	"ccd-comparator-data-diff-rapid/internal/store"
+	"context"
 	"github.com/stretchr/testify/mock"
+	"strings"
 )
 
 var cfg *config.Configurations
@@ -12,7 +14,7 @@ func setUp() {
 	cfg = &config.Configurations{
 		Database: config.Database{},
 		Period: config.Period{
-			SearchWindow: 1,
+			StartTime: "2023-08-01T00:00:00.000",
 		},
 		Rule: config.Rule{
 			Active: "staticfieldchange,arrayfieldchange",
@@ -61,21 +63,70 @@ type MockDB struct {
 	mock.Mock
 }
 
-func (m *MockDB) MustBegin() store.Transaction {
-	args := m.Called()
-	return args.Get(0).(store.Transaction)
+func (m *MockDB) Begin(ctx context.Context) (store.Transaction, error) {
+	args := m.Called(ctx)
+	tx, _ := args.Get(0).(store.Transaction)
+	return tx, args.Error(1)
 }
 
-func (m *MockDB) Select(dest interface{}, query string, args ...interface{}) error {
+func (m *MockDB) Select(_ context.Context, dest interface{}, query string, args ...interface{}) error {
 	argsList := m.Called(dest, query, args)
 	return argsList.Error(0)
 }
 
+// expectTableColumns answers the information_schema lookup of the columns of the table once. The table can be
+// qualified with its schema.
+func expectTableColumns(mockDB *MockDB, table string, columns ...string) {
+	if _, name, found := strings.Cut(table, "."); found {
+		table = name
+	}
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]string"),
+		mock.MatchedBy(func(query string) bool { return strings.Contains(query, "information_schema.columns") }),
+		mock.MatchedBy(func(args []interface{}) bool { return len(args) > 0 && args[0] == table })).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]string) = columns
+		}).Once()
+}
+
+func (m *MockDB) Queryx(_ context.Context, query string, args ...interface{}) (store.Rows, error) {
+	argsList := m.Called(query, args)
+	rows, _ := argsList.Get(0).(store.Rows)
+	return rows, argsList.Error(1)
+}
+
+// MockRows returns the given entities one row at a time.
+type MockRows struct {
+	entities []CaseDataEntity
+	index    int
+	closed   bool
+}
+
+func (m *MockRows) Next() bool {
+	m.index++
+	return m.index <= len(m.entities)
+}
+
+func (m *MockRows) StructScan(dest interface{}) error {
+	*dest.(*CaseDataEntity) = m.entities[m.index-1]
+	return nil
+}
+
+func (m *MockRows) Err() error {
+	return nil
+}
+
+func (m *MockRows) Close() error {
+	m.closed = true
+	return nil
+}
+
 type MockTransaction struct {
 	mock.Mock
 }
 
-func (m *MockTransaction) NamedExec(query string, arg interface{}) (interface{}, error) {
+func (m *MockTransaction) NamedExec(_ context.Context, query string, arg interface{}) (interface{}, error) {
 	argsList := m.Called(query, arg)
 	return argsList.Get(0), argsList.Error(1)
 }
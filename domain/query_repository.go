@@ -1,20 +1,27 @@
 package domain
 
 import (
+	"ccd-comparator-data-diff-rapid/comparator"
+	"ccd-comparator-data-diff-rapid/config"
 	"ccd-comparator-data-diff-rapid/internal/store"
+	"context"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type QueryRepository interface {
-	findCasesByJurisdictionInImpactPeriod(caseIds []string) ([]CaseDataEntity, error)
-	findCasesByEventsInImpactPeriod(comparison Comparison) ([]string, error)
+	EventSource
 }
 
 type queryRepository struct {
-	db store.DB
+	db                    store.DB
+	tableColumns          *tableColumns
+	missingColumnsWarning *sync.Once
 }
 
 type CaseDataEntity struct {
@@ -29,22 +36,113 @@ type CaseDataEntity struct {
 	EventCreatedDate time.Time `db:"event_created_date"`
 	EventData        string    `db:"event_data"`
 	UserId           string    `db:"user_id"`
+	StateId          string    `db:"state_id"`
+	EventContextEntity
+}
+
+// EventContextEntity holds the eventContextColumns of an event, which are empty when the source doesn't have them.
+type EventContextEntity struct {
+	StateName     string `db:"state_name"`
+	Summary       string `db:"summary"`
+	Description   string `db:"description"`
+	UserFirstName string `db:"user_first_name"`
+	UserLastName  string `db:"user_last_name"`
+	ProxiedBy     string `db:"proxied_by"`
+}
+
+// withEventDetails returns the details with the context of the event.
+func (e EventContextEntity) withEventDetails(details comparator.EventDetails) comparator.EventDetails {
+	details.StateName = e.StateName
+	details.Summary = e.Summary
+	details.Description = e.Description
+	details.UserFirstName = e.UserFirstName
+	details.UserLastName = e.UserLastName
+	details.ProxiedBy = e.ProxiedBy
+	return details
 }
 
 func NewQueryRepository(db store.DB) QueryRepository {
-	return &queryRepository{db: db}
+	return &queryRepository{db: db, tableColumns: newTableColumns(), missingColumnsWarning: &sync.Once{}}
 }
 
-func (r queryRepository) findCasesByEventsInImpactPeriod(comparison Comparison) ([]string, error) {
+// findCasesByEventsInImpactPeriod returns up to limit case ids greater than lastCaseId, in id order, so that callers
+// can page through the matching cases with a keyset instead of loading them all at once.
+func (r queryRepository) findCasesByEventsInImpactPeriod(ctx context.Context, comparison Comparison, lastCaseId int64,
+	limit int) ([]string, error) {
 	var caseIDs []string
-	var query string
-	var args []interface{}
 
-	query = `SELECT cd.id FROM case_data cd
+	query, args := casesByEventsQuery("cd.id", "cd.id", comparison, lastCaseId, limit)
+	err := r.db.Select(ctx, &caseIDs, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while retrieving caseIDs in findCasesByEventsInImpactPeriod()")
+	}
+
+	return caseIDs, nil
+}
+
+// findSampleFrame pages through the cases selected by findCasesByEventsInImpactPeriod like it does, together with
+// their case type and their number of matching events in the period.
+func (r queryRepository) findSampleFrame(ctx context.Context, comparison Comparison, lastCaseId int64,
+	limit int) ([]SampleFrameEntity, error) {
+	var frame []SampleFrameEntity
+
+	query, args := casesByEventsQuery(
+		"cd.id as case_id, cd.case_type_id as case_type_id, COUNT(ce.id) as event_count",
+		"cd.id, cd.case_type_id", comparison, lastCaseId, limit)
+	err := r.db.Select(ctx, &frame, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in findSampleFrame()")
+	}
+
+	return frame, nil
+}
+
+// casesByEventsQuery selects the columns of the cases with matching events in the period, grouped by groupBy.
+func casesByEventsQuery(columns, groupBy string, comparison Comparison, lastCaseId int64,
+	limit int) (string, []interface{}) {
+	caseTypeQuery, args := caseTypeConditions(comparison)
+	query := `SELECT ` + columns + ` FROM case_data cd
                     INNER JOIN case_event ce ON cd.id = ce.case_data_id
-                    WHERE cd.jurisdiction = $1`
+                    WHERE ` + caseTypeQuery
+
+	filterQuery, filterArgs := eventFilterConditions(comparison.EventFilter, len(args))
+	query += filterQuery
+	args = append(args, filterArgs...)
+
+	// A single matching event is enough to scan a filtered case as the earlier events provide the previous state
+	havingQuery := " HAVING COUNT(ce.id) > 1"
+	if filterQuery != "" || comparison.Incremental {
+		havingQuery = ""
+	}
+
+	argsCount := len(args)
+	query += ` AND ce.created_date >= $` + strconv.Itoa(argsCount+1) + `
+                        AND ce.created_date <= $` + strconv.Itoa(argsCount+2)
+	args = append(args, comparison.StartTime, comparison.SearchPeriodEndTime)
+
+	if comparison.AfterEventId != 0 || comparison.UntilEventId != 0 {
+		query += ` AND (ce.created_date > $` + strconv.Itoa(argsCount+1) + ` OR ce.id > $` +
+			strconv.Itoa(argsCount+3) + `)
+                        AND (ce.created_date < $` + strconv.Itoa(argsCount+2) + ` OR ce.id <= $` +
+			strconv.Itoa(argsCount+4) + `)`
+		args = append(args, comparison.AfterEventId, comparison.untilEventId())
+	}
+
+	argsCount = len(args)
+	query += ` AND cd.id > $` + strconv.Itoa(argsCount+1) + `
+                    GROUP BY ` + groupBy + havingQuery + `
+                    ORDER BY cd.id
+                    LIMIT $` + strconv.Itoa(argsCount+2)
 
-	args = append(args, comparison.Jurisdiction)
+	args = append(args, lastCaseId, limit)
+
+	return query, args
+}
+
+// caseTypeConditions selects the cases of the jurisdiction, $1, and of the case types of the comparison.
+func caseTypeConditions(comparison Comparison) (string, []interface{}) {
+	query := "cd.jurisdiction = $1"
+	args := []interface{}{comparison.Jurisdiction}
 
 	if comparison.CaseTypeId != "" {
 		caseTypeIds := strings.Split(comparison.CaseTypeId, ",")
@@ -56,34 +154,155 @@ func (r queryRepository) findCasesByEventsInImpactPeriod(comparison Comparison)
 		query = query[:len(query)-1] + ")"
 	}
 
-	argsCount := len(args)
-	query += ` AND ce.created_date >= $` + strconv.Itoa(argsCount+1) + `
-                        AND ce.created_date <= $` + strconv.Itoa(argsCount+2) + `
-                    GROUP BY cd.id 
-                    HAVING COUNT(ce.id) > 1
-                    ORDER BY cd.id`
+	return query, args
+}
 
-	args = append(args, comparison.StartTime, comparison.SearchPeriodEndTime)
+// findLatestEvent returns the latest event of the jurisdiction and case types created up to the end of the period,
+// or nil when there is none.
+func (r queryRepository) findLatestEvent(ctx context.Context, comparison Comparison) (*Watermark, error) {
+	var latest []Watermark
 
-	err := r.db.Select(&caseIDs, query, args...)
-	if err != nil {
-		return nil, errors.Wrap(err, "error while retrieving caseIDs in findCasesByEventsInImpactPeriod()")
+	caseTypeQuery, args := caseTypeConditions(comparison)
+	query := `SELECT ce.created_date as last_event_created_date, ce.id as last_event_id FROM case_data cd
+                    INNER JOIN case_event ce ON cd.id = ce.case_data_id
+                    WHERE ` + caseTypeQuery + ` AND ce.created_date <= $` + strconv.Itoa(len(args)+1) + `
+                    ORDER BY ce.created_date DESC, ce.id DESC
+                    LIMIT 1`
+	args = append(args, comparison.SearchPeriodEndTime)
+
+	if err := r.db.Select(ctx, &latest, query, args...); err != nil {
+		return nil, errors.Wrap(err, "error in findLatestEvent()")
+	}
+	if len(latest) == 0 {
+		return nil, nil
 	}
 
-	return caseIDs, nil
+	latest[0].Jurisdiction = comparison.Jurisdiction
+	latest[0].CaseTypeId = comparison.CaseTypeId
+	return &latest[0], nil
 }
 
-func (r queryRepository) findCasesByJurisdictionInImpactPeriod(caseIds []string) ([]CaseDataEntity, error) {
-	var caseData []CaseDataEntity
+// eventFilterConditions builds the case_event conditions of the event filter, numbering its parameters after
+// argsCount.
+func eventFilterConditions(filter config.EventFilter, argsCount int) (string, []interface{}) {
+	return arrayConditions(argsCount,
+		arrayCondition{"ce.event_id = ANY(?)", filter.IncludeEvents},
+		arrayCondition{"NOT (ce.event_id = ANY(?))", filter.ExcludeEvents},
+		arrayCondition{"ce.user_id = ANY(?)", filter.IncludeUsers},
+		arrayCondition{"NOT (ce.user_id = ANY(?))", filter.ExcludeUsers})
+}
+
+type arrayCondition struct {
+	condition string
+	values    []string
+}
+
+// arrayConditions joins the conditions with a value list, binding the list to the ? of the condition and numbering
+// the parameters after argsCount.
+func arrayConditions(argsCount int, conditions ...arrayCondition) (string, []interface{}) {
+	var query string
+	var args []interface{}
+
+	for _, condition := range conditions {
+		if len(condition.values) == 0 {
+			continue
+		}
+		args = append(args, pq.Array(condition.values))
+		query += " AND " + strings.Replace(condition.condition, "?", "$"+strconv.Itoa(argsCount+len(args)), 1)
+	}
 
-	caseIDQuery := "'" + strings.Join(caseIds, "','") + "'"
+	return query, args
+}
 
-	err := r.db.Select(&caseData, `SELECT cd.id as case_id, cd.created_date as case_created_date,
+// caseEventsInImpactPeriodQuery selects the events of the cases, $1, with the event context columns of
+// selectEventContext.
+func caseEventsInImpactPeriodQuery(eventContext string) string {
+	return `SELECT cd.id as case_id, cd.created_date as case_created_date,
 							cd.jurisdiction as jurisdiction, cd.case_type_id as case_type_id, cd.reference as reference,
 							ce.case_data_id as case_data_id, ce.id as event_id, ce.event_id as event_name, 
-							ce.user_id as user_id, ce.created_date as event_created_date, ce.data as event_data
+							ce.user_id as user_id, ce.created_date as event_created_date, ce.data as event_data,
+							ce.state_id as state_id, ` + eventContext + `
 							FROM case_data cd inner join case_event ce on cd.id = ce.case_data_id
-							WHERE cd.id IN (`+caseIDQuery+`)`)
+							WHERE cd.id = ANY($1::bigint[])
+							AND ` + impactPeriodCondition
+}
+
+// impactPeriodCondition selects the events created within the period, $2 to $3, and the latest event before it. The
+// events created at $2 up to the event id $4, and the ones created at $3 after the event id $5, are outside of it.
+const impactPeriodCondition = `((ce.created_date >= $2 AND ce.created_date <= $3
+									AND (ce.created_date > $2 OR ce.id > $4) AND (ce.created_date < $3 OR ce.id <= $5))
+								OR ce.id = (SELECT be.id FROM case_event be
+											WHERE be.case_data_id = cd.id AND be.created_date <= $2
+											AND (be.created_date < $2 OR be.id <= $4)
+											ORDER BY be.created_date DESC, be.id DESC
+											LIMIT 1))`
+
+// impactPeriodArgs binds the period of impactPeriodCondition.
+func impactPeriodArgs(comparison Comparison) []interface{} {
+	return []interface{}{comparison.StartTime, comparison.SearchPeriodEndTime, comparison.AfterEventId,
+		comparison.untilEventId()}
+}
+
+// watchedFieldChangesQuery extracts the watched paths, $6, from the events selected like in
+// caseEventsInImpactPeriodQuery and returns only the events where a value differs from the previous event.
+func watchedFieldChangesQuery(eventContext string) string {
+	return `SELECT case_id, reference, case_type_id, event_id, event_name, user_id,
+							event_created_date, previous_state_id, field_path, old_value, new_value, state_id, ` +
+		strings.Join(eventContextColumns, ", ") + `
+							FROM (SELECT cd.id as case_id, cd.reference as reference, cd.case_type_id as case_type_id,
+									ce.id as event_id, ce.event_id as event_name, ce.user_id as user_id,
+									ce.created_date as event_created_date, wf.path as field_path,
+									ce.state_id as state_id, ` + eventContext + `,
+									ce.data #> string_to_array(wf.path, '.') as new_value,
+									LAG(ce.data #> string_to_array(wf.path, '.')) OVER field_events as old_value,
+									LAG(ce.state_id) OVER field_events as previous_state_id,
+									ROW_NUMBER() OVER field_events as event_index
+								FROM case_data cd inner join case_event ce on cd.id = ce.case_data_id
+								CROSS JOIN unnest($6::text[]) as wf(path)
+								WHERE cd.id = ANY($1::bigint[])
+								AND ` + impactPeriodCondition + `
+								WINDOW field_events AS (PARTITION BY cd.id, wf.path ORDER BY ce.id)) field_changes
+							WHERE event_index > 1 AND new_value IS DISTINCT FROM old_value
+							ORDER BY case_id, event_id, field_path`
+}
+
+// selectEventContext selects the eventContextColumns case_event has, and empty values for the other ones.
+func (r queryRepository) selectEventContext(ctx context.Context) (string, error) {
+	columns, err := r.tableColumns.find(ctx, r.db, "case_event")
+	if err != nil {
+		return "", err
+	}
+
+	if missing := missingColumns(columns, eventContextColumns); len(missing) > 0 {
+		r.missingColumnsWarning.Do(func() {
+			log.Warn().Msgf("case_event doesn't have the columns %s, they are left empty in the report", missing)
+		})
+	}
+
+	selected := make([]string, 0, len(eventContextColumns))
+	for _, column := range eventContextColumns {
+		if columns[column] {
+			selected = append(selected, "COALESCE(ce."+column+", '') as "+column)
+		} else {
+			selected = append(selected, "'' as "+column)
+		}
+	}
+	return strings.Join(selected, ", "), nil
+}
+
+// findCasesByJurisdictionInImpactPeriod loads the events of the given cases created within the comparison period,
+// together with the latest event before the period as a baseline to compare the first in-period event against.
+func (r queryRepository) findCasesByJurisdictionInImpactPeriod(ctx context.Context, caseIds []string,
+	comparison Comparison) ([]CaseDataEntity, error) {
+	var caseData []CaseDataEntity
+
+	eventContext, err := r.selectEventContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in findCasesByJurisdictionInImpactPeriod()")
+	}
+
+	err = r.db.Select(ctx, &caseData, caseEventsInImpactPeriodQuery(eventContext),
+		append([]interface{}{pq.Array(caseIds)}, impactPeriodArgs(comparison)...)...)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "error in findCasesByJurisdictionInImpactPeriod()")
@@ -91,3 +310,107 @@ func (r queryRepository) findCasesByJurisdictionInImpactPeriod(caseIds []string)
 
 	return caseData, nil
 }
+
+// streamEventsInImpactPeriod selects the same events as findCasesByJurisdictionInImpactPeriod, reading them row by
+// row in case and event order.
+func (r queryRepository) streamEventsInImpactPeriod(ctx context.Context, caseIds []string, comparison Comparison,
+	handleEvent func(event CaseDataEntity) error) error {
+	eventContext, err := r.selectEventContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error in streamEventsInImpactPeriod()")
+	}
+
+	rows, err := r.db.Queryx(ctx, caseEventsInImpactPeriodQuery(eventContext)+" ORDER BY cd.id, ce.id",
+		append([]interface{}{pq.Array(caseIds)}, impactPeriodArgs(comparison)...)...)
+	if err != nil {
+		return errors.Wrap(err, "error in streamEventsInImpactPeriod()")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event CaseDataEntity
+		if err := rows.StructScan(&event); err != nil {
+			return errors.Wrap(err, "error while reading rows in streamEventsInImpactPeriod()")
+		}
+		if err := handleEvent(event); err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrap(rows.Err(), "error while reading rows in streamEventsInImpactPeriod()")
+}
+
+// findWatchedFieldChanges compares the watched fields in the database, so that only the changed values of the
+// watched fields are transferred instead of whole events.
+func (r queryRepository) findWatchedFieldChanges(ctx context.Context, caseIds []string, comparison Comparison,
+	fields []string) ([]WatchedFieldChangeEntity, error) {
+	var changes []WatchedFieldChangeEntity
+
+	eventContext, err := r.selectEventContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in findWatchedFieldChanges()")
+	}
+
+	args := append([]interface{}{pq.Array(caseIds)}, impactPeriodArgs(comparison)...)
+	err = r.db.Select(ctx, &changes, watchedFieldChangesQuery(eventContext), append(args, pq.Array(fields))...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in findWatchedFieldChanges()")
+	}
+
+	return changes, nil
+}
+
+// findCaseTypeStatistics counts the cases with more than one event in the period by jurisdiction and case type,
+// together with their events and the size of their event data.
+func (r queryRepository) findCaseTypeStatistics(ctx context.Context, comparison Comparison,
+	filter DiscoveryFilter) ([]CaseTypeStatisticsEntity, error) {
+	var statistics []CaseTypeStatisticsEntity
+
+	filterQuery, filterArgs := arrayConditions(2,
+		arrayCondition{"cd.jurisdiction = ANY(?)", filter.IncludeJurisdictions},
+		arrayCondition{"NOT (cd.jurisdiction = ANY(?))", filter.ExcludeJurisdictions},
+		arrayCondition{"cd.case_type_id = ANY(?)", filter.IncludeCaseTypes},
+		arrayCondition{"NOT (cd.case_type_id = ANY(?))", filter.ExcludeCaseTypes})
+
+	query := `SELECT jurisdiction, case_type_id, COUNT(*) as case_count, SUM(event_count) as event_count,
+                    SUM(event_data_bytes) as event_data_bytes
+                    FROM (SELECT cd.jurisdiction as jurisdiction, cd.case_type_id as case_type_id,
+                            COUNT(ce.id) as event_count, SUM(octet_length(ce.data::text)) as event_data_bytes
+                        FROM case_data cd
+                        INNER JOIN case_event ce ON cd.id = ce.case_data_id
+                        WHERE ce.created_date >= $1 AND ce.created_date <= $2` + filterQuery + `
+                        GROUP BY cd.id, cd.jurisdiction, cd.case_type_id
+                        HAVING COUNT(ce.id) > 1) cases
+                    GROUP BY jurisdiction, case_type_id
+                    ORDER BY jurisdiction, case_type_id`
+
+	args := append([]interface{}{comparison.StartTime, comparison.SearchPeriodEndTime}, filterArgs...)
+	if err := r.db.Select(ctx, &statistics, query, args...); err != nil {
+		return nil, errors.Wrap(err, "error in findCaseTypeStatistics()")
+	}
+
+	return statistics, nil
+}
+
+type caseReferenceEntity struct {
+	CaseId    string `db:"case_id"`
+	Reference string `db:"reference"`
+}
+
+// findCaseIdsByReferences returns the case_data id of every known case reference, keyed by reference.
+func (r queryRepository) findCaseIdsByReferences(ctx context.Context, references []string) (map[string]string, error) {
+	var entities []caseReferenceEntity
+
+	err := r.db.Select(ctx, &entities, `SELECT cd.id as case_id, cd.reference as reference FROM case_data cd
+							WHERE cd.reference = ANY($1::bigint[])`, pq.Array(references))
+	if err != nil {
+		return nil, errors.Wrap(err, "error in findCaseIdsByReferences()")
+	}
+
+	caseIds := make(map[string]string, len(entities))
+	for _, entity := range entities {
+		caseIds[entity.Reference] = entity.CaseId
+	}
+
+	return caseIds, nil
+}
@@ -1,15 +1,21 @@
 package domain
 
 import (
+	"ccd-comparator-data-diff-rapid/config"
+	"context"
+	"database/sql"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"math"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestFindCasesByJurisdictionInImpactPeriod(t *testing.T) {
 	mockDB := new(MockDB)
+	expectTableColumns(mockDB, "case_event", eventContextColumns...)
 	queryRepo := NewQueryRepository(mockDB)
 
 	expectedCases := []CaseDataEntity{
@@ -41,7 +47,7 @@ func TestFindCasesByJurisdictionInImpactPeriod(t *testing.T) {
 			*casesPtr = expectedCases
 		})
 
-	cases, err := queryRepo.findCasesByJurisdictionInImpactPeriod([]string{"1"})
+	cases, err := queryRepo.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"1"}, Comparison{})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, cases)
@@ -82,7 +88,7 @@ func TestFindCasesByEventsInImpactPeriod(t *testing.T) {
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
 	}
-	cases, err := queryRepo.findCasesByEventsInImpactPeriod(c)
+	cases, err := queryRepo.findCasesByEventsInImpactPeriod(context.Background(), c, 0, 100)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, cases)
@@ -94,6 +100,7 @@ func TestFindCasesByEventsInImpactPeriod(t *testing.T) {
 
 func TestFindCasesByJurisdictionInImpactPeriodReturnError(t *testing.T) {
 	mockDB := new(MockDB)
+	expectTableColumns(mockDB, "case_event", eventContextColumns...)
 	queryRepo := NewQueryRepository(mockDB)
 
 	expectedError := errors.New("some error")
@@ -104,7 +111,7 @@ func TestFindCasesByJurisdictionInImpactPeriodReturnError(t *testing.T) {
 		mock.Anything).
 		Return(expectedError)
 
-	cases, err := queryRepo.findCasesByJurisdictionInImpactPeriod([]string{"1"})
+	cases, err := queryRepo.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"1"}, Comparison{})
 
 	unwrappedErr := errors.Cause(err)
 
@@ -138,7 +145,7 @@ func TestTestFindCasesByEventsInImpactPeriodReturnError(t *testing.T) {
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
 	}
-	cases, err := queryRepo.findCasesByEventsInImpactPeriod(c)
+	cases, err := queryRepo.findCasesByEventsInImpactPeriod(context.Background(), c, 0, 100)
 
 	unwrappedErr := errors.Cause(err)
 
@@ -148,3 +155,274 @@ func TestTestFindCasesByEventsInImpactPeriodReturnError(t *testing.T) {
 
 	mockDB.AssertExpectations(t)
 }
+
+func TestFindCaseIdsByReferences(t *testing.T) {
+	mockDB := new(MockDB)
+	queryRepo := NewQueryRepository(mockDB)
+
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]domain.caseReferenceEntity"),
+		mock.MatchedBy(func(query string) bool { return strings.Contains(query, "= ANY($1::bigint[])") }),
+		mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			entities := args.Get(0).(*[]caseReferenceEntity)
+			*entities = []caseReferenceEntity{{CaseId: "7", Reference: "1234567890123452"}}
+		})
+
+	caseIds, err := queryRepo.findCaseIdsByReferences(context.Background(), []string{"1234567890123452", "1698765432109877"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"1234567890123452": "7"}, caseIds)
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestFindCasesByJurisdictionInImpactPeriodBindsPeriodWithBaseline(t *testing.T) {
+	mockDB := new(MockDB)
+	expectTableColumns(mockDB, "case_event", eventContextColumns...)
+	queryRepo := NewQueryRepository(mockDB)
+
+	startTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	endTime := startTime.Add(24 * time.Hour)
+
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]domain.CaseDataEntity"),
+		mock.MatchedBy(func(query string) bool {
+			return strings.Contains(query, "ce.created_date >= $2 AND ce.created_date <= $3") &&
+				strings.Contains(query, "be.created_date < $2")
+		}),
+		mock.MatchedBy(func(args []interface{}) bool {
+			return len(args) == 5 && args[1] == startTime && args[2] == endTime && args[3] == int64(0) &&
+				args[4] == int64(math.MaxInt64)
+		})).
+		Return(nil)
+
+	_, err := queryRepo.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"1"}, Comparison{
+		StartTime:           startTime,
+		SearchPeriodEndTime: endTime,
+	})
+
+	assert.NoError(t, err)
+	mockDB.AssertExpectations(t)
+}
+
+func TestFindCasesByEventsInImpactPeriodAppliesEventFilter(t *testing.T) {
+	mockDB := new(MockDB)
+	queryRepo := NewQueryRepository(mockDB)
+
+	c := Comparison{
+		Jurisdiction: "TestJurisdiction",
+		EventFilter: config.EventFilter{
+			IncludeEvents: []string{"updateRespondent"},
+			ExcludeUsers:  []string{"system"},
+		},
+	}
+
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]string"),
+		mock.MatchedBy(func(query string) bool {
+			return strings.Contains(query, "AND ce.event_id = ANY($2) AND NOT (ce.user_id = ANY($3))") &&
+				strings.Contains(query, "ce.created_date >= $4") &&
+				!strings.Contains(query, "HAVING")
+		}),
+		mock.MatchedBy(func(args []interface{}) bool { return len(args) == 7 })).
+		Return(nil)
+
+	_, err := queryRepo.findCasesByEventsInImpactPeriod(context.Background(), c, 0, 10)
+
+	assert.NoError(t, err)
+	mockDB.AssertExpectations(t)
+}
+
+func TestFindCasesByEventsInImpactPeriodStartsAfterTheWatermark(t *testing.T) {
+	mockDB := new(MockDB)
+	queryRepo := NewQueryRepository(mockDB)
+
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]string"),
+		mock.MatchedBy(func(query string) bool {
+			return strings.Contains(query, "AND (ce.created_date > $2 OR ce.id > $4)") &&
+				strings.Contains(query, "AND (ce.created_date < $3 OR ce.id <= $5)") &&
+				strings.Contains(query, "AND cd.id > $6") &&
+				!strings.Contains(query, "HAVING")
+		}),
+		mock.MatchedBy(func(args []interface{}) bool {
+			return len(args) == 7 && args[3] == int64(100) && args[4] == int64(200)
+		})).
+		Return(nil)
+
+	_, err := queryRepo.findCasesByEventsInImpactPeriod(context.Background(), Comparison{Jurisdiction: "TestJurisdiction",
+		AfterEventId: 100, UntilEventId: 200, Incremental: true}, 0, 10)
+
+	assert.NoError(t, err)
+	mockDB.AssertExpectations(t)
+}
+
+func TestFindLatestEvent(t *testing.T) {
+	mockDB := new(MockDB)
+	queryRepo := NewQueryRepository(mockDB)
+
+	endTime := time.Date(2023, 8, 2, 0, 0, 0, 0, time.UTC)
+	latestCreatedDate := endTime.Add(-time.Hour)
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]domain.Watermark"),
+		mock.MatchedBy(func(query string) bool {
+			return strings.Contains(query, "WHERE cd.jurisdiction = $1 AND cd.case_type_id IN ($2,$3)") &&
+				strings.Contains(query, "AND ce.created_date <= $4") &&
+				strings.Contains(query, "ORDER BY ce.created_date DESC, ce.id DESC")
+		}),
+		[]interface{}{"J1", "CT1", "CT2", endTime}).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]Watermark) = []Watermark{{LastEventCreatedDate: latestCreatedDate, LastEventId: 12}}
+		})
+
+	latest, err := queryRepo.findLatestEvent(context.Background(), Comparison{Jurisdiction: "J1", CaseTypeId: "CT1,CT2",
+		SearchPeriodEndTime: endTime})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Watermark{Jurisdiction: "J1", CaseTypeId: "CT1,CT2", LastEventCreatedDate: latestCreatedDate,
+		LastEventId: 12}, latest)
+	mockDB.AssertExpectations(t)
+}
+
+func TestFindSampleFrameSelectsCaseTypeAndEventCount(t *testing.T) {
+	mockDB := new(MockDB)
+	queryRepo := NewQueryRepository(mockDB)
+
+	expectedFrame := []SampleFrameEntity{{CaseId: "1", CaseTypeId: "CT1", EventCount: 3}}
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]domain.SampleFrameEntity"),
+		mock.MatchedBy(func(query string) bool {
+			return strings.Contains(query, "cd.case_type_id as case_type_id, COUNT(ce.id) as event_count") &&
+				strings.Contains(query, "GROUP BY cd.id, cd.case_type_id HAVING COUNT(ce.id) > 1") &&
+				strings.Contains(query, "AND cd.case_type_id IN ($2)") &&
+				strings.Contains(query, "LIMIT $6")
+		}),
+		mock.MatchedBy(func(args []interface{}) bool { return len(args) == 6 && args[4] == int64(5) })).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]SampleFrameEntity) = expectedFrame
+		})
+
+	frame, err := queryRepo.findSampleFrame(context.Background(), Comparison{Jurisdiction: "J1", CaseTypeId: "CT1"}, 5, 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedFrame, frame)
+	mockDB.AssertExpectations(t)
+}
+
+func TestFindCasesByJurisdictionInImpactPeriodSelectsTheEventContextColumnsPresent(t *testing.T) {
+	mockDB := new(MockDB)
+	expectTableColumns(mockDB, "case_event", "id", "state_id", "summary", "description")
+	queryRepo := NewQueryRepository(mockDB)
+
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]domain.CaseDataEntity"),
+		mock.MatchedBy(func(query string) bool {
+			return strings.Contains(query, "COALESCE(ce.summary, '') as summary") &&
+				strings.Contains(query, "COALESCE(ce.description, '') as description") &&
+				strings.Contains(query, "'' as state_name") &&
+				strings.Contains(query, "'' as proxied_by")
+		}),
+		mock.Anything).
+		Return(nil).Twice()
+
+	_, err := queryRepo.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"1"}, Comparison{})
+	assert.NoError(t, err)
+	_, err = queryRepo.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"2"}, Comparison{})
+	assert.NoError(t, err)
+
+	mockDB.AssertExpectations(t)
+}
+
+func TestFindCasesByJurisdictionInImpactPeriodReturnsColumnLookupError(t *testing.T) {
+	mockDB := new(MockDB)
+	queryRepo := NewQueryRepository(mockDB)
+
+	mockDB.On("Select", mock.AnythingOfType("*[]string"), mock.AnythingOfType("string"), mock.Anything).
+		Return(errors.New("connection reset")).Once()
+
+	_, err := queryRepo.findCasesByJurisdictionInImpactPeriod(context.Background(), []string{"1"}, Comparison{})
+
+	assert.EqualError(t, err, "error in findCasesByJurisdictionInImpactPeriod(): error while reading the columns of "+
+		"case_event: connection reset")
+	mockDB.AssertExpectations(t)
+}
+
+func TestStreamEventsInImpactPeriodHandsOverEachRow(t *testing.T) {
+	mockDB := new(MockDB)
+	expectTableColumns(mockDB, "case_event", eventContextColumns...)
+	queryRepo := NewQueryRepository(mockDB)
+
+	rows := &MockRows{entities: []CaseDataEntity{
+		{CaseId: 1, EventId: 1},
+		{CaseId: 1, EventId: 2},
+		{CaseId: 2, EventId: 3},
+	}}
+	mockDB.On("Queryx",
+		mock.MatchedBy(func(query string) bool { return strings.HasSuffix(query, "ORDER BY cd.id, ce.id") }),
+		mock.Anything).
+		Return(rows, nil)
+
+	var eventIds []int64
+	err := queryRepo.streamEventsInImpactPeriod(context.Background(), []string{"1", "2"}, Comparison{},
+		func(event CaseDataEntity) error {
+			eventIds = append(eventIds, event.EventId)
+			return nil
+		})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, eventIds)
+	assert.True(t, rows.closed)
+	mockDB.AssertExpectations(t)
+}
+
+func TestStreamEventsInImpactPeriodStopsOnHandlerError(t *testing.T) {
+	mockDB := new(MockDB)
+	expectTableColumns(mockDB, "case_event", eventContextColumns...)
+	queryRepo := NewQueryRepository(mockDB)
+
+	rows := &MockRows{entities: []CaseDataEntity{{CaseId: 1, EventId: 1}, {CaseId: 2, EventId: 2}}}
+	mockDB.On("Queryx", mock.AnythingOfType("string"), mock.Anything).Return(rows, nil)
+
+	calls := 0
+	err := queryRepo.streamEventsInImpactPeriod(context.Background(), []string{"1", "2"}, Comparison{},
+		func(event CaseDataEntity) error {
+			calls++
+			return errors.New("handler failed")
+		})
+
+	assert.EqualError(t, err, "handler failed")
+	assert.Equal(t, 1, calls)
+	assert.True(t, rows.closed)
+}
+
+func TestFindWatchedFieldChanges(t *testing.T) {
+	mockDB := new(MockDB)
+	expectTableColumns(mockDB, "case_event", eventContextColumns...)
+	queryRepo := NewQueryRepository(mockDB)
+
+	expectedChanges := []WatchedFieldChangeEntity{{CaseId: 1, Reference: 11, EventId: 2, FieldPath: "applicant.name",
+		OldValue: sql.NullString{String: `"Jo"`, Valid: true}, NewValue: sql.NullString{String: `"Joe"`, Valid: true}}}
+
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]domain.WatchedFieldChangeEntity"),
+		mock.MatchedBy(func(query string) bool {
+			return strings.Contains(query, "LAG(ce.data #> string_to_array(wf.path, '.')) OVER field_events") &&
+				strings.Contains(query, "unnest($6::text[])") &&
+				strings.Contains(query, "new_value IS DISTINCT FROM old_value")
+		}),
+		mock.MatchedBy(func(args []interface{}) bool { return len(args) == 6 })).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]WatchedFieldChangeEntity) = expectedChanges
+		})
+
+	changes, err := queryRepo.findWatchedFieldChanges(context.Background(), []string{"1"}, Comparison{}, []string{"applicant.name"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedChanges, changes)
+	mockDB.AssertExpectations(t)
+}
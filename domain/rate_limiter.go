@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces calls evenly so that no more than the configured number of calls per second are made, however
+// many workers share it.
+type rateLimiter struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	next     time.Time
+}
+
+// newRateLimiter creates a rate limiter. A rate of 0 or below doesn't limit anything.
+func newRateLimiter(callsPerSecond float64) *rateLimiter {
+	var interval time.Duration
+	if callsPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / callsPerSecond)
+	}
+	return &rateLimiter{interval: interval}
+}
+
+// wait blocks until the next call slot is due, or returns the error of the context when it is done first.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil || r.interval == 0 {
+		return err
+	}
+
+	r.mutex.Lock()
+	now := time.Now()
+	slot := now
+	if r.next.After(now) {
+		slot = r.next
+	}
+	r.next = slot.Add(r.interval)
+	r.mutex.Unlock()
+
+	return sleep(ctx, slot.Sub(now))
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"ccd-comparator-data-diff-rapid/internal/store"
+	"context"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var errCircuitOpen = errors.New("circuit breaker is open after repeated transient database failures")
+
+// retrier runs operations again after transient database errors, waiting a jittered exponential backoff between
+// the attempts. Its circuit breaker is shared by all the workers.
+type retrier struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	breaker        *circuitBreaker
+}
+
+func newRetrier(retry config.Retry) *retrier {
+	return &retrier{
+		maxAttempts:    retry.MaxAttempts,
+		initialBackoff: time.Duration(retry.InitialBackoffMilliseconds) * time.Millisecond,
+		maxBackoff:     time.Duration(retry.MaxBackoffMilliseconds) * time.Millisecond,
+		breaker: &circuitBreaker{
+			threshold: retry.CircuitBreakerThreshold,
+			cooldown:  time.Duration(retry.CircuitBreakerCooldownSeconds) * time.Second,
+		},
+	}
+}
+
+// do runs the operation until it succeeds, fails with an error that isn't transient or runs out of attempts. It
+// fails straight away with errCircuitOpen while the circuit breaker is open, and with the error of the context once
+// it is done.
+func (r *retrier) do(ctx context.Context, operation string, run func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.breaker.allow(); err != nil {
+			return err
+		}
+
+		err := run()
+		r.breaker.record(err)
+		if err == nil || !store.IsRetryable(err) || attempt >= r.maxAttempts {
+			return err
+		}
+
+		backoff := r.backoff(attempt)
+		log.Warn().Msgf("%s failed on attempt %d of %d, retrying in %s: %s", operation, attempt, r.maxAttempts,
+			backoff, err)
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for the duration, or returns the error of the context when it is done first.
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns a random wait up to the initial backoff doubled for each previous attempt, capped at the max
+// backoff, so that workers failing together don't retry together.
+func (r *retrier) backoff(attempt int) time.Duration {
+	ceiling := r.initialBackoff
+	for i := 1; i < attempt && ceiling < r.maxBackoff; i++ {
+		ceiling *= 2
+	}
+	if r.maxBackoff > 0 && ceiling > r.maxBackoff {
+		ceiling = r.maxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// circuitBreaker opens after threshold consecutive transient failures and stays open for the cooldown. The first
+// attempt after the cooldown closes it again when it succeeds and reopens it when it fails. A threshold of 0
+// disables the breaker.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	mutex     sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func (c *circuitBreaker) allow() error {
+	if c.threshold <= 0 {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if time.Now().Before(c.openUntil) {
+		return errCircuitOpen
+	}
+	return nil
+}
+
+func (c *circuitBreaker) record(err error) {
+	if c.threshold <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch {
+	case err == nil:
+		c.failures = 0
+	case store.IsRetryable(err):
+		c.failures++
+		if c.failures >= c.threshold {
+			if !time.Now().Before(c.openUntil) {
+				log.Error().Msgf("Circuit breaker opened for %s after %d consecutive transient failures",
+					c.cooldown, c.failures)
+			}
+			c.openUntil = time.Now().Add(c.cooldown)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"context"
+	"database/sql/driver"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetrier_RetriesTransientErrors(t *testing.T) {
+	retrier := newRetrier(config.Retry{MaxAttempts: 3, InitialBackoffMilliseconds: 1, MaxBackoffMilliseconds: 2})
+
+	attempts := 0
+	err := retrier.do(context.Background(), "test", func() error {
+		attempts++
+		if attempts < 3 {
+			return &pq.Error{Code: "40001"}
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestRetrier_StopsOnPermanentErrorsAndAfterMaxAttempts(t *testing.T) {
+	retrier := newRetrier(config.Retry{MaxAttempts: 3})
+
+	attempts := 0
+	err := retrier.do(context.Background(), "test", func() error {
+		attempts++
+		return errors.New("invalid case id")
+	})
+	assert.EqualError(t, err, "invalid case id")
+	assert.Equal(t, 1, attempts)
+
+	attempts = 0
+	err = retrier.do(context.Background(), "test", func() error {
+		attempts++
+		return driver.ErrBadConn
+	})
+	assert.Equal(t, driver.ErrBadConn, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestRetrier_StopsOnceTheContextIsDone(t *testing.T) {
+	retrier := newRetrier(config.Retry{MaxAttempts: 3, InitialBackoffMilliseconds: 60000,
+		MaxBackoffMilliseconds: 60000})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	start := time.Now()
+	err := retrier.do(ctx, "test", func() error {
+		attempts++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return driver.ErrBadConn
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	err = retrier.do(ctx, "test", func() error {
+		t.Fatal("the operation shouldn't run once the context is done")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRetrier_BackoffIsCapped(t *testing.T) {
+	retrier := newRetrier(config.Retry{InitialBackoffMilliseconds: 100, MaxBackoffMilliseconds: 250})
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		backoff := retrier.backoff(attempt)
+		assert.GreaterOrEqual(t, backoff, time.Duration(0))
+		assert.LessOrEqual(t, backoff, 250*time.Millisecond)
+	}
+	assert.LessOrEqual(t, retrier.backoff(1), 100*time.Millisecond)
+}
+
+func TestCircuitBreaker_OpensAfterConsecutiveTransientFailures(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 2, cooldown: 50 * time.Millisecond}
+
+	breaker.record(driver.ErrBadConn)
+	breaker.record(errors.New("invalid case id"))
+	assert.NoError(t, breaker.allow())
+
+	breaker.record(driver.ErrBadConn)
+	assert.Equal(t, errCircuitOpen, breaker.allow())
+
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, breaker.allow())
+
+	breaker.record(driver.ErrBadConn)
+	assert.Equal(t, errCircuitOpen, breaker.allow(), "a failure after the cooldown reopens the breaker")
+
+	time.Sleep(60 * time.Millisecond)
+	breaker.record(nil)
+	breaker.record(driver.ErrBadConn)
+	assert.NoError(t, breaker.allow())
+}
+
+func TestRetrier_FailsFastWhileCircuitIsOpen(t *testing.T) {
+	retrier := newRetrier(config.Retry{MaxAttempts: 5, CircuitBreakerThreshold: 2, CircuitBreakerCooldownSeconds: 60})
+
+	attempts := 0
+	err := retrier.do(context.Background(), "test", func() error {
+		attempts++
+		return driver.ErrBadConn
+	})
+
+	assert.Equal(t, errCircuitOpen, err)
+	assert.Equal(t, 2, attempts)
+}
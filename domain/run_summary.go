@@ -0,0 +1,346 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/comparator"
+	"ccd-comparator-data-diff-rapid/config"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	stageDiscovery = "discovery"
+	stageLoad      = "load"
+	stageCompare   = "compare"
+	stageSave      = "save"
+)
+
+var stages = []string{stageDiscovery, stageLoad, stageCompare, stageSave}
+
+// Exit codes of a run, from the most to the least severe outcome.
+const (
+	ExitCodeSuccess     = 0
+	ExitCodeFailure     = 1 // batches or scans failed
+	ExitCodeGateFailed  = 2 // the violations didn't pass the gate
+	ExitCodeInterrupted = 3
+)
+
+// RunSummary totals the batches of a run across the jurisdictions and case types it scans.
+type RunSummary struct {
+	mutex              sync.Mutex
+	startedAt          time.Time
+	dispatchedCases    int
+	scannedCases       int
+	comparedEvents     int
+	completedBatches   int
+	failedBatches      int
+	interruptedBatches int
+	failedScans        int
+	fieldChanges       map[comparator.OperationType]int
+	violations         map[comparator.RuleType]int
+	stageDurations     map[string]time.Duration
+}
+
+func NewRunSummary() *RunSummary {
+	return &RunSummary{
+		startedAt:      time.Now(),
+		fieldChanges:   make(map[comparator.OperationType]int),
+		violations:     make(map[comparator.RuleType]int),
+		stageDurations: make(map[string]time.Duration),
+	}
+}
+
+// comparisonTotals adds up the comparison of a batch, which is recorded in the summary once the batch completes so
+// that the attempts retried are not counted twice.
+type comparisonTotals struct {
+	cases          int
+	events         int
+	fieldChanges   map[comparator.OperationType]int
+	violations     map[comparator.RuleType]int
+	stageDurations map[string]time.Duration
+}
+
+func newComparisonTotals() *comparisonTotals {
+	return &comparisonTotals{
+		fieldChanges:   make(map[comparator.OperationType]int),
+		violations:     make(map[comparator.RuleType]int),
+		stageDurations: make(map[string]time.Duration),
+	}
+}
+
+// add counts the changes and the violations, leaving out the violations of the events the filter excludes as the
+// report does.
+func (t *comparisonTotals) add(eventFieldChanges comparator.EventFieldChanges, analyzeResult *comparator.AnalyzeResult,
+	filter config.EventFilter) {
+	for _, changes := range eventFieldChanges {
+		for _, change := range changes {
+			t.fieldChanges[change.OperationType]++
+		}
+	}
+	for ruleType, count := range analyzeResult.ViolationCounts(eventFieldChanges, filter) {
+		t.violations[ruleType] += count
+	}
+}
+
+// timeStage adds the time since start to the stage.
+func (t *comparisonTotals) timeStage(stage string, start time.Time) {
+	t.stageDurations[stage] += time.Since(start)
+}
+
+// The record methods are no-ops when the run isn't summarised.
+
+func (r *RunSummary) recordDispatchedCases(count int) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.dispatchedCases += count
+}
+
+func (r *RunSummary) recordComparison(totals *comparisonTotals) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.scannedCases += totals.cases
+	r.comparedEvents += totals.events
+	for operationType, count := range totals.fieldChanges {
+		r.fieldChanges[operationType] += count
+	}
+	for ruleType, count := range totals.violations {
+		r.violations[ruleType] += count
+	}
+	for stage, duration := range totals.stageDurations {
+		r.stageDurations[stage] += duration
+	}
+}
+
+// timeStage adds the time since start to the stage, for the stages outside of the batches.
+func (r *RunSummary) timeStage(stage string, start time.Time) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stageDurations[stage] += time.Since(start)
+}
+
+func (r *RunSummary) recordCompletedBatch() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.completedBatches++
+}
+
+func (r *RunSummary) recordFailedBatch() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failedBatches++
+}
+
+func (r *RunSummary) recordInterruptedBatch() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.interruptedBatches++
+}
+
+// recordFailedScan records a jurisdiction and case type whose cases couldn't be discovered, or whose watermark
+// couldn't be moved.
+func (r *RunSummary) recordFailedScan() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failedScans++
+}
+
+// RunReport holds the totals of a run, which only cover the batches compared so far when the run was interrupted.
+// Stage times are summed over the workers, so they exceed the elapsed time when the workers run in parallel.
+type RunReport struct {
+	RunId              string             `json:"run_id,omitempty"`
+	StartedAt          time.Time          `json:"started_at"`
+	ElapsedSeconds     float64            `json:"elapsed_seconds"`
+	Interrupted        bool               `json:"interrupted"`
+	DispatchedCases    int                `json:"dispatched_cases"`
+	ScannedCases       int                `json:"scanned_cases"`
+	ComparedEvents     int                `json:"compared_events"`
+	CompletedBatches   int                `json:"completed_batches"`
+	FailedBatches      int                `json:"failed_batches"`
+	InterruptedBatches int                `json:"interrupted_batches"`
+	FailedScans        int                `json:"failed_scans"`
+	FieldChanges       map[string]int     `json:"field_changes"`
+	Violations         []RuleViolations   `json:"violations"`
+	StageSeconds       map[string]float64 `json:"stage_seconds"`
+	Gate               *GateResult        `json:"gate,omitempty"`
+	ExitCode           int                `json:"exit_code"`
+}
+
+type RuleViolations struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Count    int    `json:"count"`
+}
+
+type GateResult struct {
+	MinSeverity   string `json:"min_severity"`
+	MaxViolations int    `json:"max_violations"`
+	Violations    int    `json:"violations"`
+	Passed        bool   `json:"passed"`
+}
+
+// Report returns the totals of the run with the severities of the rules and the outcome of the gate.
+func (r *RunSummary) Report(runId string, interrupted bool, rule config.Rule) (RunReport, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	report := RunReport{
+		RunId:              runId,
+		StartedAt:          r.startedAt.UTC(),
+		ElapsedSeconds:     time.Since(r.startedAt).Seconds(),
+		Interrupted:        interrupted,
+		DispatchedCases:    r.dispatchedCases,
+		ScannedCases:       r.scannedCases,
+		ComparedEvents:     r.comparedEvents,
+		CompletedBatches:   r.completedBatches,
+		FailedBatches:      r.failedBatches,
+		InterruptedBatches: r.interruptedBatches,
+		FailedScans:        r.failedScans,
+		FieldChanges:       make(map[string]int),
+		Violations:         make([]RuleViolations, 0, len(r.violations)),
+		StageSeconds:       make(map[string]float64),
+	}
+	for operationType, count := range r.fieldChanges {
+		report.FieldChanges[string(operationType)] = count
+	}
+	for ruleType, count := range r.violations {
+		report.Violations = append(report.Violations, RuleViolations{
+			Rule:     string(ruleType),
+			Severity: string(comparator.RuleSeverity(ruleType, rule.Severities)),
+			Count:    count,
+		})
+	}
+	sort.Slice(report.Violations, func(i, j int) bool {
+		return report.Violations[i].Rule < report.Violations[j].Rule
+	})
+	for _, stage := range stages {
+		report.StageSeconds[stage] = r.stageDurations[stage].Seconds()
+	}
+
+	if rule.Gate.Enabled {
+		gate, err := report.applyGate(rule.Gate)
+		if err != nil {
+			return RunReport{}, err
+		}
+		report.Gate = &gate
+	}
+	report.ExitCode = report.exitCode()
+	return report, nil
+}
+
+// applyGate counts the violations of the rules rated the minimum severity of the gate or higher.
+func (r RunReport) applyGate(gate config.Gate) (GateResult, error) {
+	minSeverity, err := comparator.ParseSeverity(gate.MinSeverity)
+	if err != nil {
+		return GateResult{}, errors.Wrap(err, "invalid gate")
+	}
+
+	result := GateResult{MinSeverity: string(minSeverity), MaxViolations: gate.MaxViolations}
+	for _, violations := range r.Violations {
+		if comparator.Severity(violations.Severity).AtLeast(minSeverity) {
+			result.Violations += violations.Count
+		}
+	}
+	result.Passed = result.Violations <= gate.MaxViolations
+	return result, nil
+}
+
+func (r RunReport) exitCode() int {
+	switch {
+	case r.FailedBatches > 0 || r.FailedScans > 0:
+		return ExitCodeFailure
+	case r.Gate != nil && !r.Gate.Passed:
+		return ExitCodeGateFailed
+	case r.Interrupted:
+		return ExitCodeInterrupted
+	default:
+		return ExitCodeSuccess
+	}
+}
+
+// WriteTable writes the report as a table.
+func (r RunReport) WriteTable(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "RUN SUMMARY\t\n")
+	if r.RunId != "" {
+		fmt.Fprintf(table, "Run id\t%s\n", r.RunId)
+	}
+	fmt.Fprintf(table, "Elapsed\t%s\n", secondsDuration(r.ElapsedSeconds))
+	fmt.Fprintf(table, "Interrupted\t%t\n", r.Interrupted)
+	fmt.Fprintf(table, "Cases dispatched\t%d\n", r.DispatchedCases)
+	fmt.Fprintf(table, "Cases scanned\t%d\n", r.ScannedCases)
+	fmt.Fprintf(table, "Events compared\t%d\n", r.ComparedEvents)
+	fmt.Fprintf(table, "Batches completed\t%d\n", r.CompletedBatches)
+	fmt.Fprintf(table, "Batches failed\t%d\n", r.FailedBatches)
+	fmt.Fprintf(table, "Batches interrupted\t%d\n", r.InterruptedBatches)
+	fmt.Fprintf(table, "Scans failed\t%d\n", r.FailedScans)
+
+	fmt.Fprintf(table, "\t\nFIELD CHANGES\tCOUNT\n")
+	operationTypes := make([]string, 0, len(r.FieldChanges))
+	for operationType := range r.FieldChanges {
+		operationTypes = append(operationTypes, operationType)
+	}
+	sort.Strings(operationTypes)
+	for _, operationType := range operationTypes {
+		fmt.Fprintf(table, "%s\t%d\n", operationType, r.FieldChanges[operationType])
+	}
+
+	fmt.Fprintf(table, "\t\nVIOLATIONS\tSEVERITY\tCOUNT\n")
+	for _, violations := range r.Violations {
+		fmt.Fprintf(table, "%s\t%s\t%d\n", violations.Rule, violations.Severity, violations.Count)
+	}
+
+	fmt.Fprintf(table, "\t\nSTAGE\tWORKER TIME\n")
+	for _, stage := range stages {
+		fmt.Fprintf(table, "%s\t%s\n", stage, secondsDuration(r.StageSeconds[stage]))
+	}
+
+	if r.Gate != nil {
+		fmt.Fprintf(table, "\t\nGATE\t%s or higher: %d violations of at most %d, passed: %t\n",
+			r.Gate.MinSeverity, r.Gate.Violations, r.Gate.MaxViolations, r.Gate.Passed)
+	}
+	fmt.Fprintf(table, "Exit code\t%d\n", r.ExitCode)
+	return table.Flush()
+}
+
+// WriteFile writes the report as JSON to the path.
+func (r RunReport) WriteFile(path string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the run summary")
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "failed to write the run summary")
+	}
+	return nil
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+}
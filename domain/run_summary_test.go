@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"bytes"
+	"ccd-comparator-data-diff-rapid/comparator"
+	"ccd-comparator-data-diff-rapid/config"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestRunSummary() *RunSummary {
+	summary := NewRunSummary()
+	totals := newComparisonTotals()
+	totals.cases = 2
+	totals.events = 7
+	totals.fieldChanges[comparator.Modified] = 3
+	totals.violations[comparator.RuleTypeStaticFieldChange] = 2
+	totals.violations[comparator.RuleTypeFieldChangeCount] = 4
+	summary.recordDispatchedCases(2)
+	summary.recordComparison(totals)
+	summary.recordCompletedBatch()
+	return summary
+}
+
+func TestRunSummary_Report(t *testing.T) {
+	summary := newTestRunSummary()
+
+	report, err := summary.Report("run", false, config.Rule{Severities: map[string]string{"fieldchangecount": "medium"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "run", report.RunId)
+	assert.Equal(t, 2, report.ScannedCases)
+	assert.Equal(t, 7, report.ComparedEvents)
+	assert.Equal(t, map[string]int{"MODIFIED": 3}, report.FieldChanges)
+	assert.Equal(t, []RuleViolations{
+		{Rule: "fieldchangecount", Severity: "medium", Count: 4},
+		{Rule: "staticfieldchange", Severity: "high", Count: 2},
+	}, report.Violations)
+	assert.Nil(t, report.Gate)
+	assert.Equal(t, ExitCodeSuccess, report.ExitCode)
+}
+
+func TestRunSummary_ReportAppliesTheGate(t *testing.T) {
+	summary := newTestRunSummary()
+
+	report, err := summary.Report("", false,
+		config.Rule{Gate: config.Gate{Enabled: true, MinSeverity: "high", MaxViolations: 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, &GateResult{MinSeverity: "high", MaxViolations: 2, Violations: 2, Passed: true}, report.Gate)
+	assert.Equal(t, ExitCodeSuccess, report.ExitCode)
+
+	report, err = summary.Report("", false,
+		config.Rule{Gate: config.Gate{Enabled: true, MinSeverity: "low", MaxViolations: 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, &GateResult{MinSeverity: "low", MaxViolations: 2, Violations: 6, Passed: false}, report.Gate)
+	assert.Equal(t, ExitCodeGateFailed, report.ExitCode)
+
+	_, err = summary.Report("", false, config.Rule{Gate: config.Gate{Enabled: true, MinSeverity: "urgent"}})
+	assert.EqualError(t, err, "invalid gate: unknown severity 'urgent', expected low, medium or high")
+}
+
+func TestRunSummary_ReportExitCodes(t *testing.T) {
+	failingGate := config.Rule{Gate: config.Gate{Enabled: true, MinSeverity: "low"}}
+
+	report, err := newTestRunSummary().Report("", true, config.Rule{})
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeInterrupted, report.ExitCode)
+
+	report, err = newTestRunSummary().Report("", true, failingGate)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeGateFailed, report.ExitCode)
+
+	summary := newTestRunSummary()
+	summary.recordFailedBatch()
+	report, err = summary.Report("", true, failingGate)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeFailure, report.ExitCode)
+
+	summary = newTestRunSummary()
+	summary.recordFailedScan()
+	report, err = summary.Report("", false, config.Rule{})
+	assert.NoError(t, err)
+	assert.Equal(t, ExitCodeFailure, report.ExitCode)
+}
+
+func TestRunReport_WriteTableAndFile(t *testing.T) {
+	report, err := newTestRunSummary().Report("run", false,
+		config.Rule{Gate: config.Gate{Enabled: true, MinSeverity: "high"}})
+	assert.NoError(t, err)
+
+	var table bytes.Buffer
+	assert.NoError(t, report.WriteTable(&table))
+	assert.Contains(t, table.String(), "Events compared      7\n")
+	assert.Contains(t, table.String(), "MODIFIED             3\n")
+	assert.Contains(t, table.String(), "staticfieldchange    high      2\n")
+	assert.Contains(t, table.String(), "high or higher: 2 violations of at most 0, passed: false\n")
+	assert.Contains(t, table.String(), "Exit code            2\n")
+
+	path := filepath.Join(t.TempDir(), "run_summary.json")
+	assert.NoError(t, report.WriteFile(path))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var written map[string]any
+	assert.NoError(t, json.Unmarshal(content, &written))
+	assert.Equal(t, "run", written["run_id"])
+	assert.Equal(t, float64(2), written["scanned_cases"])
+	assert.Equal(t, map[string]any{"MODIFIED": float64(3)}, written["field_changes"])
+	assert.Equal(t, false, written["gate"].(map[string]any)["passed"])
+	assert.Equal(t, float64(ExitCodeGateFailed), written["exit_code"])
+}
+
+func TestRunSummary_RecordsNothingWhenNotSummarised(t *testing.T) {
+	var summary *RunSummary
+
+	assert.NotPanics(t, func() {
+		summary.recordComparison(newComparisonTotals())
+		summary.recordFailedScan()
+		summary.timeStage(stageDiscovery, NewRunSummary().startedAt)
+	})
+}
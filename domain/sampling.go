@@ -0,0 +1,276 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	sampleStratifyCaseType   = "casetype"
+	sampleStratifyEventCount = "eventcount"
+	sampleTotalStratum       = "total"
+)
+
+// SampleFrameEntity is a case of the population a sample is drawn from.
+type SampleFrameEntity struct {
+	CaseId     string `db:"case_id"`
+	CaseTypeId string `db:"case_type_id"`
+	EventCount int    `db:"event_count"`
+}
+
+type sampleStratum struct {
+	name       string
+	order      int
+	population int
+	sampled    int
+	caseIds    []string
+}
+
+// caseSample holds the cases drawn from every stratum of the population, in case id order.
+type caseSample struct {
+	seed    int64
+	caseIds []string
+	strata  []*sampleStratum
+	stratum map[int64]*sampleStratum
+}
+
+// drawSample draws the configured number of cases from the frame. The strata are sampled in proportion to their
+// number of cases, so that the sample is self-weighting, and the same seed draws the same sample from the same frame.
+func drawSample(frame []SampleFrameEntity, sample config.Sample) caseSample {
+	seed := sample.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	strataByName := make(map[string]*sampleStratum)
+	var strata []*sampleStratum
+	for _, entity := range frame {
+		name, order := stratumOf(entity, sample)
+		stratum, found := strataByName[name]
+		if !found {
+			stratum = &sampleStratum{name: name, order: order}
+			strataByName[name] = stratum
+			strata = append(strata, stratum)
+		}
+		stratum.population++
+		stratum.caseIds = append(stratum.caseIds, entity.CaseId)
+	}
+	sort.Slice(strata, func(i, j int) bool {
+		if strata[i].order != strata[j].order {
+			return strata[i].order < strata[j].order
+		}
+		return strata[i].name < strata[j].name
+	})
+
+	allocateSample(strata, sampleSize(len(frame), sample))
+
+	drawn := caseSample{seed: seed, strata: strata, stratum: make(map[int64]*sampleStratum)}
+	random := rand.New(rand.NewSource(seed))
+	for _, stratum := range strata {
+		caseIds := stratum.caseIds
+		for i := 0; i < stratum.sampled; i++ {
+			j := i + random.Intn(len(caseIds)-i)
+			caseIds[i], caseIds[j] = caseIds[j], caseIds[i]
+
+			drawn.caseIds = append(drawn.caseIds, caseIds[i])
+			if caseId, err := strconv.ParseInt(caseIds[i], 10, 64); err == nil {
+				drawn.stratum[caseId] = stratum
+			}
+		}
+		stratum.caseIds = nil
+	}
+
+	sort.Slice(drawn.caseIds, func(i, j int) bool {
+		first, _ := strconv.ParseInt(drawn.caseIds[i], 10, 64)
+		second, _ := strconv.ParseInt(drawn.caseIds[j], 10, 64)
+		return first < second
+	})
+	return drawn
+}
+
+func stratumOf(entity SampleFrameEntity, sample config.Sample) (string, int) {
+	switch sample.Stratify {
+	case sampleStratifyCaseType:
+		return entity.CaseTypeId, 0
+	case sampleStratifyEventCount:
+		return eventCountBucket(entity.EventCount, sample.EventCountBuckets)
+	default:
+		return sampleTotalStratum, 0
+	}
+}
+
+// eventCountBucket names the bucket of the event count, where bounds are the ascending lower bounds of the buckets
+// after the first one.
+func eventCountBucket(eventCount int, bounds []int) (string, int) {
+	bucket := sort.Search(len(bounds), func(i int) bool { return bounds[i] > eventCount })
+	switch {
+	case len(bounds) == 0:
+		return sampleTotalStratum, 0
+	case bucket == 0:
+		return fmt.Sprintf("events<%d", bounds[0]), bucket
+	case bucket == len(bounds):
+		return fmt.Sprintf("events>=%d", bounds[bucket-1]), bucket
+	default:
+		return fmt.Sprintf("events %d-%d", bounds[bucket-1], bounds[bucket]-1), bucket
+	}
+}
+
+func sampleSize(population int, sample config.Sample) int {
+	size := sample.Size
+	if size <= 0 {
+		size = int(math.Round(sample.Percentage / 100 * float64(population)))
+	}
+	return min(size, population)
+}
+
+// allocateSample shares the sample size among the strata in proportion to their population, giving the cases left
+// by rounding down to the strata with the largest remainders.
+func allocateSample(strata []*sampleStratum, size int) {
+	population := 0
+	for _, stratum := range strata {
+		population += stratum.population
+	}
+	if population == 0 {
+		return
+	}
+
+	remainders := make([]float64, len(strata))
+	allocated := 0
+	for i, stratum := range strata {
+		share := float64(size) * float64(stratum.population) / float64(population)
+		stratum.sampled = int(share)
+		remainders[i] = share - float64(stratum.sampled)
+		allocated += stratum.sampled
+	}
+
+	indexes := make([]int, len(strata))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool { return remainders[indexes[i]] > remainders[indexes[j]] })
+	for _, index := range indexes[:size-allocated] {
+		strata[index].sampled++
+	}
+}
+
+// sampleRun collects whether the compared cases of a sampled scan have a violation.
+type sampleRun struct {
+	mutex    sync.Mutex
+	sample   caseSample
+	outcomes map[int64]bool
+}
+
+func newSampleRun() *sampleRun {
+	return &sampleRun{outcomes: make(map[int64]bool)}
+}
+
+func (r *sampleRun) setSample(sample caseSample) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.sample = sample
+}
+
+// record keeps whether each compared case, by case id, has a violation. It is a no-op when the scan isn't sampled.
+func (r *sampleRun) record(outcomes map[int64]bool) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for caseId, violated := range outcomes {
+		r.outcomes[caseId] = violated
+	}
+}
+
+type stratumEstimate struct {
+	name       string
+	population int
+	sampled    int
+	compared   int
+	violating  int
+	rate       float64
+	lower      float64
+	upper      float64
+}
+
+// estimate extrapolates the violation rate of every stratum, and of the whole population, with Wilson score
+// intervals at the confidence level. The sample is self-weighting, so the total pools the strata.
+func (r *sampleRun) estimate(confidenceLevel float64) []stratumEstimate {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	z := math.Sqrt2 * math.Erfinv(confidenceLevel)
+	total := stratumEstimate{name: sampleTotalStratum}
+	indexes := make(map[*sampleStratum]int, len(r.sample.strata))
+	estimates := make([]stratumEstimate, 0, len(r.sample.strata)+1)
+	for _, stratum := range r.sample.strata {
+		indexes[stratum] = len(estimates)
+		estimates = append(estimates, stratumEstimate{name: stratum.name, population: stratum.population,
+			sampled: stratum.sampled})
+		total.population += stratum.population
+		total.sampled += stratum.sampled
+	}
+
+	for caseId, violated := range r.outcomes {
+		stratum, found := r.sample.stratum[caseId]
+		if !found {
+			continue
+		}
+		estimate := &estimates[indexes[stratum]]
+		estimate.compared++
+		total.compared++
+		if violated {
+			estimate.violating++
+			total.violating++
+		}
+	}
+
+	if len(estimates) > 1 {
+		estimates = append(estimates, total)
+	}
+	for i := range estimates {
+		estimates[i].rate, estimates[i].lower, estimates[i].upper = wilsonInterval(estimates[i].violating,
+			estimates[i].compared, z)
+	}
+	return estimates
+}
+
+// logSummary logs the estimates of the sample, unless no sample was drawn as the cases were configured.
+func (r *sampleRun) logSummary(confidenceLevel float64) {
+	if r == nil || r.sample.strata == nil {
+		return
+	}
+	log.Info().Msgf("Sample summary with seed %d and %g%% confidence intervals", r.sample.seed,
+		confidenceLevel*100)
+	for _, estimate := range r.estimate(confidenceLevel) {
+		log.Info().Msgf("Sample summary - stratum: %s population: %d sampled: %d compared: %d violating: %d "+
+			"violation rate: %.2f%% (%.2f%%-%.2f%%) estimated violating cases: %.0f (%.0f-%.0f)",
+			estimate.name, estimate.population, estimate.sampled, estimate.compared, estimate.violating,
+			estimate.rate*100, estimate.lower*100, estimate.upper*100, estimate.rate*float64(estimate.population),
+			estimate.lower*float64(estimate.population), estimate.upper*float64(estimate.population))
+	}
+}
+
+// wilsonInterval returns the observed rate with the Wilson score interval, which stays within 0 and 1 and holds for
+// rates close to them or few trials.
+func wilsonInterval(successes, trials int, z float64) (float64, float64, float64) {
+	if trials == 0 {
+		return 0, 0, 1
+	}
+	n := float64(trials)
+	rate := float64(successes) / n
+	denominator := 1 + z*z/n
+	center := (rate + z*z/(2*n)) / denominator
+	margin := z * math.Sqrt(rate*(1-rate)/n+z*z/(4*n*n)) / denominator
+	return rate, math.Max(0, center-margin), math.Min(1, center+margin)
+}
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func newSampleFrame(caseTypeIds ...string) []SampleFrameEntity {
+	var frame []SampleFrameEntity
+	for i, caseTypeId := range caseTypeIds {
+		frame = append(frame, SampleFrameEntity{CaseId: strconv.Itoa(i + 1), CaseTypeId: caseTypeId,
+			EventCount: i + 2})
+	}
+	return frame
+}
+
+func repeat(value string, count int) []string {
+	values := make([]string, count)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+func TestDrawSample_IsReproducibleWithTheSameSeed(t *testing.T) {
+	frame := newSampleFrame(repeat("CT1", 100)...)
+	sample := config.Sample{Size: 10, Seed: 42}
+
+	first := drawSample(frame, sample)
+	second := drawSample(newSampleFrame(repeat("CT1", 100)...), sample)
+	other := drawSample(newSampleFrame(repeat("CT1", 100)...), config.Sample{Size: 10, Seed: 43})
+
+	assert.Len(t, first.caseIds, 10)
+	assert.Equal(t, first.caseIds, second.caseIds)
+	assert.NotEqual(t, first.caseIds, other.caseIds)
+	assert.IsIncreasing(t, toCaseIdNumbers(first.caseIds))
+	assert.Equal(t, int64(42), first.seed)
+}
+
+func toCaseIdNumbers(caseIds []string) []int64 {
+	numbers := make([]int64, len(caseIds))
+	for i, caseId := range caseIds {
+		numbers[i], _ = strconv.ParseInt(caseId, 10, 64)
+	}
+	return numbers
+}
+
+func TestDrawSample_AllocatesStrataInProportion(t *testing.T) {
+	caseTypeIds := append(append(repeat("CT2", 30), repeat("CT1", 65)...), repeat("CT3", 5)...)
+
+	sample := drawSample(newSampleFrame(caseTypeIds...), config.Sample{Percentage: 10, Seed: 1,
+		Stratify: sampleStratifyCaseType})
+
+	assert.Len(t, sample.caseIds, 10)
+	var allocations []sampleStratum
+	for _, stratum := range sample.strata {
+		allocations = append(allocations, sampleStratum{name: stratum.name, population: stratum.population,
+			sampled: stratum.sampled})
+	}
+	assert.Equal(t, []sampleStratum{
+		{name: "CT1", population: 65, sampled: 7},
+		{name: "CT2", population: 30, sampled: 3},
+		{name: "CT3", population: 5, sampled: 0},
+	}, allocations)
+	for _, caseId := range toCaseIdNumbers(sample.caseIds) {
+		assert.Equal(t, caseTypeIds[caseId-1], sample.stratum[caseId].name)
+	}
+}
+
+func TestDrawSample_TakesEveryCaseWhenTheSampleIsLargerThanThePopulation(t *testing.T) {
+	sample := drawSample(newSampleFrame("CT1", "CT1", "CT2"), config.Sample{Size: 10, Seed: 1})
+
+	assert.Equal(t, []string{"1", "2", "3"}, sample.caseIds)
+}
+
+func TestEventCountBucket(t *testing.T) {
+	bounds := []int{5, 10, 50}
+
+	for eventCount, expected := range map[int]string{
+		2: "events<5", 5: "events 5-9", 9: "events 5-9", 10: "events 10-49", 50: "events>=50", 1000: "events>=50",
+	} {
+		name, _ := eventCountBucket(eventCount, bounds)
+		assert.Equal(t, expected, name, "event count %d", eventCount)
+	}
+
+	name, _ := eventCountBucket(3, nil)
+	assert.Equal(t, sampleTotalStratum, name)
+}
+
+func TestWilsonInterval(t *testing.T) {
+	rate, lower, upper := wilsonInterval(10, 100, 1.959964)
+	assert.Equal(t, 0.1, rate)
+	assert.InDelta(t, 0.0552, lower, 0.0001)
+	assert.InDelta(t, 0.1744, upper, 0.0001)
+
+	rate, lower, upper = wilsonInterval(0, 20, 1.959964)
+	assert.Equal(t, 0.0, rate)
+	assert.Equal(t, 0.0, lower)
+	assert.InDelta(t, 0.1611, upper, 0.0001)
+
+	_, lower, upper = wilsonInterval(0, 0, 1.959964)
+	assert.Equal(t, 0.0, lower)
+	assert.Equal(t, 1.0, upper)
+}
+
+func TestSampleRun_EstimatesEveryStratumAndTheTotal(t *testing.T) {
+	run := newSampleRun()
+	run.setSample(drawSample(newSampleFrame("CT1", "CT1", "CT2", "CT2"), config.Sample{Size: 4, Seed: 1,
+		Stratify: sampleStratifyCaseType}))
+	run.record(map[int64]bool{1: true, 2: false, 3: false})
+	run.record(map[int64]bool{99: true})
+
+	estimates := run.estimate(0.95)
+
+	assert.Len(t, estimates, 3)
+	assert.Equal(t, stratumEstimate{name: "CT1", population: 2, sampled: 2, compared: 2, violating: 1, rate: 0.5,
+		lower: estimates[0].lower, upper: estimates[0].upper}, estimates[0])
+	assert.Equal(t, 1, estimates[1].compared)
+	assert.Equal(t, 0, estimates[1].violating)
+	assert.Equal(t, sampleTotalStratum, estimates[2].name)
+	assert.Equal(t, 4, estimates[2].population)
+	assert.Equal(t, 3, estimates[2].compared)
+	assert.Equal(t, 1, estimates[2].violating)
+	assert.Less(t, estimates[2].lower, 1.0/3)
+	assert.Greater(t, estimates[2].upper, 1.0/3)
+}
+
+func TestWatchedFieldOutcomes(t *testing.T) {
+	outcomes := watchedFieldOutcomes([]string{"1", "2", "3"}, []WatchedFieldChangeEntity{
+		{CaseId: 1, Reference: 11}, {CaseId: 2, Reference: 22},
+	}, map[int64]bool{11: true})
+
+	assert.Equal(t, map[int64]bool{1: true, 2: false, 3: false}, outcomes)
+}
@@ -3,34 +3,53 @@ package domain
 import (
 	"ccd-comparator-data-diff-rapid/comparator"
 	"ccd-comparator-data-diff-rapid/internal/store"
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"sync"
 )
 
 const defaultBatchSize = 100
 
 type SaveRepository interface {
-	saveAllEventDataReport(batchSize int, eventDataTable string, eventDataReportEntities []comparator.
-		EventDataReportEntity) error
+	saveAllEventDataReport(ctx context.Context, batchSize int, eventDataTable string,
+		eventDataReportEntities []comparator.EventDataReportEntity) error
+	// findWatermark returns the watermark of the jurisdiction and case types, or nil before their first incremental
+	// scan.
+	findWatermark(ctx context.Context, watermarkTable, jurisdiction, caseTypeId string) (*Watermark, error)
+	// saveWatermark moves the watermark of the jurisdiction and case types forward to the event.
+	saveWatermark(ctx context.Context, watermarkTable string, watermark Watermark) error
 }
 
 type saveRepository struct {
-	db store.DB
+	db                    store.DB
+	tableColumns          *tableColumns
+	missingColumnsWarning *sync.Once
 }
 
 func NewSaveRepository(db store.DB) SaveRepository {
-	return &saveRepository{db: db}
+	return &saveRepository{db: db, tableColumns: newTableColumns(), missingColumnsWarning: &sync.Once{}}
 }
 
-func (s saveRepository) saveAllEventDataReport(batchSize int, eventDataTable string,
+// reportContextColumns are the columns describing the event of a report row, written when the report table has them.
+var reportContextColumns = append([]string{"state_id"}, eventContextColumns...)
+
+func (s saveRepository) saveAllEventDataReport(ctx context.Context, batchSize int, eventDataTable string,
 	eventDataReportEntities []comparator.EventDataReportEntity) error {
 	totalEntities := len(eventDataReportEntities)
 	if batchSize == 0 {
 		batchSize = defaultBatchSize
 	}
-	tx := s.db.MustBegin()
+	insertQuery, err := s.insertQuery(ctx, eventDataTable)
+	if err != nil {
+		return err
+	}
+	tx, err := beginTransaction(ctx, s.db)
+	if err != nil {
+		return err
+	}
 	for i := 0; i < totalEntities; i += batchSize {
 		end := i + batchSize
 		if end > totalEntities {
@@ -39,16 +58,7 @@ func (s saveRepository) saveAllEventDataReport(batchSize int, eventDataTable str
 
 		batch := eventDataReportEntities[i:end]
 
-		res, err := tx.NamedExec(
-			fmt.Sprintf(`INSERT INTO %s (
-			event_id, event_name, case_type_id, reference, field_name, change_type,
-			old_record, new_record, array_change_record, previous_event_created_date, event_created_date,
-			analyze_result_detail, rule_matched, previous_event_user_id, event_user_id, 
-            event_delta, previous_event_id, previous_event_name)
-		VALUES (:event_id, :event_name, :case_type_id, :reference, :field_name, :change_type, :old_record, :new_record,
-			:array_change_record, :previous_event_created_date, :event_created_date, :analyze_result, :rule_matched, 
-		        :previous_event_user_id, :event_user_id, :event_delta, :previous_event_id, :previous_event_name)`,
-				eventDataTable), batch)
+		res, err := tx.NamedExec(ctx, insertQuery, batch)
 
 		if err != nil {
 			_ = tx.Rollback()
@@ -60,7 +70,7 @@ func (s saveRepository) saveAllEventDataReport(batchSize int, eventDataTable str
 			log.Info().Msgf("%d records persisted successfully", count)
 		}
 	}
-	err := tx.Commit()
+	err = tx.Commit()
 	if err != nil {
 		_ = tx.Rollback()
 		return errors.Wrap(err, "Failed while committing the transaction")
@@ -68,3 +78,92 @@ func (s saveRepository) saveAllEventDataReport(batchSize int, eventDataTable str
 
 	return nil
 }
+
+// insertQuery inserts the report rows, with the reportContextColumns the report table has.
+func (s saveRepository) insertQuery(ctx context.Context, eventDataTable string) (string, error) {
+	columns, err := s.tableColumns.find(ctx, s.db, eventDataTable)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed while reading the report table columns")
+	}
+
+	if missing := missingColumns(columns, reportContextColumns); len(missing) > 0 {
+		s.missingColumnsWarning.Do(func() {
+			log.Warn().Msgf("%s doesn't have the columns %s, they are not written to the report", eventDataTable,
+				missing)
+		})
+	}
+
+	var contextColumns, contextValues string
+	for _, column := range reportContextColumns {
+		if columns[column] {
+			contextColumns += ", " + column
+			contextValues += ", :" + column
+		}
+	}
+
+	return fmt.Sprintf(`INSERT INTO %s (
+			event_id, event_name, case_type_id, reference, field_name, change_type,
+			old_record, new_record, array_change_record, previous_event_created_date, event_created_date,
+			analyze_result_detail, rule_matched, previous_event_user_id, event_user_id, 
+            event_delta, previous_event_id, previous_event_name%s)
+		VALUES (:event_id, :event_name, :case_type_id, :reference, :field_name, :change_type, :old_record, :new_record,
+			:array_change_record, :previous_event_created_date, :event_created_date, :analyze_result, :rule_matched, 
+		        :previous_event_user_id, :event_user_id, :event_delta, :previous_event_id, :previous_event_name%s)`,
+		eventDataTable, contextColumns, contextValues), nil
+}
+
+func (s saveRepository) findWatermark(ctx context.Context, watermarkTable, jurisdiction,
+	caseTypeId string) (*Watermark, error) {
+	var watermarks []Watermark
+
+	err := s.db.Select(ctx, &watermarks, fmt.Sprintf(`SELECT jurisdiction, case_type_id, last_event_created_date,
+			last_event_id FROM %s WHERE jurisdiction = $1 AND case_type_id = $2`, watermarkTable),
+		jurisdiction, caseTypeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed while reading the watermark")
+	}
+	if len(watermarks) == 0 {
+		return nil, nil
+	}
+
+	return &watermarks[0], nil
+}
+
+// saveWatermark never moves the watermark back, in case scans of the same case types overlap.
+func (s saveRepository) saveWatermark(ctx context.Context, watermarkTable string, watermark Watermark) error {
+	tx, err := beginTransaction(ctx, s.db)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NamedExec(ctx, fmt.Sprintf(`INSERT INTO %s AS w (
+			jurisdiction, case_type_id, last_event_created_date, last_event_id, updated_date)
+		VALUES (:jurisdiction, :case_type_id, :last_event_created_date, :last_event_id, now())
+		ON CONFLICT (jurisdiction, case_type_id) DO UPDATE
+			SET last_event_created_date = EXCLUDED.last_event_created_date, last_event_id = EXCLUDED.last_event_id,
+			    updated_date = now()
+			WHERE (w.last_event_created_date, w.last_event_id) <
+			      (EXCLUDED.last_event_created_date, EXCLUDED.last_event_id)`, watermarkTable), watermark)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Wrap(err, "Failed while saving the watermark")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Wrap(err, "Failed while committing the transaction")
+	}
+
+	return nil
+}
+
+// beginTransaction begins a transaction that is rolled back when the context is done before it commits, such as
+// when the run is interrupted.
+func beginTransaction(ctx context.Context, db store.DB) (store.Transaction, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed while beginning the transaction")
+	}
+	return tx, nil
+}
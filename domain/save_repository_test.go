@@ -2,9 +2,13 @@ package domain
 
 import (
 	"ccd-comparator-data-diff-rapid/comparator"
+	"ccd-comparator-data-diff-rapid/internal/store"
+	"context"
+	"database/sql/driver"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"strings"
 	"testing"
 )
 
@@ -24,7 +28,8 @@ func (r result) RowsAffected() (int64, error) {
 func TestSaveAllEventDataReport(t *testing.T) {
 	mockTx := new(MockTransaction)
 	mockDB := new(MockDB)
-	mockDB.On("MustBegin", mock.Anything).Return(mockTx, nil)
+	expectTableColumns(mockDB, "event_data_report", reportContextColumns...)
+	mockDB.On("Begin", mock.Anything).Return(mockTx, nil)
 
 	saveRepo := NewSaveRepository(mockDB)
 
@@ -34,7 +39,7 @@ func TestSaveAllEventDataReport(t *testing.T) {
 
 	eventDataReportEntities := make([]comparator.EventDataReportEntity, 201)
 
-	err := saveRepo.saveAllEventDataReport(0, eventDataReportEntities)
+	err := saveRepo.saveAllEventDataReport(context.Background(), 0, "event_data_report", eventDataReportEntities)
 	assert.NoError(t, err)
 	mockDB.AssertExpectations(t)
 	mockTx.AssertExpectations(t)
@@ -43,7 +48,8 @@ func TestSaveAllEventDataReport(t *testing.T) {
 func TestSaveAllEventDataReportInsertError(t *testing.T) {
 	mockTx := new(MockTransaction)
 	mockDB := new(MockDB)
-	mockDB.On("MustBegin", mock.Anything).Return(mockTx, nil)
+	expectTableColumns(mockDB, "event_data_report", reportContextColumns...)
+	mockDB.On("Begin", mock.Anything).Return(mockTx, nil)
 
 	saveRepo := NewSaveRepository(mockDB)
 
@@ -53,7 +59,7 @@ func TestSaveAllEventDataReportInsertError(t *testing.T) {
 
 	eventDataReportEntities := make([]comparator.EventDataReportEntity, 100)
 
-	err := saveRepo.saveAllEventDataReport(0, eventDataReportEntities)
+	err := saveRepo.saveAllEventDataReport(context.Background(), 0, "event_data_report", eventDataReportEntities)
 	assert.Error(t, err)
 	assert.EqualError(t, err, "Failed while batch inserting report: insert error")
 	mockDB.AssertExpectations(t)
@@ -63,7 +69,8 @@ func TestSaveAllEventDataReportInsertError(t *testing.T) {
 func TestSaveAllEventDataReportCommitError(t *testing.T) {
 	mockTx := new(MockTransaction)
 	mockDB := new(MockDB)
-	mockDB.On("MustBegin", mock.Anything).Return(mockTx, nil)
+	expectTableColumns(mockDB, "event_data_report", reportContextColumns...)
+	mockDB.On("Begin", mock.Anything).Return(mockTx, nil)
 
 	saveRepo := NewSaveRepository(mockDB)
 
@@ -74,9 +81,91 @@ func TestSaveAllEventDataReportCommitError(t *testing.T) {
 
 	eventDataReportEntities := make([]comparator.EventDataReportEntity, 100)
 
-	err := saveRepo.saveAllEventDataReport(0, eventDataReportEntities)
+	err := saveRepo.saveAllEventDataReport(context.Background(), 0, "event_data_report", eventDataReportEntities)
 	assert.Error(t, err)
 	assert.EqualError(t, err, "Failed while committing the transaction: commit error")
 	mockDB.AssertExpectations(t)
 	mockTx.AssertExpectations(t)
 }
+
+func TestSaveAllEventDataReportBeginError(t *testing.T) {
+	mockDB := new(MockDB)
+	expectTableColumns(mockDB, "event_data_report", reportContextColumns...)
+	mockDB.On("Begin", mock.Anything).Return(nil, driver.ErrBadConn)
+
+	saveRepo := NewSaveRepository(mockDB)
+
+	err := saveRepo.saveAllEventDataReport(context.Background(), 0, "event_data_report", make([]comparator.EventDataReportEntity, 1))
+	assert.EqualError(t, err, "Failed while beginning the transaction: driver: bad connection")
+	assert.True(t, store.IsRetryable(err))
+}
+
+func TestSaveAllEventDataReportWritesTheContextColumnsOfTheTable(t *testing.T) {
+	mockTx := new(MockTransaction)
+	mockDB := new(MockDB)
+	expectTableColumns(mockDB, "report.event_data_report", "event_id", "state_id", "summary")
+	mockDB.On("Begin", mock.Anything).Return(mockTx, nil)
+
+	saveRepo := NewSaveRepository(mockDB)
+
+	mockTx.On("NamedExec", mock.MatchedBy(func(query string) bool {
+		return strings.Contains(query, "previous_event_name, state_id, summary)") &&
+			strings.Contains(query, ":previous_event_name, :state_id, :summary)") &&
+			!strings.Contains(query, "proxied_by")
+	}), mock.Anything).Return(result{}, nil).Twice()
+	mockTx.On("Commit").Return(nil).Twice()
+
+	entities := make([]comparator.EventDataReportEntity, 1)
+	assert.NoError(t, saveRepo.saveAllEventDataReport(context.Background(), 0, "report.event_data_report", entities))
+	assert.NoError(t, saveRepo.saveAllEventDataReport(context.Background(), 0, "report.event_data_report", entities))
+	mockDB.AssertExpectations(t)
+	mockTx.AssertExpectations(t)
+}
+
+func TestFindWatermark(t *testing.T) {
+	mockDB := new(MockDB)
+	saveRepo := NewSaveRepository(mockDB)
+
+	watermark := Watermark{Jurisdiction: "J1", CaseTypeId: "CT1", LastEventId: 12}
+	mockDB.On("Select",
+		mock.AnythingOfType("*[]domain.Watermark"),
+		mock.MatchedBy(func(query string) bool {
+			return strings.Contains(query, "FROM comparator_watermark WHERE jurisdiction = $1 AND case_type_id = $2")
+		}),
+		[]interface{}{"J1", "CT1"}).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]Watermark) = []Watermark{watermark}
+		}).Once()
+	mockDB.On("Select", mock.AnythingOfType("*[]domain.Watermark"), mock.Anything, []interface{}{"J1", "CT2"}).
+		Return(nil).Once()
+
+	found, err := saveRepo.findWatermark(context.Background(), "comparator_watermark", "J1", "CT1")
+	assert.NoError(t, err)
+	assert.Equal(t, &watermark, found)
+
+	found, err = saveRepo.findWatermark(context.Background(), "comparator_watermark", "J1", "CT2")
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+	mockDB.AssertExpectations(t)
+}
+
+func TestSaveWatermarkOnlyMovesItForward(t *testing.T) {
+	mockTx := new(MockTransaction)
+	mockDB := new(MockDB)
+	mockDB.On("Begin", mock.Anything).Return(mockTx, nil)
+
+	saveRepo := NewSaveRepository(mockDB)
+
+	watermark := Watermark{Jurisdiction: "J1", CaseTypeId: "CT1", LastEventId: 12}
+	mockTx.On("NamedExec", mock.MatchedBy(func(query string) bool {
+		return strings.Contains(query, "INSERT INTO comparator_watermark AS w") &&
+			strings.Contains(query, "ON CONFLICT (jurisdiction, case_type_id) DO UPDATE") &&
+			strings.Contains(query, "WHERE (w.last_event_created_date, w.last_event_id) <")
+	}), watermark).Return(result{}, nil).Once()
+	mockTx.On("Commit").Return(nil).Once()
+
+	assert.NoError(t, saveRepo.saveWatermark(context.Background(), "comparator_watermark", watermark))
+	mockDB.AssertExpectations(t)
+	mockTx.AssertExpectations(t)
+}
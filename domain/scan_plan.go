@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"encoding/csv"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScanPlanEntry is a row of the source file. Besides the jurisdiction and case type, a row can override the period,
+// the active rules, the thresholds and the case ids of the configuration for its scan. Empty values keep the
+// configured ones.
+type ScanPlanEntry struct {
+	Jurisdiction              string
+	CaseTypeId                string
+	Count                     int64
+	Priority                  int
+	StartTime                 string
+	EndTime                   string
+	Rules                     string
+	CaseIds                   string
+	FieldChangeThreshold      *int
+	ConcurrentThresholdMillis *int64
+	MaxEventProcessCount      *int
+}
+
+// Apply returns a copy of the configurations with the overrides of the entry.
+func (e ScanPlanEntry) Apply(configurations config.Configurations) *config.Configurations {
+	configurations.Jurisdiction = e.Jurisdiction
+	configurations.CaseType = e.CaseTypeId
+	if e.StartTime != "" {
+		configurations.StartTime = e.StartTime
+	}
+	if e.EndTime != "" {
+		configurations.EndTime = e.EndTime
+	}
+	if e.Rules != "" {
+		configurations.Active = e.Rules
+	}
+	if e.CaseIds != "" {
+		configurations.CaseId = e.CaseIds
+		configurations.CaseIdFile = ""
+	}
+	if e.FieldChangeThreshold != nil {
+		configurations.FieldChange.Threshold = *e.FieldChangeThreshold
+	}
+	if e.ConcurrentThresholdMillis != nil {
+		configurations.Concurrent.Event.ThresholdMilliseconds = *e.ConcurrentThresholdMillis
+	}
+	if e.MaxEventProcessCount != nil {
+		configurations.MaxEventProcessCount = *e.MaxEventProcessCount
+	}
+	return &configurations
+}
+
+// ReadScanPlan reads the source file, a CSV with a header row. The jurisdiction and case_type_id columns are
+// required, and count, priority, start_time, end_time, rules, case_ids, field_change_threshold,
+// concurrent_threshold_ms and max_event_process_count are optional. The entries are ordered by descending priority,
+// then by descending count, and keep the file order otherwise. The periods the entries override are resolved as the
+// configured period at now, the time the run started.
+func ReadScanPlan(path string, period config.Period, now time.Time) ([]ScanPlanEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the source file")
+	}
+	defer file.Close()
+
+	entries, err := parseScanPlan(file, period, now)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid source file %s", path)
+	}
+	return entries, nil
+}
+
+func parseScanPlan(input io.Reader, period config.Period, now time.Time) ([]ScanPlanEntry, error) {
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"jurisdiction", "case_type_id"} {
+		if _, found := columns[required]; !found {
+			return nil, errors.Errorf("the %s column is missing", required)
+		}
+	}
+
+	var entries []ScanPlanEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		entry, err := newScanPlanEntry(scanPlanRow{columns: columns, record: record}, period, now)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", line)
+		}
+		if entry.Jurisdiction == "" || entry.CaseTypeId == "" {
+			return nil, errors.Errorf("line %d: the jurisdiction and case_type_id are required", line)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Priority != entries[j].Priority {
+			return entries[i].Priority > entries[j].Priority
+		}
+		return entries[i].Count > entries[j].Count
+	})
+	return entries, nil
+}
+
+type scanPlanRow struct {
+	columns map[string]int
+	record  []string
+}
+
+func (r scanPlanRow) value(column string) string {
+	index, found := r.columns[column]
+	if !found || index >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[index])
+}
+
+func newScanPlanEntry(row scanPlanRow, period config.Period, now time.Time) (ScanPlanEntry, error) {
+	entry := ScanPlanEntry{
+		Jurisdiction: row.value("jurisdiction"),
+		CaseTypeId:   row.value("case_type_id"),
+		StartTime:    row.value("start_time"),
+		EndTime:      row.value("end_time"),
+		Rules:        row.value("rules"),
+		CaseIds:      row.value("case_ids"),
+	}
+
+	if entry.StartTime != "" || entry.EndTime != "" {
+		if _, _, err := entry.Apply(config.Configurations{Period: period}).Period.Resolve(now); err != nil {
+			return entry, err
+		}
+	}
+
+	count, err := parseOptionalInt(row, "count")
+	if err != nil {
+		return entry, err
+	}
+	priority, err := parseOptionalInt(row, "priority")
+	if err != nil {
+		return entry, err
+	}
+	fieldChangeThreshold, err := parseOptionalInt(row, "field_change_threshold")
+	if err != nil {
+		return entry, err
+	}
+	concurrentThreshold, err := parseOptionalInt(row, "concurrent_threshold_ms")
+	if err != nil {
+		return entry, err
+	}
+	maxEventProcessCount, err := parseOptionalInt(row, "max_event_process_count")
+	if err != nil {
+		return entry, err
+	}
+
+	if count != nil {
+		entry.Count = *count
+	}
+	if priority != nil {
+		entry.Priority = int(*priority)
+	}
+	if fieldChangeThreshold != nil {
+		threshold := int(*fieldChangeThreshold)
+		entry.FieldChangeThreshold = &threshold
+	}
+	entry.ConcurrentThresholdMillis = concurrentThreshold
+	if maxEventProcessCount != nil {
+		maxCount := int(*maxEventProcessCount)
+		entry.MaxEventProcessCount = &maxCount
+	}
+
+	return entry, nil
+}
+
+// parseOptionalInt returns nil when the column is missing or empty.
+func parseOptionalInt(row scanPlanRow, column string) (*int64, error) {
+	value := row.value(column)
+	if value == "" {
+		return nil, nil
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", column)
+	}
+	return &number, nil
+}
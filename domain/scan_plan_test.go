@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/config"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testPeriod = config.Period{StartTime: "2023-08-01T00:00:00", Timezone: "Europe/London"}
+	testNow    = time.Date(2023, 8, 3, 12, 0, 0, 0, time.UTC)
+)
+
+func TestParseScanPlan_OrdersEntriesByPriorityThenCount(t *testing.T) {
+	input := "\ufeffJurisdiction,case_type_id,count,priority\n" +
+		"# ignored comment\n" +
+		"J1,CT1,10,\n" +
+		"J1,CT2,30,\n" +
+		"J2,CT3,5,2\n" +
+		"J2,CT5,30,\n"
+
+	entries, err := parseScanPlan(strings.NewReader(input), testPeriod, testNow)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ScanPlanEntry{
+		{Jurisdiction: "J2", CaseTypeId: "CT3", Count: 5, Priority: 2},
+		{Jurisdiction: "J1", CaseTypeId: "CT2", Count: 30},
+		{Jurisdiction: "J2", CaseTypeId: "CT5", Count: 30},
+		{Jurisdiction: "J1", CaseTypeId: "CT1", Count: 10},
+	}, entries)
+}
+
+func TestParseScanPlan_ReadsQuotedOverrides(t *testing.T) {
+	input := "jurisdiction,case_type_id,start_time,end_time,rules,case_ids,field_change_threshold," +
+		"concurrent_threshold_ms,max_event_process_count\n" +
+		`J1,CT1,2023-08-01T00:00:00,2023-08-02T00:00:00,"staticfieldchange,fieldchangecount","1,2",5,1500,200` + "\n"
+
+	entries, err := parseScanPlan(strings.NewReader(input), testPeriod, testNow)
+
+	threshold, maxCount, concurrent := 5, 200, int64(1500)
+	assert.NoError(t, err)
+	assert.Equal(t, []ScanPlanEntry{{
+		Jurisdiction:              "J1",
+		CaseTypeId:                "CT1",
+		StartTime:                 "2023-08-01T00:00:00",
+		EndTime:                   "2023-08-02T00:00:00",
+		Rules:                     "staticfieldchange,fieldchangecount",
+		CaseIds:                   "1,2",
+		FieldChangeThreshold:      &threshold,
+		ConcurrentThresholdMillis: &concurrent,
+		MaxEventProcessCount:      &maxCount,
+	}}, entries)
+}
+
+func TestParseScanPlan_ReturnsErrorWithLineOfInvalidValue(t *testing.T) {
+	_, err := parseScanPlan(strings.NewReader("jurisdiction,case_type_id,count\nJ1,CT1,1\nJ1,CT2,many\n"), testPeriod,
+		testNow)
+	assert.ErrorContains(t, err, "line 3: invalid count")
+
+	_, err = parseScanPlan(strings.NewReader("jurisdiction,case_type_id,start_time\nJ1,CT1,someday\n"), testPeriod,
+		testNow)
+	assert.ErrorContains(t, err, "line 2: invalid period start time")
+
+	_, err = parseScanPlan(strings.NewReader("jurisdiction,case_type_id,count\nJ1,CT1,1\n,CT4,100\n"), testPeriod,
+		testNow)
+	assert.ErrorContains(t, err, "line 3: the jurisdiction and case_type_id are required")
+}
+
+func TestParseScanPlan_ResolvesThePeriodInTheConfiguredTimezoneWhenTheRunStarted(t *testing.T) {
+	entries, err := parseScanPlan(strings.NewReader("jurisdiction,case_type_id,start_time\nJ1,CT1,2023-08-03T12:30:00\n"),
+		testPeriod, testNow)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = parseScanPlan(strings.NewReader("jurisdiction,case_type_id,start_time\nJ1,CT1,2023-08-03T13:30:00\n"),
+		testPeriod, testNow)
+	assert.ErrorContains(t, err, "line 2: the period start 2023-08-03T12:30:00Z is not before its end")
+}
+
+func TestParseScanPlan_ReturnsErrorWhenRequiredColumnIsMissing(t *testing.T) {
+	_, err := parseScanPlan(strings.NewReader("jurisdiction,count\nJ1,1\n"), testPeriod, testNow)
+
+	assert.EqualError(t, err, "the case_type_id column is missing")
+}
+
+func TestScanPlanEntry_ApplyOverridesTheConfigurations(t *testing.T) {
+	configurations := config.Configurations{}
+	configurations.StartTime = "2023-01-01T00:00:00"
+	configurations.EndTime = "2023-02-01T00:00:00"
+	configurations.Active = "staticfieldchange"
+	configurations.CaseIdFile = "case_ids.txt"
+	configurations.MaxEventProcessCount = 100
+	threshold := 7
+
+	applied := ScanPlanEntry{Jurisdiction: "J1", CaseTypeId: "CT1", EndTime: "2023-01-15T00:00:00",
+		CaseIds: "1,2", FieldChangeThreshold: &threshold}.Apply(configurations)
+
+	assert.Equal(t, "J1", applied.Jurisdiction)
+	assert.Equal(t, "CT1", applied.CaseType)
+	assert.Equal(t, "2023-01-01T00:00:00", applied.StartTime)
+	assert.Equal(t, "2023-01-15T00:00:00", applied.EndTime)
+	assert.Equal(t, "staticfieldchange", applied.Active)
+	assert.Equal(t, "1,2", applied.CaseId)
+	assert.Empty(t, applied.CaseIdFile)
+	assert.Equal(t, 7, applied.FieldChange.Threshold)
+	assert.Equal(t, 100, applied.MaxEventProcessCount)
+	assert.Empty(t, configurations.CaseType)
+}
@@ -4,29 +4,54 @@ import (
 	"ccd-comparator-data-diff-rapid/comparator"
 	"ccd-comparator-data-diff-rapid/config"
 	"ccd-comparator-data-diff-rapid/helper"
+	"context"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	defaultDiscoveryPageSize = 10000
+	defaultReportBufferSize  = 1000
+)
+
 type Service struct {
-	configuration *config.Configurations
-	activeRules   *[]comparator.Rule
-	queryRepo     QueryRepository
-	saveRepo      SaveRepository
+	configuration   *config.Configurations
+	activeRules     *[]comparator.Rule
+	activeCaseRules *[]comparator.CaseRule
+	eventSource     EventSource
+	saveRepo        SaveRepository
+	retrier         *retrier
+	deadLetters     *deadLetterFile
+	sampling        *sampleRun
+	incremental     *incrementalRun
+	checkpoints     *Checkpoints
+	summary         *RunSummary
 }
 
+// NewService creates the service. The batches are checkpointed unless checkpoints is nil, and totalled in the summary
+// unless it is nil.
 func NewService(configuration *config.Configurations, activeRules *[]comparator.Rule,
-	queryRepo QueryRepository, saveRepo SaveRepository) *Service {
+	activeCaseRules *[]comparator.CaseRule, eventSource EventSource, saveRepo SaveRepository,
+	checkpoints *Checkpoints, summary *RunSummary) *Service {
 	return &Service{
-		configuration: configuration,
-		activeRules:   activeRules,
-		queryRepo:     queryRepo,
-		saveRepo:      saveRepo,
+		configuration:   configuration,
+		activeRules:     activeRules,
+		activeCaseRules: activeCaseRules,
+		eventSource:     eventSource,
+		saveRepo:        saveRepo,
+		retrier:         newRetrier(configuration.Database.Retry),
+		deadLetters:     newDeadLetterFile(configuration.Scan.DeadLetterFile),
+		checkpoints:     checkpoints,
+		summary:         summary,
 	}
 }
 
@@ -35,12 +60,55 @@ type Comparison struct {
 	CaseTypeId          string
 	StartTime           time.Time
 	SearchPeriodEndTime time.Time
+	EventFilter         config.EventFilter
+	// AfterEventId leaves the events created at StartTime up to this id out of the period, and UntilEventId the
+	// events created at SearchPeriodEndTime after this id, so that consecutive periods don't share any event.
+	AfterEventId int64
+	UntilEventId int64
+	// Incremental scans compare the cases with a single event in the period too, against their baseline, as the
+	// previous scan compared the earlier events.
+	Incremental bool
+}
+
+// includesEvent reports whether the event is within the period.
+func (c Comparison) includesEvent(createdDate time.Time, eventId int64) bool {
+	return !createdDate.Before(c.StartTime) && !createdDate.After(c.SearchPeriodEndTime) &&
+		(createdDate.After(c.StartTime) || eventId > c.AfterEventId) &&
+		(createdDate.Before(c.SearchPeriodEndTime) || eventId <= c.untilEventId())
+}
+
+// precedesEvent reports whether the event is before the period, where the baseline is taken from.
+func (c Comparison) precedesEvent(createdDate time.Time, eventId int64) bool {
+	return createdDate.Before(c.StartTime) || (createdDate.Equal(c.StartTime) && eventId <= c.AfterEventId)
+}
+
+// untilEventId returns UntilEventId, or the largest id when the period isn't narrowed.
+func (c Comparison) untilEventId() int64 {
+	if c.UntilEventId == 0 {
+		return math.MaxInt64
+	}
+	return c.UntilEventId
 }
 
 type comparisonWork struct {
 	transactionId string
 	comparison    Comparison
 	caseIds       []string
+	// savedEventId is the last event of the isolated case of the work whose report is saved.
+	savedEventId int64
+}
+
+// withoutCaseIds returns the work without the cases, such as the cases whose report is already saved.
+func (w comparisonWork) withoutCaseIds(caseIds map[int64]bool) comparisonWork {
+	remaining := make([]string, 0, len(w.caseIds))
+	for _, caseId := range w.caseIds {
+		id, err := strconv.ParseInt(caseId, 10, 64)
+		if err != nil || !caseIds[id] {
+			remaining = append(remaining, caseId)
+		}
+	}
+	w.caseIds = remaining
+	return w
 }
 
 type comparisonResult struct {
@@ -49,7 +117,150 @@ type comparisonResult struct {
 	err           error
 }
 
-func (s Service) CompareEventsInImpactPeriod(comparison Comparison) {
+// CompareEventsInImpactPeriod compares the cases of the comparison. No more batches are dispatched once ctx is done,
+// and the batches in progress are cancelled if they don't finish within the shutdown timeout.
+func (s Service) CompareEventsInImpactPeriod(ctx context.Context, comparison Comparison) {
+	if s.configuration.Sample.IsEnabled() {
+		s.sampling = newSampleRun()
+	}
+
+	s.runComparisonWorkers(ctx, func(workers chan<- comparisonWork) {
+		s.dispatchComparisonWork(ctx, comparison, workers)
+	})
+
+	s.sampling.logSummary(s.configuration.Sample.ConfidenceLevel)
+}
+
+// CompareEventsSinceWatermark compares the events created after the watermark of the jurisdiction and case types, or
+// from the start of the comparison before their first incremental scan, up to their latest event. The scan ends the
+// configured lag before the run started at the latest, so that a resumed run ends where the interrupted run did. The
+// watermark then moves to that event, unless events of a failed batch would be neither compared nor retried from the
+// dead letter file, or the scan is interrupted, in which case the next scan starts from the same watermark. Scans
+// failing other than by the interruption or the batches, which are recorded as they fail, are recorded in the run
+// summary.
+func (s Service) CompareEventsSinceWatermark(ctx context.Context, comparison Comparison,
+	runStartedAt time.Time) (err error) {
+	defer func() {
+		if err != nil && ctx.Err() == nil && !s.incremental.hasLostEvents() {
+			s.summary.recordFailedScan()
+		}
+	}()
+
+	caseIdConfig, err := s.readConfiguredCaseIdentifiers()
+	if err != nil {
+		return err
+	}
+	if caseIdConfig != "" || s.configuration.Sample.IsEnabled() {
+		return errors.New("incremental scans compare every case with new events, without configured case ids " +
+			"or sampling")
+	}
+
+	watermarkTable := s.configuration.Database.WatermarkTable
+
+	var watermark, latest *Watermark
+	err = s.retrier.do(ctx, "Reading the watermark", func() error {
+		var err error
+		watermark, err = s.saveRepo.findWatermark(ctx, watermarkTable, comparison.Jurisdiction, comparison.CaseTypeId)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// Events created before the end may still be committed, or replicated, after the scan, and would fall behind the
+	// watermark
+	lagEnd := runStartedAt.Add(-time.Duration(s.configuration.IncrementalLagSeconds) * time.Second).UTC()
+	if comparison.SearchPeriodEndTime.After(lagEnd) {
+		comparison.SearchPeriodEndTime = lagEnd
+	}
+	if watermark != nil {
+		comparison.StartTime = watermark.LastEventCreatedDate
+		comparison.AfterEventId = watermark.LastEventId
+		log.Info().Msgf("Scanning jurisdiction: %s and caseType: %s after the watermark event %d created at %s",
+			comparison.Jurisdiction, comparison.CaseTypeId, watermark.LastEventId,
+			helper.FormatTimeStamp(watermark.LastEventCreatedDate))
+	}
+
+	err = s.retrier.do(ctx, "Finding the latest event", func() error {
+		var err error
+		latest, err = s.eventSource.findLatestEvent(ctx, comparison)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if latest == nil || (watermark != nil && !watermark.precedes(*latest)) {
+		log.Info().Msgf("There are no new events for jurisdiction: %s and caseType: %s", comparison.Jurisdiction,
+			comparison.CaseTypeId)
+		return nil
+	}
+
+	comparison.SearchPeriodEndTime = latest.LastEventCreatedDate
+	comparison.UntilEventId = latest.LastEventId
+	comparison.Incremental = true
+
+	s.incremental = newIncrementalRun()
+	s.CompareEventsInImpactPeriod(ctx, comparison)
+	if s.incremental.hasLostEvents() {
+		return errors.Errorf("the watermark of jurisdiction: %s and caseType: %s is kept as events couldn't be "+
+			"compared", comparison.Jurisdiction, comparison.CaseTypeId)
+	}
+
+	err = s.retrier.do(ctx, "Saving the watermark", func() error {
+		return s.saveRepo.saveWatermark(ctx, watermarkTable, *latest)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("The watermark of jurisdiction: %s and caseType: %s moved to the event %d created at %s",
+		comparison.Jurisdiction, comparison.CaseTypeId, latest.LastEventId,
+		helper.FormatTimeStamp(latest.LastEventCreatedDate))
+	return nil
+}
+
+// RetryFailedBatches compares the batches recorded in the dead letter file again. The file is moved aside first so
+// that the batches failing again are recorded in a new one, together with the batches left when ctx is done.
+func (s Service) RetryFailedBatches(ctx context.Context) error {
+	path := s.deadLetters.path
+	if path == "" {
+		return errors.New("the dead letter file is not configured")
+	}
+
+	works, err := readFailedBatches(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Info().Msgf("There are no failed batches to retry in %s", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	retriedPath := path + ".retried"
+	if err := os.Rename(path, retriedPath); err != nil {
+		return errors.Wrap(err, "failed to move the dead letter file aside")
+	}
+	log.Info().Msgf("Retrying %d failed batches, the previous dead letter file is kept as %s", len(works),
+		retriedPath)
+
+	s.runComparisonWorkers(ctx, func(workers chan<- comparisonWork) {
+		for i, w := range works {
+			if !sendWork(ctx, workers, w) {
+				for _, undispatched := range works[i:] {
+					s.recordInterruptedBatch(undispatched)
+				}
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// runComparisonWorkers runs the dispatched work until ctx is done. The workers compare their batches with a context
+// of their own, so that the batches in progress can finish once ctx is done.
+func (s Service) runComparisonWorkers(ctx context.Context, dispatch func(workers chan<- comparisonWork)) {
+	workCtx, cancel := shutdownContext(ctx,
+		time.Duration(s.configuration.Worker.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+
 	resultChan := make(chan comparisonResult)
 	defer func() {
 		close(resultChan)
@@ -57,7 +268,7 @@ func (s Service) CompareEventsInImpactPeriod(comparison Comparison) {
 
 	processResults(resultChan)
 
-	s.startComparisonWorkers(comparison, resultChan)
+	s.startComparisonWorkers(ctx, workCtx, dispatch, resultChan)
 }
 
 func processResults(resultChan <-chan comparisonResult) {
@@ -72,34 +283,81 @@ func processResults(resultChan <-chan comparisonResult) {
 	}()
 }
 
-func (s Service) startComparisonWorkers(comparison Comparison, resultChan chan<- comparisonResult) {
+func (s Service) startComparisonWorkers(ctx, workCtx context.Context, dispatch func(workers chan<- comparisonWork),
+	resultChan chan<- comparisonResult) {
 	var wg sync.WaitGroup
-	s.processComparisonWork(&wg, comparison, resultChan)
+	var oversizedWg sync.WaitGroup
+
+	oversizedCases := make(chan oversizedCase, s.configuration.Worker.Pool)
+	oversizedWg.Add(1)
+	go s.compareOversizedCases(ctx, workCtx, &oversizedWg, oversizedCases, resultChan)
+
+	s.processComparisonWork(ctx, workCtx, &wg, dispatch, oversizedCases, resultChan)
 	wg.Wait()
+
+	close(oversizedCases)
+	oversizedWg.Wait()
 }
 
-func (s Service) processComparisonWork(wg *sync.WaitGroup, comparison Comparison, resultChan chan<- comparisonResult) {
+func (s Service) processComparisonWork(ctx, workCtx context.Context, wg *sync.WaitGroup,
+	dispatch func(workers chan<- comparisonWork), oversizedCases chan<- oversizedCase,
+	resultChan chan<- comparisonResult) {
 	numberOfWorker := s.configuration.Worker.Pool
 	workers := make(chan comparisonWork, numberOfWorker)
 	defer closeWorkers(workers)
 
-	caseIdConfig := strings.TrimSpace(s.configuration.Scan.CaseId)
+	for wid := 1; wid <= numberOfWorker; wid++ {
+		wg.Add(1)
+		go s.compareAndSaveEvents(ctx, workCtx, wid, wg, workers, oversizedCases, resultChan)
+	}
 
-	var caseIds []string
+	dispatch(workers)
+}
 
-	if caseIdConfig != "" {
-		caseIds = strings.Split(caseIdConfig, ",")
-	} else {
-		var err error
-		caseIds, err = s.retrieveCaseIdsByEvents(comparison)
-		if err != nil {
-			log.Error().Msgf("Couldn't retrieve caseIds. ERROR: %s", err)
-			return
+// dispatchComparisonWork sends the configured cases, or the cases found by the period search, to the workers in
+// batches, until ctx is done.
+func (s Service) dispatchComparisonWork(ctx context.Context, comparison Comparison, workers chan<- comparisonWork) {
+	dispatcher := newBatchDispatcher(ctx, comparison, s.configuration.Scan.BatchSize, workers)
+
+	caseIdConfig, err := s.readConfiguredCaseIdentifiers()
+	if err != nil {
+		log.Error().Msgf("Couldn't read configured caseIds. ERROR: %s", err)
+	} else if caseIdConfig != "" {
+		if s.sampling != nil {
+			log.Warn().Msg("Sampling applies to the cases found by the period search, comparing every configured case")
+		}
+		discoveryStart := time.Now()
+		caseIds, err := s.resolveCaseIdentifiers(ctx, caseIdConfig)
+		s.summary.timeStage(stageDiscovery, discoveryStart)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Msgf("Couldn't resolve case references. ERROR: %s", err)
+			s.summary.recordFailedScan()
+			s.incremental.markLostEvents()
 		}
+		sortCaseIds(caseIds)
+		dispatcher.add(s.checkpoints.remaining(comparison, caseIds))
+	} else if s.sampling != nil {
+		if err := s.dispatchSample(ctx, comparison, dispatcher); err != nil && ctx.Err() == nil {
+			log.Error().Msgf("Couldn't sample caseIds. ERROR: %s", err)
+			s.summary.recordFailedScan()
+			s.incremental.markLostEvents()
+		}
+	} else if err := s.dispatchCaseIdsByEvents(ctx, comparison, dispatcher); err != nil && ctx.Err() == nil {
+		log.Error().Msgf("Couldn't retrieve caseIds. ERROR: %s", err)
+		s.summary.recordFailedScan()
+		s.incremental.markLostEvents()
 	}
+	dispatcher.flush()
+	s.summary.recordDispatchedCases(dispatcher.dispatchedCount)
 
-	countOfCaseIds := len(caseIds)
-	if countOfCaseIds == 0 {
+	if ctx.Err() != nil {
+		log.Warn().Msgf("Stopped dispatching the cases of jurisdiction: %s and caseType: %s after %d cases as the "+
+			"run was interrupted", comparison.Jurisdiction, comparison.CaseTypeId, dispatcher.dispatchedCount)
+		s.incremental.markLostEvents()
+		return
+	}
+
+	if dispatcher.caseCount == 0 {
 		log.Warn().Msgf("Couldn't retrieved any case: start period: %s, "+
 			"end period: %s with jurisdiction: %s and caseType: %s",
 			helper.FormatTimeStamp(comparison.StartTime), helper.FormatTimeStamp(comparison.SearchPeriodEndTime),
@@ -109,30 +367,70 @@ func (s Service) processComparisonWork(wg *sync.WaitGroup, comparison Comparison
 
 	log.Info().Msgf("'%d' case retrieved: start period: %s, "+
 		"end period: %s with jurisdiction: %s and caseType: %s",
-		countOfCaseIds, helper.FormatTimeStamp(comparison.StartTime), helper.FormatTimeStamp(comparison.SearchPeriodEndTime),
-		comparison.Jurisdiction, comparison.CaseTypeId)
+		dispatcher.caseCount, helper.FormatTimeStamp(comparison.StartTime),
+		helper.FormatTimeStamp(comparison.SearchPeriodEndTime), comparison.Jurisdiction, comparison.CaseTypeId)
+}
 
-	for wid := 1; wid <= numberOfWorker; wid++ {
-		wg.Add(1)
-		go s.compareAndSaveEvents(wid, wg, workers, resultChan)
+// readConfiguredCaseIdentifiers returns the case identifiers set in scan.caseId followed by the ones listed in
+// scan.caseIdFile.
+func (s Service) readConfiguredCaseIdentifiers() (string, error) {
+	identifiers := strings.TrimSpace(s.configuration.Scan.CaseId)
+
+	caseIdFile := strings.TrimSpace(s.configuration.Scan.CaseIdFile)
+	if caseIdFile == "" {
+		return identifiers, nil
 	}
 
-	batchSize := s.configuration.Scan.BatchSize
-	for i := 0; i < countOfCaseIds; i += batchSize {
-		end := i + batchSize
-		if end > countOfCaseIds {
-			end = countOfCaseIds
-		}
+	fileIdentifiers, err := readCaseIdentifierFile(caseIdFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(identifiers + "\n" + fileIdentifiers), nil
+}
 
-		batch := caseIds[i:end]
+// resolveCaseIdentifiers converts the configured case references and ids into case_data ids. Invalid values and
+// references that are not found are logged and skipped.
+func (s Service) resolveCaseIdentifiers(ctx context.Context, values string) ([]string, error) {
+	identifiers := parseCaseIdentifiers(values)
+	for _, invalid := range identifiers.invalid {
+		log.Warn().Msgf("Skipping invalid case identifier '%s'", invalid)
+	}
 
-		transactionId := uuid.New().String()
-		workers <- comparisonWork{
-			transactionId: transactionId,
-			caseIds:       batch,
-			comparison:    comparison,
+	caseIds := identifiers.caseIds
+	if len(identifiers.references) == 0 {
+		return caseIds, nil
+	}
+
+	var caseIdsByReference map[string]string
+	err := s.retrier.do(ctx, "Resolving case references", func() error {
+		var err error
+		caseIdsByReference, err = s.eventSource.findCaseIdsByReferences(ctx, identifiers.references)
+		return err
+	})
+	if err != nil {
+		return caseIds, err
+	}
+
+	for _, reference := range identifiers.references {
+		caseId, found := caseIdsByReference[reference]
+		if !found {
+			log.Warn().Msgf("Case reference '%s' doesn't exist, skipping", reference)
+			continue
 		}
+		caseIds = append(caseIds, caseId)
 	}
+
+	return caseIds, nil
+}
+
+// sortCaseIds sorts the case ids numerically, so that every batch holds a range of the dispatched cases.
+func sortCaseIds(caseIds []string) {
+	sort.SliceStable(caseIds, func(i, j int) bool {
+		first, _ := strconv.ParseInt(caseIds[i], 10, 64)
+		second, _ := strconv.ParseInt(caseIds[j], 10, 64)
+		return first < second
+	})
 }
 
 func closeWorkers(workers chan comparisonWork) {
@@ -140,66 +438,597 @@ func closeWorkers(workers chan comparisonWork) {
 	close(workers)
 }
 
-func (s Service) retrieveCaseIdsByEvents(comparison Comparison) ([]string, error) {
-	return s.queryRepo.findCasesByEventsInImpactPeriod(comparison)
+// dispatchCaseIdsByEvents pages through the matching case ids in id order and hands them to the dispatcher page by
+// page, so workers start on the first batches while discovery is still running.
+func (s Service) dispatchCaseIdsByEvents(ctx context.Context, comparison Comparison,
+	dispatcher *batchDispatcher) error {
+	pageSize := s.configuration.Scan.DiscoveryPageSize
+	if pageSize <= 0 {
+		pageSize = defaultDiscoveryPageSize
+	}
+
+	var lastCaseId int64
+	for {
+		var caseIds []string
+		discoveryStart := time.Now()
+		err := s.retrier.do(ctx, "Case discovery", func() error {
+			var err error
+			caseIds, err = s.eventSource.findCasesByEventsInImpactPeriod(ctx, comparison, lastCaseId, pageSize)
+			return err
+		})
+		s.summary.timeStage(stageDiscovery, discoveryStart)
+		if err != nil {
+			return err
+		}
+
+		dispatcher.add(s.checkpoints.remaining(comparison, caseIds))
+		log.Debug().Msgf("Discovered %d cases after case id %d", len(caseIds), lastCaseId)
+
+		if len(caseIds) < pageSize {
+			return nil
+		}
+
+		lastCaseId, err = strconv.ParseInt(caseIds[len(caseIds)-1], 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "invalid case id returned by case discovery")
+		}
+	}
+}
+
+// dispatchSample pages through the cases found by the period search like dispatchCaseIdsByEvents, and hands the
+// sample drawn from all of them to the dispatcher.
+func (s Service) dispatchSample(ctx context.Context, comparison Comparison, dispatcher *batchDispatcher) error {
+	pageSize := s.configuration.Scan.DiscoveryPageSize
+	if pageSize <= 0 {
+		pageSize = defaultDiscoveryPageSize
+	}
+
+	var frame []SampleFrameEntity
+	var lastCaseId int64
+	for {
+		var page []SampleFrameEntity
+		discoveryStart := time.Now()
+		err := s.retrier.do(ctx, "Sample discovery", func() error {
+			var err error
+			page, err = s.eventSource.findSampleFrame(ctx, comparison, lastCaseId, pageSize)
+			return err
+		})
+		s.summary.timeStage(stageDiscovery, discoveryStart)
+		if err != nil {
+			return err
+		}
+
+		frame = append(frame, page...)
+		if len(page) < pageSize {
+			break
+		}
+
+		lastCaseId, err = strconv.ParseInt(page[len(page)-1].CaseId, 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "invalid case id returned by sample discovery")
+		}
+	}
+
+	sample := drawSample(frame, s.configuration.Sample)
+	s.sampling.setSample(sample)
+	log.Info().Msgf("Sampled %d of %d cases in %d strata with seed %d", len(sample.caseIds), len(frame),
+		len(sample.strata), sample.seed)
+
+	dispatcher.add(s.checkpoints.remaining(comparison, sample.caseIds))
+	return nil
+}
+
+// batchDispatcher groups case ids into batches of batchSize and sends each full batch to the workers. The batches
+// are dropped once ctx is done.
+type batchDispatcher struct {
+	ctx             context.Context
+	comparison      Comparison
+	batchSize       int
+	workers         chan<- comparisonWork
+	pending         []string
+	caseCount       int
+	dispatchedCount int
+}
+
+func newBatchDispatcher(ctx context.Context, comparison Comparison, batchSize int,
+	workers chan<- comparisonWork) *batchDispatcher {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &batchDispatcher{
+		ctx:        ctx,
+		comparison: comparison,
+		batchSize:  batchSize,
+		workers:    workers,
+	}
+}
+
+func (d *batchDispatcher) add(caseIds []string) {
+	d.caseCount += len(caseIds)
+	d.pending = append(d.pending, caseIds...)
+	for len(d.pending) >= d.batchSize {
+		d.send(d.pending[:d.batchSize])
+		d.pending = d.pending[d.batchSize:]
+	}
+}
+
+func (d *batchDispatcher) flush() {
+	if len(d.pending) > 0 {
+		d.send(d.pending)
+		d.pending = nil
+	}
+}
+
+func (d *batchDispatcher) send(batch []string) {
+	caseIds := make([]string, len(batch))
+	copy(caseIds, batch)
+
+	w := comparisonWork{
+		transactionId: uuid.New().String(),
+		caseIds:       caseIds,
+		comparison:    d.comparison,
+	}
+	if sendWork(d.ctx, d.workers, w) {
+		d.dispatchedCount += len(caseIds)
+	}
+}
+
+// sendWork hands the work to a worker, unless ctx is done first.
+func sendWork(ctx context.Context, workers chan<- comparisonWork, w comparisonWork) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	select {
+	case workers <- w:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
-func (s Service) compareAndSaveEvents(workerId int, wg *sync.WaitGroup, workers <-chan comparisonWork, resultChan chan<- comparisonResult) {
+// compareAndSaveEvents compares the batches handed to the worker with workCtx. The batches still queued once ctx is
+// done are not started.
+func (s Service) compareAndSaveEvents(ctx, workCtx context.Context, workerId int, wg *sync.WaitGroup,
+	workers <-chan comparisonWork, oversizedCases chan<- oversizedCase, resultChan chan<- comparisonResult) {
 	defer func(id int) {
 		log.Info().Msgf("Worker %d has completed its work and is being deferred.", id)
 		wg.Done()
 	}(workerId)
 
 	for w := range workers {
-		logEventComparisonStart(workerId, w)
-		cases, err := s.queryRepo.findCasesByJurisdictionInImpactPeriod(w.caseIds)
-		if err != nil {
-			handleError(resultChan, w.transactionId, err, "finding cases")
+		if ctx.Err() != nil {
+			s.recordInterruptedBatch(w)
 			continue
 		}
-
-		if len(cases) == 0 {
-			noDataMessage := fmt.Sprintf("No case data returned for jurisdiction: %s with caseTypeId: %s",
-				w.comparison.Jurisdiction, w.comparison.CaseTypeId)
-			sendResult(resultChan, w.transactionId, noDataMessage)
-			continue
+		logEventComparisonStart(workerId, w)
+		for _, oversized := range s.compareWork(workCtx, w, resultChan) {
+			oversizedCases <- oversized
 		}
+	}
+}
 
-		if s.configuration.MaxEventProcessCount > 0 && len(cases) > s.configuration.MaxEventProcessCount {
-			dataExceeded := fmt.Sprintf("tid:%s - The threshold for event count (%d) has been exceeded for caseId: %s."+
-				"The process will proceed uninterrupted...", w.transactionId, len(cases), w.caseIds)
-			log.Warn().Msg(dataExceeded)
-		}
-		logParsingCaseData(w.transactionId, w.comparison.Jurisdiction, w.comparison.CaseTypeId, len(cases))
+// compareWork compares the batch and records the cases whose report isn't saved in the dead letter file when it
+// fails. Only its reads and its report transactions are run again after transient errors, so that the report and the
+// results are written once.
+func (s Service) compareWork(ctx context.Context, w comparisonWork,
+	resultChan chan<- comparisonResult) []oversizedCase {
+	var isolatedCases []oversizedCase
+	unsaved := w
+	var err error
+	if watchedFields := normalizeWatchedFields(s.configuration.WatchedFields); len(watchedFields) > 0 {
+		err = s.compareWatchedFields(ctx, w, watchedFields, resultChan)
+	} else {
+		isolatedCases, unsaved, err = s.compareCases(ctx, w, resultChan)
+	}
+	if err != nil {
+		s.recordBatchError(ctx, unsaved, err, resultChan, "comparing the batch")
+		return nil
+	}
 
-		casesWithEventDetails := getCasesWithEventDetails(cases)
+	pendingCaseIds := make([]int64, 0, len(isolatedCases))
+	for _, isolated := range isolatedCases {
+		pendingCaseIds = append(pendingCaseIds, isolated.caseId)
+	}
+	s.recordCompletedBatch(w, pendingCaseIds)
+	return isolatedCases
+}
 
-		logEventComparisonStarted(w.transactionId)
+func (s Service) recordCompletedBatch(w comparisonWork, pendingCaseIds []int64) {
+	s.summary.recordCompletedBatch()
+	s.checkpointBatch(w, pendingCaseIds)
+}
 
-		// Compare events by case reference
+// checkpointBatch checkpoints the batch, so that a resumed run doesn't compare it again.
+func (s Service) checkpointBatch(w comparisonWork, pendingCaseIds []int64) {
+	if err := s.checkpoints.complete(w, pendingCaseIds); err != nil {
+		log.Error().Msgf("tid:%s - Couldn't checkpoint the batch with caseIds: %s. ERROR: %s", w.transactionId,
+			w.caseIds, err)
+	}
+}
+
+// checkpointCases checkpoints the cases of the batch whose report is saved, so that a resumed run doesn't compare
+// them again.
+func (s Service) checkpointCases(w comparisonWork, caseIds []int64) {
+	if err := s.checkpoints.completeCases(w, caseIds); err != nil {
+		log.Error().Msgf("tid:%s - Couldn't checkpoint the caseIds: %v. ERROR: %s", w.transactionId, caseIds, err)
+	}
+}
+
+func (s Service) recordFailedBatch(w comparisonWork, cause error) {
+	if s.deadLetters.path == "" {
+		s.incremental.markLostEvents()
+	}
+	if err := s.deadLetters.write(w, cause); err != nil {
+		log.Error().Msgf("tid:%s - Couldn't record the failed batch with caseIds: %s. ERROR: %s", w.transactionId,
+			w.caseIds, err)
+		s.incremental.markLostEvents()
+		return
+	}
+	log.Warn().Msgf("tid:%s - The failed batch has been recorded in %s", w.transactionId, s.deadLetters.path)
+	if s.deadLetters.path != "" {
+		s.checkpointBatch(w, nil)
+	}
+}
+
+// recordBatchError records the batch as interrupted when ctx is done, as its queries were cancelled, and as failed
+// otherwise.
+func (s Service) recordBatchError(ctx context.Context, w comparisonWork, err error,
+	resultChan chan<- comparisonResult, description string) {
+	if ctx.Err() != nil {
+		s.recordInterruptedBatch(w)
+		return
+	}
+
+	handleError(resultChan, w.transactionId, err, description)
+	s.summary.recordFailedBatch()
+	s.recordFailedBatch(w, err)
+}
+
+// recordInterruptedBatch leaves the batch the interruption stopped to the resumed run when the run is checkpointed,
+// and records it in the dead letter file otherwise.
+func (s Service) recordInterruptedBatch(w comparisonWork) {
+	s.summary.recordInterruptedBatch()
+	if !s.checkpoints.isEnabled() {
+		s.recordFailedBatch(w, errRunInterrupted)
+		return
+	}
+
+	log.Warn().Msgf("tid:%s - The batch with caseIds: %s is left to the resumed run as the run was interrupted",
+		w.transactionId, w.caseIds)
+	s.incremental.markLostEvents()
+}
+
+func (s Service) caseLimits() caseLimits {
+	return caseLimits{
+		maxEventCount:   s.configuration.MaxEventProcessCount,
+		maxPayloadBytes: s.configuration.MaxCasePayloadBytes,
+	}
+}
+
+// compareCases streams the cases of the work one at a time through the comparator and the rules, so only the
+// events of the current case are held in memory. Their report rows are saved whenever they fill the report buffer,
+// and the rest at the end of the batch. Oversized cases are either recorded as skipped or returned to be compared on
+// their own. When it fails, it returns the work of the cases whose report isn't saved.
+func (s Service) compareCases(ctx context.Context, w comparisonWork,
+	resultChan chan<- comparisonResult) ([]oversizedCase, comparisonWork, error) {
+	var reportEntities []comparator.EventDataReportEntity
+	var isolatedCases []oversizedCase
+	var caseCount, eventCount, analyzeResultSize, fieldChangeCount int
+	outcomes := make(map[int64]bool)
+	totals := newComparisonTotals()
+	streamed := make(map[int64]bool)
+	var unsavedCaseIds []int64
+	savedCaseIds := make(map[int64]bool)
+	var saveErr error
+
+	// The rows of the cases compared are saved once they fill the buffer, so that the rows of the whole batch are
+	// never held in memory. The cases they cover are checkpointed as done, so that neither a resumed run nor the
+	// dead letter file compares them again when the rest of the batch is interrupted or fails.
+	bufferSize := s.configuration.ReportBufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultReportBufferSize
+	}
+	saveReportEntities := func() error {
+		if len(reportEntities) < bufferSize {
+			return nil
+		}
+		defer totals.timeStage(stageSave, time.Now())
+		if err := s.saveReport(ctx, w.transactionId, reportEntities); err != nil {
+			return err
+		}
+		reportEntities = nil
+		s.checkpointCases(w, unsavedCaseIds)
+		for _, caseId := range unsavedCaseIds {
+			savedCaseIds[caseId] = true
+		}
+		unsavedCaseIds = nil
+		return nil
+	}
+
+	compareCase := func(caseEvents []CaseDataEntity) error {
+		defer totals.timeStage(stageCompare, time.Now())
+		streamed[caseEvents[0].CaseId] = true
+		unsavedCaseIds = append(unsavedCaseIds, caseEvents[0].CaseId)
+		caseCount++
+		eventCount += len(caseEvents)
+		totals.cases++
+		totals.events += len(caseEvents)
+
+		casesWithEventDetails := getCasesWithEventDetails(caseEvents)
 		eventFieldChanges := comparator.CompareEventsByCaseReference(w.transactionId, casesWithEventDetails)
-		if len(eventFieldChanges) == 0 {
-			resultMessage := fmt.Sprintf("No differences found in events for specified cases based on the search criteria provided")
-			sendResult(resultChan, w.transactionId, resultMessage)
-			continue
+
+		eventChangesAnalyze := comparator.NewEventChangesAnalyze(s.activeRules, eventFieldChanges)
+		eventChangesAnalyze.AnalyzeEventFieldChanges()
+		analyzeResult := eventChangesAnalyze.AnalyzeCaseEvents(s.activeCaseRules, casesWithEventDetails)
+		analyzeResultSize += analyzeResult.Size()
+		fieldChangeCount += len(eventFieldChanges)
+		outcomes[caseEvents[0].CaseId] = analyzeResult.IsNotEmpty()
+		totals.add(eventFieldChanges, analyzeResult, s.configuration.EventFilter)
+
+		entities, err := s.prepareReportEntities(analyzeResult, eventFieldChanges)
+		reportEntities = append(reportEntities, entities...)
+		return err
+	}
+
+	isolateCase := func(oversized oversizedCase) {
+		log.Warn().Msgf("tid:%s - Moving oversized caseId: %d to the isolated queue: %s", w.transactionId,
+			oversized.caseId, oversized.reason)
+		oversized.savedEventId = s.savedEventId(w, oversized.caseId)
+		isolatedCases = append(isolatedCases, oversized)
+	}
+
+	handleOversized := func(oversized oversizedCase) {
+		streamed[oversized.caseId] = true
+		caseCount++
+		if s.configuration.OversizedCase == oversizedCaseSkip {
+			log.Warn().Msgf("tid:%s - Skipping oversized caseId: %d: %s", w.transactionId, oversized.caseId,
+				oversized.reason)
+			unsavedCaseIds = append(unsavedCaseIds, oversized.caseId)
+			if s.configuration.Report.Enabled {
+				reportEntities = append(reportEntities, comparator.NewSkippedCaseReportEntity(oversized.reference,
+					oversized.caseTypeId, oversized.reason))
+			}
+			return
+		}
+		isolateCase(oversized)
+	}
+
+	handleCase := func(caseEvents []CaseDataEntity) error {
+		if savedEventId := s.savedEventId(w, caseEvents[0].CaseId); savedEventId > 0 {
+			// An earlier attempt saved the report of the case up to the event, which only the isolated comparison
+			// can start after
+			streamed[caseEvents[0].CaseId] = true
+			caseCount++
+			isolateCase(oversizedCase{
+				caseId:     caseEvents[0].CaseId,
+				reference:  caseEvents[0].Reference,
+				caseTypeId: caseEvents[0].CaseTypeId,
+				reason:     fmt.Sprintf("its report was saved up to event id %d", savedEventId),
+				comparison: w.comparison,
+			})
+			return nil
 		}
+		if err := compareCase(caseEvents); err != nil {
+			return err
+		}
+		saveErr = saveReportEntities()
+		return saveErr
+	}
+
+	loadStart := time.Now()
+	err := s.retrier.do(ctx, fmt.Sprintf("tid:%s - Reading the cases", w.transactionId), func() error {
+		// The attempts after a transient error only read the cases the previous attempts didn't hand over
+		err := streamCases(ctx, s.eventSource, unstreamedCaseIds(w.caseIds, streamed), w.comparison, s.caseLimits(),
+			handleCase, handleOversized)
+		if saveErr != nil {
+			// The saved rows are released, so reading the cases again wouldn't save them
+			return nil
+		}
+		return err
+	})
+	if saveErr != nil {
+		return nil, w.withoutCaseIds(savedCaseIds), saveErr
+	}
+	// The cases are compared and their rows saved as they are streamed, which is left out of the load time.
+	totals.stageDurations[stageLoad] += time.Since(loadStart) - totals.stageDurations[stageCompare] -
+		totals.stageDurations[stageSave]
+	if err != nil {
+		return nil, w.withoutCaseIds(savedCaseIds), errors.Wrap(err, "failed to find cases")
+	}
+
+	if caseCount == 0 {
+		noDataMessage := fmt.Sprintf("No case data returned for jurisdiction: %s with caseTypeId: %s",
+			w.comparison.Jurisdiction, w.comparison.CaseTypeId)
+		sendResult(resultChan, w.transactionId, noDataMessage)
+		return isolatedCases, w, nil
+	}
+	logParsingCaseData(w.transactionId, w.comparison.Jurisdiction, w.comparison.CaseTypeId, eventCount)
+
+	saveStart := time.Now()
+	err = s.completeComparison(ctx, w.transactionId, resultChan, reportEntities, analyzeResultSize,
+		fieldChangeCount)
+	if err != nil {
+		return nil, w.withoutCaseIds(savedCaseIds), err
+	}
+	totals.timeStage(stageSave, saveStart)
+
+	s.sampling.record(outcomes)
+	s.summary.recordComparison(totals)
+	return isolatedCases, w, nil
+}
+
+// compareWatchedFields runs the rules on the changes of the watched fields only. The source compares the fields,
+// in the database when it can, so that whole events are never loaded. Case rules need whole events and are not
+// applied.
+func (s Service) compareWatchedFields(ctx context.Context, w comparisonWork, watchedFields []string,
+	resultChan chan<- comparisonResult) error {
+	totals := newComparisonTotals()
+	loadStart := time.Now()
+	var changes []WatchedFieldChangeEntity
+	err := s.retrier.do(ctx, fmt.Sprintf("tid:%s - Reading the watched field changes", w.transactionId), func() error {
+		var err error
+		changes, err = s.eventSource.findWatchedFieldChanges(ctx, w.caseIds, w.comparison, watchedFields)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to find watched field changes")
+	}
+	totals.timeStage(stageLoad, loadStart)
+	log.Info().Msgf("tid:%s - Found %d changes of the watched fields %s", w.transactionId, len(changes),
+		watchedFields)
+
+	compareStart := time.Now()
+	eventFieldChanges := comparator.CompareFieldValueChanges(toFieldValueChanges(changes))
+	analyzeResult := comparator.NewEventChangesAnalyze(s.activeRules, eventFieldChanges).AnalyzeEventFieldChanges()
+	reportEntities, err := s.prepareReportEntities(analyzeResult, eventFieldChanges)
+	if err != nil {
+		return err
+	}
+	totals.timeStage(stageCompare, compareStart)
+
+	saveStart := time.Now()
+	err = s.completeComparison(ctx, w.transactionId, resultChan, reportEntities, analyzeResult.Size(),
+		len(eventFieldChanges))
+	if err != nil {
+		return err
+	}
+	totals.timeStage(stageSave, saveStart)
 
-		analyzeResult := comparator.NewEventChangesAnalyze(s.activeRules, eventFieldChanges).AnalyzeEventFieldChanges()
+	s.sampling.record(watchedFieldOutcomes(w.caseIds, changes, analyzeResult.CaseReferences()))
+	totals.cases = len(w.caseIds)
+	totals.events = countChangedEvents(changes)
+	totals.add(eventFieldChanges, analyzeResult, s.configuration.EventFilter)
+	s.summary.recordComparison(totals)
+	return nil
+}
 
-		if !s.configuration.Report.Enabled {
-			resultMessage := fmt.Sprintf("Analysis completed without saving the report. Total records in analyzeResult: %d. Total number of field change: %d",
-				analyzeResult.Size(), len(eventFieldChanges))
-			sendResult(resultChan, w.transactionId, resultMessage)
+// compareOversizedCases compares the oversized cases one at a time, diffing their events as they are read and saving
+// their report every chunk of events. Case rules need the whole case and are not applied. The cases still queued once
+// ctx is done are not started.
+func (s Service) compareOversizedCases(ctx, workCtx context.Context, wg *sync.WaitGroup,
+	oversizedCases <-chan oversizedCase, resultChan chan<- comparisonResult) {
+	defer wg.Done()
+
+	for oversized := range oversizedCases {
+		w := comparisonWork{
+			transactionId: uuid.New().String(),
+			comparison:    oversized.comparison,
+			caseIds:       []string{strconv.FormatInt(oversized.caseId, 10)},
+			savedEventId:  oversized.savedEventId,
+		}
+		if ctx.Err() != nil {
+			s.recordInterruptedBatch(w)
 			continue
 		}
+		log.Info().Msgf("tid:%s - Isolated comparison started for caseId: %d", w.transactionId, oversized.caseId)
 
-		if err := s.saveReport(w.transactionId, analyzeResult, eventFieldChanges); err != nil {
-			handleError(resultChan, w.transactionId, err, "saving the report")
+		isolated := newIsolatedComparison(s, w, oversized)
+		err := s.compareOversizedCase(workCtx, isolated, resultChan)
+		if err != nil {
+			// The failed case is compared again after the last event whose report is saved
+			s.recordBatchError(workCtx, isolated.w, err, resultChan, "comparing the oversized case")
 			continue
 		}
+		s.recordCompletedBatch(w, nil)
+	}
+}
 
-		sendResult(resultChan, w.transactionId, "Operation completed successfully.")
+// savedEventId returns the last event of the case whose report an earlier attempt saved, from the dead letter file
+// or the checkpoints of the resumed run, 0 when none did.
+func (s Service) savedEventId(w comparisonWork, caseId int64) int64 {
+	if w.savedEventId > 0 {
+		return w.savedEventId
 	}
+	return s.checkpoints.savedEventId(w.comparison, caseId)
+}
+
+// compareOversizedCase diffs the events of the case as they are read, and analyses, saves and releases their changes
+// every chunk of events, so that memory doesn't grow with the history of the case. A read failing with a transient
+// error is run again from the event after the last one diffed.
+func (s Service) compareOversizedCase(ctx context.Context, isolated *isolatedComparison,
+	resultChan chan<- comparisonResult) error {
+	w, oversized := isolated.w, isolated.oversized
+	start := time.Now()
+	var chunkErr error
+	err := s.retrier.do(ctx, fmt.Sprintf("tid:%s - Reading the oversized case", w.transactionId), func() error {
+		err := s.streamOversizedCase(ctx, oversized, func(event CaseDataEntity) error {
+			if chunkErr = isolated.add(ctx, event); chunkErr != nil {
+				return chunkErr
+			}
+			return nil
+		})
+		if chunkErr != nil {
+			// The changes of the chunk are released, so reading the case again wouldn't save them
+			return nil
+		}
+		return err
+	})
+	if chunkErr != nil {
+		return chunkErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := isolated.flush(ctx, true); err != nil {
+		return err
+	}
+	isolated.totals.stageDurations[stageLoad] += time.Since(start) - isolated.totals.stageDurations[stageCompare] -
+		isolated.totals.stageDurations[stageSave]
+
+	isolated.sendResult(resultChan)
+	s.sampling.record(map[int64]bool{oversized.caseId: isolated.hasViolation})
+	s.summary.recordComparison(isolated.totals)
+	return nil
+}
+
+func (s Service) streamOversizedCase(ctx context.Context, oversized oversizedCase,
+	handleEvent func(event CaseDataEntity) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("recovered from panic: %s", r)
+		}
+	}()
+
+	return s.eventSource.streamEventsInImpactPeriod(ctx, []string{strconv.FormatInt(oversized.caseId, 10)},
+		oversized.comparison, handleEvent)
+}
+
+func (s Service) prepareReportEntities(analyzeResult *comparator.AnalyzeResult,
+	eventFieldChanges comparator.EventFieldChanges) ([]comparator.EventDataReportEntity, error) {
+	if !s.configuration.Report.Enabled ||
+		(analyzeResult.IsEmpty() && !s.configuration.Report.IncludeEmptyChange) {
+		return nil, nil
+	}
+
+	entities, err := comparator.PrepareReportEntities(eventFieldChanges, analyzeResult, s.configuration)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to process PrepareReportEntities")
+	}
+	return entities, nil
+}
+
+func (s Service) completeComparison(ctx context.Context, transactionId string, resultChan chan<- comparisonResult,
+	reportEntities []comparator.EventDataReportEntity, analyzeResultSize, fieldChangeCount int) error {
+	if fieldChangeCount == 0 && analyzeResultSize == 0 && len(reportEntities) == 0 {
+		resultMessage := fmt.Sprintf("No differences found in events for specified cases based on the search criteria provided")
+		sendResult(resultChan, transactionId, resultMessage)
+		return nil
+	}
+
+	if !s.configuration.Report.Enabled {
+		resultMessage := fmt.Sprintf("Analysis completed without saving the report. Total records in analyzeResult: %d. Total number of field change: %d",
+			analyzeResultSize, fieldChangeCount)
+		sendResult(resultChan, transactionId, resultMessage)
+		return nil
+	}
+
+	if err := s.saveReport(ctx, transactionId, reportEntities); err != nil {
+		return err
+	}
+
+	sendResult(resultChan, transactionId, "Operation completed successfully.")
+	return nil
 }
 
 func logEventComparisonStart(workerId int, w comparisonWork) {
@@ -212,10 +1041,6 @@ func logParsingCaseData(transactionId, jurisdiction, caseTypeID string, numCases
 		transactionId, jurisdiction, caseTypeID, numCases)
 }
 
-func logEventComparisonStarted(transactionId string) {
-	log.Info().Msgf("tid:%s - Event comparing started...", transactionId)
-}
-
 func handleError(resultChan chan<- comparisonResult, transactionId string, err error, context string) {
 	sendError(resultChan, transactionId, errors.Wrap(err, fmt.Sprintf("error occurred while %s", context)))
 }
@@ -236,34 +1061,25 @@ func sendError(resultChan chan<- comparisonResult, transactionId string, error e
 	resultChan <- result
 }
 
-func (s Service) saveReport(transactionId string, analyzeResult *comparator.AnalyzeResult,
-	eventDifferences comparator.EventFieldChanges) error {
-
-	if analyzeResult.IsNotEmpty() || s.configuration.Report.IncludeEmptyChange {
-		eventDataReportEntities, err := comparator.PrepareReportEntities(eventDifferences, analyzeResult,
-			s.configuration)
-		if err != nil {
-			return errors.Wrap(err, "failed to process PrepareReportEntities")
-		}
-
-		numberOfRecord := len(eventDataReportEntities)
-		if numberOfRecord == 0 {
-			log.Info().Msg("No records were returned from PrepareReportEntities.")
-			return nil
-		}
+func (s Service) saveReport(ctx context.Context, transactionId string,
+	eventDataReportEntities []comparator.EventDataReportEntity) error {
+	numberOfRecord := len(eventDataReportEntities)
+	if numberOfRecord == 0 {
+		log.Info().Msgf("tid:%s - Saving the report has been skipped", transactionId)
+		return nil
+	}
 
-		log.Info().Msgf("tid:%s - Saving report data to the database. Total record number: %d", transactionId, numberOfRecord)
+	log.Info().Msgf("tid:%s - Saving report data to the database. Total record number: %d", transactionId, numberOfRecord)
 
-		err = s.saveRepo.saveAllEventDataReport(s.configuration.Database.BatchSize,
+	err := s.retrier.do(ctx, fmt.Sprintf("tid:%s - Saving the report", transactionId), func() error {
+		return s.saveRepo.saveAllEventDataReport(ctx, s.configuration.Database.BatchSize,
 			s.configuration.Database.EventDataTable, eventDataReportEntities)
-		if err != nil {
-			return errors.Wrap(err, "failed to save report data")
-		}
-
-		log.Info().Msgf("tid:%s - Records successfully saved to the database", transactionId)
-	} else {
-		log.Info().Msgf("tid:%s - Saving the report has been skipped", transactionId)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to save report data")
 	}
+
+	log.Info().Msgf("tid:%s - Records successfully saved to the database", transactionId)
 	return nil
 }
 
@@ -275,16 +1091,21 @@ func getCasesWithEventDetails(cases []CaseDataEntity) comparator.CasesWithEventD
 			casesWithEventDetails[caseData.Reference] = make(map[int64]comparator.EventDetails)
 		}
 
-		casesWithEventDetails[caseData.Reference][caseData.EventId] = comparator.EventDetails{
-			Id:          caseData.EventId,
-			Name:        caseData.EventName,
-			CreatedDate: caseData.EventCreatedDate,
-			Data:        caseData.EventData,
-			CaseDataId:  caseData.CaseDataId,
-			UserId:      caseData.UserId,
-			CaseTypeId:  caseData.CaseTypeId,
-		}
+		casesWithEventDetails[caseData.Reference][caseData.EventId] = newEventDetails(caseData)
 	}
 
 	return casesWithEventDetails
 }
+
+func newEventDetails(caseData CaseDataEntity) comparator.EventDetails {
+	return caseData.EventContextEntity.withEventDetails(comparator.EventDetails{
+		Id:          caseData.EventId,
+		Name:        caseData.EventName,
+		CreatedDate: caseData.EventCreatedDate,
+		Data:        caseData.EventData,
+		CaseDataId:  caseData.CaseDataId,
+		UserId:      caseData.UserId,
+		CaseTypeId:  caseData.CaseTypeId,
+		StateId:     caseData.StateId,
+	})
+}
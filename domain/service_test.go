@@ -2,8 +2,14 @@ package domain
 
 import (
 	"ccd-comparator-data-diff-rapid/comparator"
+	"ccd-comparator-data-diff-rapid/config"
+	"context"
+	"database/sql/driver"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -12,25 +18,78 @@ type MockQueryRepository struct {
 	mock.Mock
 }
 
-func (m *MockQueryRepository) findCasesByEventsInImpactPeriod(comparison Comparison) ([]string, error) {
-	args := m.Called(comparison)
+func (m *MockQueryRepository) findCasesByEventsInImpactPeriod(_ context.Context, comparison Comparison,
+	lastCaseId int64, limit int) ([]string, error) {
+	args := m.Called(comparison, lastCaseId, limit)
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (m *MockQueryRepository) findCasesByJurisdictionInImpactPeriod(caseIds []string) ([]CaseDataEntity, error) {
-	args := m.Called(caseIds)
+func (m *MockQueryRepository) findSampleFrame(_ context.Context, comparison Comparison, lastCaseId int64,
+	limit int) ([]SampleFrameEntity, error) {
+	args := m.Called(comparison, lastCaseId, limit)
+	return args.Get(0).([]SampleFrameEntity), args.Error(1)
+}
+
+func (m *MockQueryRepository) findCasesByJurisdictionInImpactPeriod(_ context.Context, caseIds []string,
+	comparison Comparison) ([]CaseDataEntity, error) {
+	args := m.Called(caseIds, comparison)
 	return args.Get(0).([]CaseDataEntity), args.Error(1)
 }
 
+func (m *MockQueryRepository) findCaseIdsByReferences(_ context.Context,
+	references []string) (map[string]string, error) {
+	args := m.Called(references)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+// streamEventsInImpactPeriod streams the entities returned by the findCasesByJurisdictionInImpactPeriod expectation.
+func (m *MockQueryRepository) streamEventsInImpactPeriod(ctx context.Context, caseIds []string, comparison Comparison,
+	handleEvent func(event CaseDataEntity) error) error {
+	cases, err := m.findCasesByJurisdictionInImpactPeriod(ctx, caseIds, comparison)
+	if err != nil {
+		return err
+	}
+	return streamSortedEvents(cases, handleEvent)
+}
+
+// findWatchedFieldChanges compares the entities returned by the findCasesByJurisdictionInImpactPeriod expectation.
+func (m *MockQueryRepository) findWatchedFieldChanges(ctx context.Context, caseIds []string, comparison Comparison,
+	fields []string) ([]WatchedFieldChangeEntity, error) {
+	return streamWatchedFieldChanges(ctx, m, caseIds, comparison, fields)
+}
+
+func (m *MockQueryRepository) findCaseTypeStatistics(_ context.Context, comparison Comparison,
+	filter DiscoveryFilter) ([]CaseTypeStatisticsEntity, error) {
+	args := m.Called(comparison, filter)
+	return args.Get(0).([]CaseTypeStatisticsEntity), args.Error(1)
+}
+
+func (m *MockQueryRepository) findLatestEvent(_ context.Context, comparison Comparison) (*Watermark, error) {
+	args := m.Called(comparison)
+	return args.Get(0).(*Watermark), args.Error(1)
+}
+
 type MockSaveRepository struct {
 	mock.Mock
 }
 
-func (m *MockSaveRepository) saveAllEventDataReport(size int, eventDataReportEntities []comparator.EventDataReportEntity) error {
+func (m *MockSaveRepository) saveAllEventDataReport(_ context.Context, size int, eventDataTable string,
+	eventDataReportEntities []comparator.EventDataReportEntity) error {
 	args := m.Called(eventDataReportEntities)
 	return args.Error(0)
 }
 
+func (m *MockSaveRepository) findWatermark(_ context.Context, watermarkTable, jurisdiction,
+	caseTypeId string) (*Watermark, error) {
+	args := m.Called(watermarkTable, jurisdiction, caseTypeId)
+	return args.Get(0).(*Watermark), args.Error(1)
+}
+
+func (m *MockSaveRepository) saveWatermark(_ context.Context, watermarkTable string, watermark Watermark) error {
+	args := m.Called(watermarkTable, watermark)
+	return args.Error(0)
+}
+
 func TestService_CompareEventsInImpactPeriodHappyPath(t *testing.T) {
 	setUp()
 	defer cleanUp()
@@ -40,7 +99,7 @@ func TestService_CompareEventsInImpactPeriodHappyPath(t *testing.T) {
 
 	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
 
-	service := NewService(cfg, &enabledRuleList, mockQueryRepo, mockSaveRepo)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
 
 	startTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
 	endTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
@@ -50,10 +109,11 @@ func TestService_CompareEventsInImpactPeriodHappyPath(t *testing.T) {
 		CaseTypeId:          "caseType",
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
-	}).
+	}, int64(0), defaultDiscoveryPageSize).
 		Return([]string{"1"}, nil)
 
-	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}).
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"},
+		mock.AnythingOfType("domain.Comparison")).
 		Return([]CaseDataEntity{
 			{
 				Reference:        1,
@@ -97,7 +157,7 @@ func TestService_CompareEventsInImpactPeriodHappyPath(t *testing.T) {
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
 	}
-	service.CompareEventsInImpactPeriod(c)
+	service.CompareEventsInImpactPeriod(context.Background(), c)
 
 	mockQueryRepo.AssertExpectations(t)
 	mockSaveRepo.AssertExpectations(t)
@@ -113,7 +173,7 @@ func TestService_CompareEventsInImpactIgnoreSaveReport(t *testing.T) {
 	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
 
 	cfg.Report.Enabled = false
-	service := NewService(cfg, &enabledRuleList, mockQueryRepo, mockSaveRepo)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
 
 	startTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
 	endTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
@@ -122,9 +182,10 @@ func TestService_CompareEventsInImpactIgnoreSaveReport(t *testing.T) {
 		CaseTypeId:          "caseType",
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
-	}).
+	}, int64(0), defaultDiscoveryPageSize).
 		Return([]string{"1"}, nil)
-	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}).
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"},
+		mock.AnythingOfType("domain.Comparison")).
 		Return([]CaseDataEntity{
 			{
 				Reference:        1,
@@ -166,7 +227,7 @@ func TestService_CompareEventsInImpactIgnoreSaveReport(t *testing.T) {
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
 	}
-	service.CompareEventsInImpactPeriod(c)
+	service.CompareEventsInImpactPeriod(context.Background(), c)
 
 	mockQueryRepo.AssertExpectations(t)
 	mockSaveRepo.AssertNotCalled(t, "saveAllEventDataReport")
@@ -182,7 +243,7 @@ func TestService_CompareEventsInImpactNoCaseData(t *testing.T) {
 	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
 
 	cfg.Report.Enabled = false
-	service := NewService(cfg, &enabledRuleList, mockQueryRepo, mockSaveRepo)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
 
 	startTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
 	endTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
@@ -194,16 +255,18 @@ func TestService_CompareEventsInImpactNoCaseData(t *testing.T) {
 		SearchPeriodEndTime: endTime,
 	}
 
-	mockQueryRepo.On("findCasesByEventsInImpactPeriod", c).Return([]string{}, nil)
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", c, int64(0), defaultDiscoveryPageSize).
+		Return([]string{}, nil)
 
-	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{}).
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{},
+		mock.AnythingOfType("domain.Comparison")).
 		Return([]CaseDataEntity{}, nil)
 
 	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil)
 
-	service.CompareEventsInImpactPeriod(c)
+	service.CompareEventsInImpactPeriod(context.Background(), c)
 
-	mockQueryRepo.AssertCalled(t, "findCasesByEventsInImpactPeriod", c)
+	mockQueryRepo.AssertCalled(t, "findCasesByEventsInImpactPeriod", c, int64(0), defaultDiscoveryPageSize)
 	mockQueryRepo.AssertNotCalled(t, "findCasesByJurisdictionInImpactPeriod")
 	mockSaveRepo.AssertNotCalled(t, "saveAllEventDataReport")
 }
@@ -218,7 +281,7 @@ func TestService_CompareEventsInImpactEmptyEventFieldChanges(t *testing.T) {
 	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
 
 	cfg.Report.Enabled = false
-	service := NewService(cfg, &enabledRuleList, mockQueryRepo, mockSaveRepo)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
 
 	startTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
 	endTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
@@ -228,10 +291,11 @@ func TestService_CompareEventsInImpactEmptyEventFieldChanges(t *testing.T) {
 		CaseTypeId:          "caseType",
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
-	}).
+	}, int64(0), defaultDiscoveryPageSize).
 		Return([]string{"1"}, nil)
 
-	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}).
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"},
+		mock.AnythingOfType("domain.Comparison")).
 		Return([]CaseDataEntity{
 			{
 				EventId:          1,
@@ -248,7 +312,7 @@ func TestService_CompareEventsInImpactEmptyEventFieldChanges(t *testing.T) {
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
 	}
-	service.CompareEventsInImpactPeriod(c)
+	service.CompareEventsInImpactPeriod(context.Background(), c)
 
 	mockQueryRepo.AssertExpectations(t)
 }
@@ -263,7 +327,7 @@ func TestService_CompareEventsInImpactErrorFromFindCasesByJurisdictionInImpactPe
 	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
 
 	cfg.Report.Enabled = false
-	service := NewService(cfg, &enabledRuleList, mockQueryRepo, mockSaveRepo)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
 
 	startTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
 	endTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
@@ -273,10 +337,11 @@ func TestService_CompareEventsInImpactErrorFromFindCasesByJurisdictionInImpactPe
 		CaseTypeId:          "caseType",
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
-	}).
+	}, int64(0), defaultDiscoveryPageSize).
 		Return([]string{"1"}, nil)
 
-	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}).
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"},
+		mock.AnythingOfType("domain.Comparison")).
 		Return([]CaseDataEntity{}, errors.New("error occurred"))
 
 	c := Comparison{
@@ -285,7 +350,7 @@ func TestService_CompareEventsInImpactErrorFromFindCasesByJurisdictionInImpactPe
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
 	}
-	service.CompareEventsInImpactPeriod(c)
+	service.CompareEventsInImpactPeriod(context.Background(), c)
 
 	mockQueryRepo.AssertExpectations(t)
 }
@@ -299,7 +364,7 @@ func TestService_CompareEventsInImpactErrorFromSaveAllEventDataReport(t *testing
 
 	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
 
-	service := NewService(cfg, &enabledRuleList, mockQueryRepo, mockSaveRepo)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
 
 	startTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
 	endTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
@@ -309,10 +374,11 @@ func TestService_CompareEventsInImpactErrorFromSaveAllEventDataReport(t *testing
 		CaseTypeId:          "caseType",
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
-	}).
+	}, int64(0), defaultDiscoveryPageSize).
 		Return([]string{"1"}, nil)
 
-	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}).
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"},
+		mock.AnythingOfType("domain.Comparison")).
 		Return([]CaseDataEntity{
 			{
 				Reference:        1,
@@ -356,7 +422,7 @@ func TestService_CompareEventsInImpactErrorFromSaveAllEventDataReport(t *testing
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
 	}
-	service.CompareEventsInImpactPeriod(c)
+	service.CompareEventsInImpactPeriod(context.Background(), c)
 
 	mockQueryRepo.AssertExpectations(t)
 	mockSaveRepo.AssertExpectations(t)
@@ -371,7 +437,7 @@ func TestService_CompareEventsInImpactPeriodNoFieldChange(t *testing.T) {
 
 	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
 	cfg.Concurrent.Event.ThresholdMilliseconds = 5000
-	service := NewService(cfg, &enabledRuleList, mockQueryRepo, mockSaveRepo)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
 
 	startTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
 	endTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
@@ -381,10 +447,11 @@ func TestService_CompareEventsInImpactPeriodNoFieldChange(t *testing.T) {
 		CaseTypeId:          "caseType",
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
-	}).
+	}, int64(0), defaultDiscoveryPageSize).
 		Return([]string{"1"}, nil)
 
-	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}).
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"},
+		mock.AnythingOfType("domain.Comparison")).
 		Return([]CaseDataEntity{
 			{
 				Reference:        1,
@@ -412,7 +479,7 @@ func TestService_CompareEventsInImpactPeriodNoFieldChange(t *testing.T) {
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
 	}
-	service.CompareEventsInImpactPeriod(c)
+	service.CompareEventsInImpactPeriod(context.Background(), c)
 
 	mockQueryRepo.AssertExpectations(t)
 }
@@ -428,7 +495,7 @@ func TestService_CompareEventsInImpactPeriodSkipEmptyChange(t *testing.T) {
 	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
 	cfg.Concurrent.Event.ThresholdMilliseconds = 5000
 	cfg.Report.IncludeEmptyChange = false
-	service := NewService(cfg, &enabledRuleList, mockQueryRepo, mockSaveRepo)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
 
 	startTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
 	endTime := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
@@ -438,10 +505,11 @@ func TestService_CompareEventsInImpactPeriodSkipEmptyChange(t *testing.T) {
 		CaseTypeId:          "caseType",
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
-	}).
+	}, int64(0), defaultDiscoveryPageSize).
 		Return([]string{"1"}, nil)
 
-	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}).
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"},
+		mock.AnythingOfType("domain.Comparison")).
 		Return([]CaseDataEntity{
 			{
 				Reference:        1,
@@ -469,7 +537,886 @@ func TestService_CompareEventsInImpactPeriodSkipEmptyChange(t *testing.T) {
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
 	}
-	service.CompareEventsInImpactPeriod(c)
+	service.CompareEventsInImpactPeriod(context.Background(), c)
+
+	mockQueryRepo.AssertExpectations(t)
+}
+
+func TestService_CompareEventsInImpactPeriodPagesThroughCaseIds(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Report.Enabled = false
+	cfg.Scan.DiscoveryPageSize = 2
+	cfg.Scan.BatchSize = 3
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	c := Comparison{
+		Jurisdiction:        "jurisdiction",
+		CaseTypeId:          "caseType",
+		StartTime:           time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC),
+		SearchPeriodEndTime: time.Date(2023, 8, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", c, int64(0), 2).Return([]string{"10", "11"}, nil).Once()
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", c, int64(11), 2).Return([]string{"12", "13"}, nil).Once()
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", c, int64(13), 2).Return([]string{}, nil).Once()
+
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"10", "11", "12"}, c).
+		Return([]CaseDataEntity{}, nil).Once()
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"13"}, c).
+		Return([]CaseDataEntity{}, nil).Once()
+
+	service.CompareEventsInImpactPeriod(context.Background(), c)
+
+	mockQueryRepo.AssertExpectations(t)
+}
+
+func TestService_CompareEventsInImpactPeriodComparesSampledCases(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Report.Enabled = false
+	cfg.Scan.DiscoveryPageSize = 3
+	cfg.Scan.BatchSize = 10
+	cfg.Scan.Sample = config.Sample{Size: 2, Seed: 7, ConfidenceLevel: 0.95}
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	c := Comparison{Jurisdiction: "jurisdiction"}
+	frame := []SampleFrameEntity{{CaseId: "10"}, {CaseId: "11"}, {CaseId: "12"}, {CaseId: "13"}}
+	mockQueryRepo.On("findSampleFrame", c, int64(0), 3).Return(frame[:3], nil).Once()
+	mockQueryRepo.On("findSampleFrame", c, int64(12), 3).Return(frame[3:], nil).Once()
+
+	sampledCaseIds := drawSample(frame, cfg.Scan.Sample).caseIds
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", sampledCaseIds, c).
+		Return([]CaseDataEntity{}, nil).Once()
+
+	service.CompareEventsInImpactPeriod(context.Background(), c)
+
+	assert.Len(t, sampledCaseIds, 2)
+	mockQueryRepo.AssertExpectations(t)
+	mockQueryRepo.AssertNotCalled(t, "findCasesByEventsInImpactPeriod", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestService_CompareEventsInImpactPeriodResolvesCaseReferences(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Report.Enabled = false
+	cfg.Scan.BatchSize = 10
+	cfg.Scan.CaseId = "1234-5678-9012-3452, 42,1234567890123453,abc 1698765432109877"
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	mockQueryRepo.On("findCaseIdsByReferences", []string{"1234567890123452", "1698765432109877"}).
+		Return(map[string]string{"1234567890123452": "7"}, nil).Once()
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"7", "42"}, Comparison{}).
+		Return([]CaseDataEntity{}, nil).Once()
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
 
 	mockQueryRepo.AssertExpectations(t)
+	mockQueryRepo.AssertNotCalled(t, "findCasesByEventsInImpactPeriod", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestService_CompareEventsInImpactPeriodSavesStreamedCasesOnce(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.CaseId = "1,2"
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1", "2"}, Comparison{}).
+		Return([]CaseDataEntity{
+			{CaseId: 2, Reference: 22, EventId: 4, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+			{CaseId: 1, Reference: 11, EventId: 1, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 2, Reference: 22, EventId: 3, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 1, Reference: 11, EventId: 2, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+		}, nil)
+
+	var references []string
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Once().
+		Run(func(args mock.Arguments) {
+			for _, entity := range args.Get(0).([]comparator.EventDataReportEntity) {
+				references = append(references, entity.Reference)
+			}
+		})
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	mockSaveRepo.AssertExpectations(t)
+	assert.Equal(t, []string{"11", "22"}, references)
+}
+
+func TestService_CompareEventsInImpactPeriodSavesTheBufferedRowsOfTheCases(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.CaseId = "1,2"
+	cfg.Scan.ReportBufferSize = 1
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1", "2"}, Comparison{}).
+		Return([]CaseDataEntity{
+			{CaseId: 1, Reference: 11, EventId: 1, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 1, Reference: 11, EventId: 2, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+			{CaseId: 2, Reference: 22, EventId: 3, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 2, Reference: 22, EventId: 4, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+		}, nil)
+
+	var references [][]string
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Times(2).
+		Run(func(args mock.Arguments) {
+			var saved []string
+			for _, entity := range args.Get(0).([]comparator.EventDataReportEntity) {
+				saved = append(saved, entity.Reference)
+			}
+			references = append(references, saved)
+		})
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	mockSaveRepo.AssertExpectations(t)
+	assert.Equal(t, [][]string{{"11"}, {"22"}}, references)
+}
+
+func TestService_CompareEventsInImpactPeriodLeavesOnlyTheUnsavedCasesOfAFailedBatch(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.CaseId = "1,2"
+	cfg.Scan.ReportBufferSize = 1
+	cfg.Scan.DeadLetterFile = filepath.Join(t.TempDir(), "failed_batches.ndjson")
+	path := filepath.Join(t.TempDir(), "checkpoints.ndjson")
+	checkpoints, err := NewCheckpoints(path, time.Now(), 0)
+	assert.NoError(t, err)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, checkpoints, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1", "2"}, Comparison{}).
+		Return([]CaseDataEntity{
+			{CaseId: 1, Reference: 11, EventId: 1, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 1, Reference: 11, EventId: 2, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+			{CaseId: 2, Reference: 22, EventId: 3, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 2, Reference: 22, EventId: 4, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+		}, nil)
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Once()
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(errors.New("relation does not exist")).Once()
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	mockSaveRepo.AssertExpectations(t)
+	works, err := readFailedBatches(cfg.Scan.DeadLetterFile)
+	assert.NoError(t, err)
+	assert.Len(t, works, 1)
+	assert.Equal(t, []string{"2"}, works[0].caseIds)
+	resumed, err := ResumeCheckpoints(path, checkpoints.RunId())
+	assert.NoError(t, err)
+	assert.Empty(t, resumed.remaining(Comparison{}, []string{"1", "2"}))
+}
+
+func TestService_CompareEventsInImpactPeriodCheckpointsTheSavedCasesOfABatch(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.CaseId = "1,2,3"
+	cfg.Scan.ReportBufferSize = 1
+	path := filepath.Join(t.TempDir(), "checkpoints.ndjson")
+	checkpoints, err := NewCheckpoints(path, time.Now(), 0)
+	assert.NoError(t, err)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, checkpoints, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1", "2", "3"}, Comparison{}).
+		Return([]CaseDataEntity{
+			{CaseId: 1, Reference: 11, EventId: 1, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 1, Reference: 11, EventId: 2, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+			{CaseId: 3, Reference: 33, EventId: 3, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 3, Reference: 33, EventId: 4, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+		}, nil)
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Once()
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(errors.New("relation does not exist")).Once()
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	mockSaveRepo.AssertExpectations(t)
+	resumed, err := ResumeCheckpoints(path, checkpoints.RunId())
+	assert.NoError(t, err)
+	// Case 2 has no events in the period, and is only done once the batch completes
+	assert.Equal(t, []string{"2", "3"}, resumed.remaining(Comparison{}, []string{"1", "2", "3"}))
+}
+
+func TestService_CompareEventsInImpactPeriodIsolatesOversizedCases(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.CaseId = "1,2"
+	cfg.Scan.MaxEventProcessCount = 2
+	cfg.Scan.OversizedCase = oversizedCaseIsolate
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	oversizedEvents := []CaseDataEntity{
+		{CaseId: 1, Reference: 11, EventId: 1, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+		{CaseId: 1, Reference: 11, EventId: 2, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+		{CaseId: 1, Reference: 11, EventId: 3, EventCreatedDate: createdDate, EventData: `{"name": "c"}`},
+	}
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1", "2"}, Comparison{}).
+		Return(append([]CaseDataEntity{
+			{CaseId: 2, Reference: 22, EventId: 4, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 2, Reference: 22, EventId: 5, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+		}, oversizedEvents...), nil)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}, Comparison{}).
+		Return(oversizedEvents, nil)
+
+	var references []string
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Times(2).
+		Run(func(args mock.Arguments) {
+			for _, entity := range args.Get(0).([]comparator.EventDataReportEntity) {
+				references = append(references, entity.Reference)
+			}
+		})
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	mockSaveRepo.AssertExpectations(t)
+	assert.Equal(t, []string{"22", "11", "11"}, references)
+}
+
+func TestService_CompareEventsInImpactPeriodSavesIsolatedCasesInChunks(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+	activeCaseRules := []comparator.CaseRule{comparator.NewDuplicateEventRule(5000, 2)}
+
+	cfg.Scan.CaseId = "1"
+	cfg.Scan.MaxEventProcessCount = 2
+	cfg.Scan.OversizedCase = oversizedCaseIsolate
+	cfg.Scan.OversizedChunkEventCount = 2
+	service := NewService(cfg, &enabledRuleList, &activeCaseRules, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}, Comparison{}).
+		Return([]CaseDataEntity{
+			{CaseId: 1, Reference: 11, CaseTypeId: "CT1", EventId: 1, EventCreatedDate: createdDate,
+				EventData: `{"name": "a"}`},
+			{CaseId: 1, Reference: 11, CaseTypeId: "CT1", EventId: 2, EventCreatedDate: createdDate,
+				EventData: `{"name": "b"}`},
+			{CaseId: 1, Reference: 11, CaseTypeId: "CT1", EventId: 3, EventCreatedDate: createdDate,
+				EventData: `{"name": "c"}`},
+			{CaseId: 1, Reference: 11, CaseTypeId: "CT1", EventId: 4, EventCreatedDate: createdDate,
+				EventData: `{"name": "d"}`},
+		}, nil)
+
+	var savedEventIds [][]int64
+	var analyzeResults []string
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).
+		Run(func(args mock.Arguments) {
+			var eventIds []int64
+			for _, entity := range args.Get(0).([]comparator.EventDataReportEntity) {
+				eventIds = append(eventIds, entity.EventId)
+				analyzeResults = append(analyzeResults, entity.AnalyzeResult)
+			}
+			savedEventIds = append(savedEventIds, eventIds)
+		})
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	assert.Equal(t, [][]int64{{2}, {3, 4}, {0}}, savedEventIds)
+	assert.Contains(t, analyzeResults[len(analyzeResults)-1], "skippedcaserules:Case rules were not applied")
+}
+
+func TestService_RetryFailedBatchesComparesIsolatedCasesAfterTheirSavedChunks(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.CaseId = "1"
+	cfg.Scan.MaxEventProcessCount = 2
+	cfg.Scan.OversizedCase = oversizedCaseIsolate
+	cfg.Scan.OversizedChunkEventCount = 2
+	cfg.Scan.DeadLetterFile = filepath.Join(t.TempDir(), "failed_batches.ndjson")
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}, mock.Anything).
+		Return([]CaseDataEntity{
+			{CaseId: 1, Reference: 11, EventId: 1, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 1, Reference: 11, EventId: 2, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+			{CaseId: 1, Reference: 11, EventId: 3, EventCreatedDate: createdDate, EventData: `{"name": "c"}`},
+			{CaseId: 1, Reference: 11, EventId: 4, EventCreatedDate: createdDate, EventData: `{"name": "d"}`},
+		}, nil)
+
+	var savedEventIds [][]int64
+	recordSavedEventIds := func(args mock.Arguments) {
+		var eventIds []int64
+		for _, entity := range args.Get(0).([]comparator.EventDataReportEntity) {
+			eventIds = append(eventIds, entity.EventId)
+		}
+		savedEventIds = append(savedEventIds, eventIds)
+	}
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Once().Run(recordSavedEventIds)
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(errors.New("relation does not exist")).Once()
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	works, err := readFailedBatches(cfg.Scan.DeadLetterFile)
+	assert.NoError(t, err)
+	assert.Len(t, works, 1)
+	assert.Equal(t, []string{"1"}, works[0].caseIds)
+	assert.Equal(t, int64(2), works[0].savedEventId)
+
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Run(recordSavedEventIds)
+
+	assert.NoError(t, service.RetryFailedBatches(context.Background()))
+
+	// The retry only saves the changes after event 2, still comparing event 3 with event 2
+	assert.Equal(t, [][]int64{{2}, {3, 4}}, savedEventIds)
+}
+
+func TestService_CompareEventsInImpactPeriodSkipsOversizedCases(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.CaseId = "1"
+	cfg.Scan.MaxCasePayloadBytes = 20
+	cfg.Scan.OversizedCase = oversizedCaseSkip
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}, Comparison{}).
+		Return([]CaseDataEntity{
+			{CaseId: 1, Reference: 11, CaseTypeId: "CT1", EventId: 1, EventCreatedDate: createdDate,
+				EventData: `{"name": "a"}`},
+			{CaseId: 1, Reference: 11, CaseTypeId: "CT1", EventId: 2, EventCreatedDate: createdDate,
+				EventData: `{"name": "b"}`},
+		}, nil)
+
+	var entities []comparator.EventDataReportEntity
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Once().
+		Run(func(args mock.Arguments) {
+			entities = args.Get(0).([]comparator.EventDataReportEntity)
+		})
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	mockSaveRepo.AssertExpectations(t)
+	mockQueryRepo.AssertNumberOfCalls(t, "findCasesByJurisdictionInImpactPeriod", 1)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, "11", entities[0].Reference)
+	assert.Equal(t, "CT1", entities[0].CaseTypeId)
+	assert.Equal(t, comparator.CaseLevelFieldName, entities[0].FieldName)
+	assert.Equal(t, "skippedcase:Case was not compared, 26 bytes of event data exceed maxCasePayloadBytes 20",
+		entities[0].AnalyzeResult)
+}
+
+func TestService_CompareEventsInImpactPeriodRetriesTransientErrors(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.CaseId = "1"
+	cfg.Database.Retry = config.Retry{MaxAttempts: 3, InitialBackoffMilliseconds: 1, MaxBackoffMilliseconds: 1}
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}, Comparison{}).
+		Return([]CaseDataEntity{}, &pq.Error{Code: "40001"}).Once()
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}, Comparison{}).
+		Return([]CaseDataEntity{
+			{CaseId: 1, Reference: 11, EventId: 1, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 1, Reference: 11, EventId: 2, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+		}, nil).Once()
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(driver.ErrBadConn).Once()
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Once()
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	// The failed save only runs the report transaction again, without reading and comparing the cases again
+	mockQueryRepo.AssertNumberOfCalls(t, "findCasesByJurisdictionInImpactPeriod", 2)
+	mockSaveRepo.AssertExpectations(t)
+}
+
+func TestService_RetryFailedBatchesComparesDeadLetteredBatches(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.CaseId = "1,2"
+	cfg.Scan.DeadLetterFile = filepath.Join(t.TempDir(), "failed_batches.ndjson")
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1", "2"}, Comparison{}).
+		Return([]CaseDataEntity{}, errors.New("relation case_event does not exist")).Once()
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	works, err := readFailedBatches(cfg.Scan.DeadLetterFile)
+	assert.NoError(t, err)
+	assert.Len(t, works, 1)
+	assert.Equal(t, []string{"1", "2"}, works[0].caseIds)
+
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1", "2"}, mock.Anything).
+		Return([]CaseDataEntity{
+			{CaseId: 1, Reference: 11, EventId: 1, EventCreatedDate: createdDate, EventData: `{"name": "a"}`},
+			{CaseId: 1, Reference: 11, EventId: 2, EventCreatedDate: createdDate, EventData: `{"name": "b"}`},
+		}, nil).Once()
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Once()
+
+	assert.NoError(t, service.RetryFailedBatches(context.Background()))
+
+	mockSaveRepo.AssertExpectations(t)
+	assert.NoFileExists(t, cfg.Scan.DeadLetterFile)
+	assert.FileExists(t, cfg.Scan.DeadLetterFile+".retried")
+}
+
+func TestService_RetryFailedBatchesRecordsTheBatchesLeftWhenInterrupted(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.DeadLetterFile = filepath.Join(t.TempDir(), "failed_batches.ndjson")
+	summary := NewRunSummary()
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, summary)
+
+	deadLetters := newDeadLetterFile(cfg.Scan.DeadLetterFile)
+	assert.NoError(t, deadLetters.write(comparisonWork{caseIds: []string{"1"}}, errors.New("failed")))
+	assert.NoError(t, deadLetters.write(comparisonWork{caseIds: []string{"2"}}, errors.New("failed")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, service.RetryFailedBatches(ctx))
+
+	mockQueryRepo.AssertNotCalled(t, "findCasesByJurisdictionInImpactPeriod", mock.Anything, mock.Anything)
+	works, err := readFailedBatches(cfg.Scan.DeadLetterFile)
+	assert.NoError(t, err)
+	assert.Len(t, works, 2)
+	assert.Equal(t, []string{"1"}, works[0].caseIds)
+	assert.Equal(t, []string{"2"}, works[1].caseIds)
+	assert.Equal(t, 2, summary.interruptedBatches)
+}
+
+func TestService_CompareEventsInImpactPeriodStopsDispatchingWhenInterrupted(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.BatchSize = 1
+	checkpoints, err := NewCheckpoints(filepath.Join(t.TempDir(), "checkpoints.ndjson"), time.Now(), 0)
+	assert.NoError(t, err)
+	summary := NewRunSummary()
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, checkpoints, summary)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", Comparison{}, int64(0), defaultDiscoveryPageSize).
+		Return([]string{"1", "2", "3"}, nil).Run(func(args mock.Arguments) { cancel() }).Once()
+
+	service.CompareEventsInImpactPeriod(ctx, Comparison{})
+
+	mockQueryRepo.AssertExpectations(t)
+	mockQueryRepo.AssertNotCalled(t, "findCasesByJurisdictionInImpactPeriod", mock.Anything, mock.Anything)
+	assert.Equal(t, 0, summary.dispatchedCases)
+	assert.Equal(t, 0, summary.completedBatches)
+}
+
+func TestService_CompareEventsInImpactPeriodComparesWatchedFieldsOnly(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Scan.CaseId = "1"
+	cfg.Scan.WatchedFields = []string{".applicant.name"}
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	createdDate := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}, Comparison{}).
+		Return([]CaseDataEntity{
+			{CaseId: 1, Reference: 11, EventId: 1, EventCreatedDate: createdDate,
+				EventData: `{"applicant": {"name": "Jo", "age": 30}, "notes": "a"}`},
+			{CaseId: 1, Reference: 11, EventId: 2, EventCreatedDate: createdDate,
+				EventData: `{"applicant": {"name": "Joe", "age": 31}, "notes": "b"}`},
+		}, nil)
+
+	var entities []comparator.EventDataReportEntity
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil).Once().
+		Run(func(args mock.Arguments) {
+			entities = args.Get(0).([]comparator.EventDataReportEntity)
+		})
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	mockSaveRepo.AssertExpectations(t)
+	assert.Len(t, entities, 1)
+	assert.Equal(t, ".applicant.name", entities[0].FieldName)
+	assert.Equal(t, "Jo", entities[0].OldRecord)
+	assert.Equal(t, "Joe", entities[0].NewRecord)
+}
+
+func TestService_CompareEventsSinceWatermarkMovesTheWatermark(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Report.Enabled = false
+	cfg.Database.WatermarkTable = "comparator_watermark"
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	c := Comparison{
+		Jurisdiction:        "jurisdiction",
+		CaseTypeId:          "caseType",
+		StartTime:           time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC),
+		SearchPeriodEndTime: time.Date(2023, 8, 3, 0, 0, 0, 0, time.UTC),
+	}
+	watermark := &Watermark{Jurisdiction: "jurisdiction", CaseTypeId: "caseType",
+		LastEventCreatedDate: time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC), LastEventId: 100}
+	latest := &Watermark{Jurisdiction: "jurisdiction", CaseTypeId: "caseType",
+		LastEventCreatedDate: time.Date(2023, 8, 2, 10, 0, 0, 0, time.UTC), LastEventId: 200}
+
+	scanned := c
+	scanned.StartTime = watermark.LastEventCreatedDate
+	scanned.AfterEventId = 100
+	mockSaveRepo.On("findWatermark", "comparator_watermark", "jurisdiction", "caseType").Return(watermark, nil)
+	mockQueryRepo.On("findLatestEvent", scanned).Return(latest, nil)
+
+	scanned.SearchPeriodEndTime = latest.LastEventCreatedDate
+	scanned.UntilEventId = 200
+	scanned.Incremental = true
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", scanned, int64(0), defaultDiscoveryPageSize).
+		Return([]string{"10"}, nil).Once()
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"10"}, scanned).
+		Return([]CaseDataEntity{}, nil).Once()
+	mockSaveRepo.On("saveWatermark", "comparator_watermark", *latest).Return(nil).Once()
+
+	assert.NoError(t, service.CompareEventsSinceWatermark(context.Background(), c, time.Now()))
+
+	mockQueryRepo.AssertExpectations(t)
+	mockSaveRepo.AssertExpectations(t)
+}
+
+func TestService_CompareEventsSinceWatermarkLeavesTheLagToTheNextScan(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.IncrementalLagSeconds = 3600
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	runStartedAt := time.Date(2023, 8, 2, 12, 0, 0, 0, time.UTC)
+	mockSaveRepo.On("findWatermark", mock.Anything, "jurisdiction", "").Return((*Watermark)(nil), nil)
+	mockQueryRepo.On("findLatestEvent", Comparison{Jurisdiction: "jurisdiction",
+		SearchPeriodEndTime: runStartedAt.Add(-time.Hour)}).Return((*Watermark)(nil), nil).Once()
+
+	assert.NoError(t, service.CompareEventsSinceWatermark(context.Background(),
+		Comparison{Jurisdiction: "jurisdiction", SearchPeriodEndTime: runStartedAt}, runStartedAt))
+
+	mockQueryRepo.AssertExpectations(t)
+}
+
+func TestService_CompareEventsSinceWatermarkResumesTheScanOfTheInterruptedRun(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Report.Enabled = false
+	cfg.Scan.BatchSize = 1
+	cfg.IncrementalLagSeconds = 300
+	cfg.Database.WatermarkTable = "comparator_watermark"
+	path := filepath.Join(t.TempDir(), "checkpoints.ndjson")
+	runStartedAt := time.Date(2023, 8, 2, 12, 0, 0, 0, time.UTC)
+	checkpoints, err := NewCheckpoints(path, runStartedAt, 0)
+	assert.NoError(t, err)
+
+	// The interrupted run scanned up to the lag before it started, and completed the batch of case 10
+	scanned := Comparison{
+		Jurisdiction:        "jurisdiction",
+		CaseTypeId:          "caseType",
+		StartTime:           time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC),
+		SearchPeriodEndTime: runStartedAt.Add(-5 * time.Minute),
+	}
+	assert.NoError(t, checkpoints.complete(comparisonWork{comparison: scanned, caseIds: []string{"10"}}, nil))
+	resumed, err := ResumeCheckpoints(path, checkpoints.RunId())
+	assert.NoError(t, err)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, resumed, nil)
+
+	latest := &Watermark{Jurisdiction: "jurisdiction", CaseTypeId: "caseType",
+		LastEventCreatedDate: time.Date(2023, 8, 2, 11, 0, 0, 0, time.UTC), LastEventId: 200}
+	mockSaveRepo.On("findWatermark", "comparator_watermark", "jurisdiction", "caseType").
+		Return((*Watermark)(nil), nil)
+	mockQueryRepo.On("findLatestEvent", scanned).Return(latest, nil).Once()
+
+	scanned.SearchPeriodEndTime = latest.LastEventCreatedDate
+	scanned.UntilEventId = 200
+	scanned.Incremental = true
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", scanned, int64(0), defaultDiscoveryPageSize).
+		Return([]string{"10", "11"}, nil).Once()
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"11"}, scanned).
+		Return([]CaseDataEntity{}, nil).Once()
+	mockSaveRepo.On("saveWatermark", "comparator_watermark", *latest).Return(nil).Once()
+
+	// The period without an end time is resolved at the start of the interrupted run, and the scan ends where the
+	// interrupted run's did however late the run is resumed
+	assert.NoError(t, service.CompareEventsSinceWatermark(context.Background(),
+		Comparison{Jurisdiction: "jurisdiction", CaseTypeId: "caseType", StartTime: scanned.StartTime,
+			SearchPeriodEndTime: resumed.RunStartedAt()}, resumed.RunStartedAt()))
+
+	mockQueryRepo.AssertExpectations(t)
+	mockSaveRepo.AssertExpectations(t)
+}
+
+func TestService_CompareEventsSinceWatermarkWithoutNewEvents(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	watermark := &Watermark{Jurisdiction: "jurisdiction",
+		LastEventCreatedDate: time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC), LastEventId: 100}
+	mockSaveRepo.On("findWatermark", mock.Anything, "jurisdiction", "").Return(watermark, nil)
+	mockQueryRepo.On("findLatestEvent", mock.Anything).Return(watermark, nil)
+
+	assert.NoError(t, service.CompareEventsSinceWatermark(context.Background(),
+		Comparison{Jurisdiction: "jurisdiction"}, time.Now()))
+
+	mockQueryRepo.AssertNotCalled(t, "findCasesByEventsInImpactPeriod", mock.Anything, mock.Anything, mock.Anything)
+	mockSaveRepo.AssertNotCalled(t, "saveWatermark", mock.Anything, mock.Anything)
+}
+
+func TestService_CompareEventsSinceWatermarkKeepsTheWatermarkOfLostEvents(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	latest := &Watermark{Jurisdiction: "jurisdiction",
+		LastEventCreatedDate: time.Date(2023, 8, 2, 10, 0, 0, 0, time.UTC), LastEventId: 200}
+	mockSaveRepo.On("findWatermark", mock.Anything, "jurisdiction", "").Return((*Watermark)(nil), nil)
+	mockQueryRepo.On("findLatestEvent", mock.Anything).Return(latest, nil)
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", mock.Anything, int64(0), defaultDiscoveryPageSize).
+		Return([]string{}, errors.New("relation case_event does not exist"))
+
+	err := service.CompareEventsSinceWatermark(context.Background(), Comparison{Jurisdiction: "jurisdiction"},
+		time.Now())
+
+	assert.EqualError(t, err, "the watermark of jurisdiction: jurisdiction and caseType:  is kept as events "+
+		"couldn't be compared")
+	mockSaveRepo.AssertNotCalled(t, "saveWatermark", mock.Anything, mock.Anything)
+}
+
+func TestService_CompareEventsSinceWatermarkKeepsTheWatermarkWhenInterrupted(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	latest := &Watermark{Jurisdiction: "jurisdiction",
+		LastEventCreatedDate: time.Date(2023, 8, 2, 10, 0, 0, 0, time.UTC), LastEventId: 200}
+	mockSaveRepo.On("findWatermark", mock.Anything, "jurisdiction", "").Return((*Watermark)(nil), nil)
+	mockQueryRepo.On("findLatestEvent", mock.Anything).Return(latest, nil).
+		Run(func(args mock.Arguments) { cancel() })
+
+	err := service.CompareEventsSinceWatermark(ctx, Comparison{Jurisdiction: "jurisdiction"}, time.Now())
+
+	assert.EqualError(t, err, "the watermark of jurisdiction: jurisdiction and caseType:  is kept as events "+
+		"couldn't be compared")
+	mockQueryRepo.AssertNotCalled(t, "findCasesByEventsInImpactPeriod", mock.Anything, mock.Anything,
+		mock.Anything)
+	mockSaveRepo.AssertNotCalled(t, "saveWatermark", mock.Anything, mock.Anything)
+}
+
+func TestService_CompareEventsInImpactPeriodSkipsCheckpointedBatches(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Report.Enabled = false
+	cfg.Scan.BatchSize = 2
+	path := filepath.Join(t.TempDir(), "checkpoints.ndjson")
+	checkpoints, err := NewCheckpoints(path, time.Now(), 0)
+	assert.NoError(t, err)
+
+	c := Comparison{Jurisdiction: "jurisdiction", CaseTypeId: "caseType"}
+	assert.NoError(t, checkpoints.complete(comparisonWork{comparison: c, caseIds: []string{"10", "11"}}, nil))
+	resumed, err := ResumeCheckpoints(path, checkpoints.RunId())
+	assert.NoError(t, err)
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, resumed, nil)
+
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", c, int64(0), defaultDiscoveryPageSize).
+		Return([]string{"10", "11", "12"}, nil).Once()
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"12"}, c).
+		Return([]CaseDataEntity{}, nil).Once()
+
+	service.CompareEventsInImpactPeriod(context.Background(), c)
+
+	mockQueryRepo.AssertExpectations(t)
+	resumed, err = ResumeCheckpoints(path, checkpoints.RunId())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, resumed.CompletedBatchCount())
+	assert.Empty(t, resumed.remaining(c, []string{"10", "11", "12"}))
+}
+
+func TestService_CompareEventsInImpactPeriodSummarisesTheComparedCases(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	summary := NewRunSummary()
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, summary)
+
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", Comparison{}, int64(0), defaultDiscoveryPageSize).
+		Return([]string{"1"}, nil)
+	mockQueryRepo.On("findCasesByJurisdictionInImpactPeriod", []string{"1"}, Comparison{}).
+		Return([]CaseDataEntity{
+			{Reference: 1, CaseId: 1, EventId: 1, EventCreatedDate: time.Now(),
+				EventData: "{\"name\": \"John\", \"city\": \"New York\"}"},
+			{Reference: 1, CaseId: 1, EventId: 2, EventCreatedDate: time.Now(),
+				EventData: "{\"name\": \"Mary\", \"city\": \"Los Angeles\"}"},
+			{Reference: 1, CaseId: 1, EventId: 3, EventCreatedDate: time.Now(),
+				EventData: "{\"city\": \"New York\", \"extra\": \"test\"}"},
+		}, nil)
+	mockSaveRepo.On("saveAllEventDataReport", mock.Anything).Return(nil)
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	report, err := summary.Report("", false, cfg.Rule)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.DispatchedCases)
+	assert.Equal(t, 1, report.ScannedCases)
+	assert.Equal(t, 3, report.ComparedEvents)
+	assert.Equal(t, 1, report.CompletedBatches)
+	assert.Equal(t, map[string]int{"ADDED": 1, "DELETED": 1, "MODIFIED": 3}, report.FieldChanges)
+	assert.Equal(t, []RuleViolations{{Rule: "staticfieldchange", Severity: "high", Count: 1}}, report.Violations)
+	assert.Len(t, report.StageSeconds, 4)
+	assert.Equal(t, ExitCodeSuccess, report.ExitCode)
+}
+
+func TestService_CompareEventsInImpactPeriodRecordsFailedDiscoveries(t *testing.T) {
+	setUp()
+	defer cleanUp()
+
+	mockQueryRepo := new(MockQueryRepository)
+	mockSaveRepo := new(MockSaveRepository)
+
+	enabledRuleList := comparator.NewRuleFactory(cfg).GetEnabledRuleList()
+
+	cfg.Database.Retry.MaxAttempts = 1
+	summary := NewRunSummary()
+	service := NewService(cfg, &enabledRuleList, nil, mockQueryRepo, mockSaveRepo, nil, summary)
+
+	mockQueryRepo.On("findCasesByEventsInImpactPeriod", Comparison{}, int64(0), defaultDiscoveryPageSize).
+		Return([]string{}, errors.New("error occurred"))
+
+	service.CompareEventsInImpactPeriod(context.Background(), Comparison{})
+
+	report, err := summary.Report("", false, cfg.Rule)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.FailedScans)
+	assert.Equal(t, ExitCodeFailure, report.ExitCode)
 }
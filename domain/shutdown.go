@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+var errRunInterrupted = errors.New("the run was interrupted before the batch was compared")
+
+// shutdownContext returns the context of the batches in progress. It isn't done when ctx is, so that the workers
+// finish their batches once the run is interrupted, but timeout later, cancelling their queries and rolling back
+// their transactions.
+func shutdownContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	workCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		log.Warn().Msgf("Waiting up to %s for the batches in progress to finish", timeout)
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			log.Warn().Msgf("Cancelling the batches still in progress after %s", timeout)
+			cancel()
+		case <-workCtx.Done():
+		}
+	})
+
+	return workCtx, func() {
+		stop()
+		cancel()
+	}
+}
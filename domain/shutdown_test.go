@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestShutdownContext_CancelsTheWorkAfterTheTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	workCtx, stop := shutdownContext(ctx, 20*time.Millisecond)
+	defer stop()
+
+	cancel()
+	assert.NoError(t, workCtx.Err(), "the work in progress has the timeout to finish")
+
+	select {
+	case <-workCtx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the work wasn't cancelled after the timeout")
+	}
+	assert.ErrorIs(t, workCtx.Err(), context.Canceled)
+}
+
+func TestShutdownContext_IsDoneWhenStopped(t *testing.T) {
+	workCtx, stop := shutdownContext(context.Background(), time.Hour)
+
+	assert.NoError(t, workCtx.Err())
+	stop()
+	assert.ErrorIs(t, workCtx.Err(), context.Canceled)
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/internal/store"
+	"context"
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+)
+
+// eventContextColumns are the case_event columns describing an event beyond its data. Older CCD databases and
+// report tables don't have all of them, so only the ones the table has are read and written.
+var eventContextColumns = []string{"state_name", "summary", "description", "user_first_name", "user_last_name",
+	"proxied_by"}
+
+// tableColumns caches the columns of the tables, read from information_schema the first time a table is used.
+type tableColumns struct {
+	mutex   sync.Mutex
+	columns map[string]map[string]bool
+}
+
+func newTableColumns() *tableColumns {
+	return &tableColumns{columns: make(map[string]map[string]bool)}
+}
+
+// find returns the columns of the table. A table without a schema is looked up in the schemas of the search path.
+func (t *tableColumns) find(ctx context.Context, db store.DB, table string) (map[string]bool, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if columns, found := t.columns[table]; found {
+		return columns, nil
+	}
+
+	query := `SELECT column_name FROM information_schema.columns WHERE table_name = $1`
+	args := []interface{}{table}
+	if schema, name, found := strings.Cut(table, "."); found {
+		query += ` AND table_schema = $2`
+		args = []interface{}{name, schema}
+	} else {
+		query += ` AND table_schema = ANY(current_schemas(false))`
+	}
+
+	var names []string
+	if err := db.Select(ctx, &names, query, args...); err != nil {
+		return nil, errors.Wrapf(err, "error while reading the columns of %s", table)
+	}
+
+	columns := make(map[string]bool, len(names))
+	for _, name := range names {
+		columns[name] = true
+	}
+	t.columns[table] = columns
+	return columns, nil
+}
+
+// missingColumns returns the wanted columns the table doesn't have.
+func missingColumns(columns map[string]bool, wanted []string) []string {
+	var missing []string
+	for _, column := range wanted {
+		if !columns[column] {
+			missing = append(missing, column)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"ccd-comparator-data-diff-rapid/comparator"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WatchedFieldChangeEntity is a change of a watched field between an event and the previous event of the case.
+// The values are JSON and null when the field is missing from the event.
+type WatchedFieldChangeEntity struct {
+	CaseId           int64          `db:"case_id"`
+	Reference        int64          `db:"reference"`
+	CaseTypeId       string         `db:"case_type_id"`
+	EventId          int64          `db:"event_id"`
+	EventName        string         `db:"event_name"`
+	EventCreatedDate time.Time      `db:"event_created_date"`
+	UserId           string         `db:"user_id"`
+	PreviousStateId  string         `db:"previous_state_id"`
+	FieldPath        string         `db:"field_path"`
+	OldValue         sql.NullString `db:"old_value"`
+	NewValue         sql.NullString `db:"new_value"`
+	StateId          string         `db:"state_id"`
+	EventContextEntity
+}
+
+// normalizeWatchedFields trims the configured field paths, which may be written with a leading dot as they appear
+// in the report.
+func normalizeWatchedFields(fields []string) []string {
+	var normalized []string
+	for _, field := range fields {
+		field = strings.TrimPrefix(strings.TrimSpace(field), ".")
+		if field != "" {
+			normalized = append(normalized, field)
+		}
+	}
+	return normalized
+}
+
+// selectWatchedFieldChanges finds the watched field changes between consecutive events in Go, for the event sources
+// that can't push the comparison down to the database. The events must be in case and event order.
+func selectWatchedFieldChanges(events []CaseDataEntity, fields []string) []WatchedFieldChangeEntity {
+	var changes []WatchedFieldChangeEntity
+	var previous CaseDataEntity
+	var previousValues []sql.NullString
+
+	for i, event := range events {
+		var data any
+		_ = json.Unmarshal([]byte(event.EventData), &data)
+
+		values := make([]sql.NullString, len(fields))
+		for f, field := range fields {
+			values[f] = extractFieldValue(data, strings.Split(field, "."))
+		}
+
+		if i > 0 && event.CaseId == previous.CaseId {
+			for f, field := range fields {
+				if values[f] == previousValues[f] {
+					continue
+				}
+				changes = append(changes, WatchedFieldChangeEntity{
+					CaseId:             event.CaseId,
+					Reference:          event.Reference,
+					CaseTypeId:         event.CaseTypeId,
+					EventId:            event.EventId,
+					EventName:          event.EventName,
+					EventCreatedDate:   event.EventCreatedDate,
+					UserId:             event.UserId,
+					PreviousStateId:    previous.StateId,
+					FieldPath:          field,
+					OldValue:           previousValues[f],
+					NewValue:           values[f],
+					StateId:            event.StateId,
+					EventContextEntity: event.EventContextEntity,
+				})
+			}
+		}
+
+		previous = event
+		previousValues = values
+	}
+
+	return changes
+}
+
+// streamWatchedFieldChanges compares the watched fields of the streamed events one case at a time.
+func streamWatchedFieldChanges(ctx context.Context, source EventSource, caseIds []string, comparison Comparison,
+	fields []string) ([]WatchedFieldChangeEntity, error) {
+	var changes []WatchedFieldChangeEntity
+	var caseEvents []CaseDataEntity
+
+	err := source.streamEventsInImpactPeriod(ctx, caseIds, comparison, func(event CaseDataEntity) error {
+		if len(caseEvents) > 0 && caseEvents[0].CaseId != event.CaseId {
+			changes = append(changes, selectWatchedFieldChanges(caseEvents, fields)...)
+			caseEvents = nil
+		}
+		caseEvents = append(caseEvents, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(changes, selectWatchedFieldChanges(caseEvents, fields)...), nil
+}
+
+// extractFieldValue follows the path through objects and arrays like the jsonb #> operator.
+func extractFieldValue(data any, path []string) sql.NullString {
+	for _, segment := range path {
+		switch node := data.(type) {
+		case map[string]any:
+			value, found := node[segment]
+			if !found {
+				return sql.NullString{}
+			}
+			data = value
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return sql.NullString{}
+			}
+			data = node[index]
+		default:
+			return sql.NullString{}
+		}
+	}
+
+	value, err := json.Marshal(data)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(value), Valid: true}
+}
+
+func toFieldValueChanges(entities []WatchedFieldChangeEntity) []comparator.FieldValueChange {
+	changes := make([]comparator.FieldValueChange, 0, len(entities))
+	for _, entity := range entities {
+		changes = append(changes, comparator.FieldValueChange{
+			CaseReference: entity.Reference,
+			Path:          entity.FieldPath,
+			OldValue:      entity.OldValue.String,
+			NewValue:      entity.NewValue.String,
+			Event: entity.EventContextEntity.withEventDetails(comparator.EventDetails{
+				Id:          entity.EventId,
+				Name:        entity.EventName,
+				CreatedDate: entity.EventCreatedDate,
+				CaseDataId:  entity.CaseId,
+				UserId:      entity.UserId,
+				CaseTypeId:  entity.CaseTypeId,
+				StateId:     entity.StateId,
+			}),
+			PreviousStateId: entity.PreviousStateId,
+		})
+	}
+	return changes
+}
+
+// watchedFieldOutcomes reports whether each case of the batch, by case id, has a violation on its watched fields.
+func watchedFieldOutcomes(caseIds []string, changes []WatchedFieldChangeEntity,
+	violatingReferences map[int64]bool) map[int64]bool {
+	outcomes := make(map[int64]bool, len(caseIds))
+	for _, caseId := range caseIds {
+		if id, err := strconv.ParseInt(caseId, 10, 64); err == nil {
+			outcomes[id] = false
+		}
+	}
+	for _, change := range changes {
+		if violatingReferences[change.Reference] {
+			outcomes[change.CaseId] = true
+		}
+	}
+	return outcomes
+}
+
+// countChangedEvents returns the number of events that changed a watched field, the only events fetched.
+func countChangedEvents(changes []WatchedFieldChangeEntity) int {
+	eventIds := make(map[int64]bool)
+	for _, change := range changes {
+		eventIds[change.EventId] = true
+	}
+	return len(eventIds)
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSelectWatchedFieldChanges(t *testing.T) {
+	events := []CaseDataEntity{
+		{CaseId: 1, Reference: 11, EventId: 1, StateId: "open", EventData: `{"name": "a", "items": [{"v": 1}]}`},
+		{CaseId: 1, Reference: 11, EventId: 2, StateId: "open", EventName: "update",
+			EventData: `{"name": "b", "items": [{"v": 1}]}`},
+		{CaseId: 1, Reference: 11, EventId: 3, StateId: "closed", EventData: `{"items": [{"v": 2}]}`},
+		{CaseId: 2, Reference: 22, EventId: 4, EventData: `{"name": "b"}`},
+	}
+
+	changes := selectWatchedFieldChanges(events, []string{"name", "items.0.v"})
+
+	assert.Equal(t, []WatchedFieldChangeEntity{
+		{CaseId: 1, Reference: 11, EventId: 2, EventName: "update", PreviousStateId: "open", FieldPath: "name",
+			OldValue: sql.NullString{String: `"a"`, Valid: true}, NewValue: sql.NullString{String: `"b"`, Valid: true},
+			StateId: "open"},
+		{CaseId: 1, Reference: 11, EventId: 3, PreviousStateId: "open", FieldPath: "name",
+			OldValue: sql.NullString{String: `"b"`, Valid: true}, StateId: "closed"},
+		{CaseId: 1, Reference: 11, EventId: 3, PreviousStateId: "open", FieldPath: "items.0.v",
+			OldValue: sql.NullString{String: `1`, Valid: true}, NewValue: sql.NullString{String: `2`, Valid: true},
+			StateId: "closed"},
+	}, changes)
+}
+
+func TestNormalizeWatchedFields(t *testing.T) {
+	assert.Equal(t, []string{"applicant.name", "claimAmount"},
+		normalizeWatchedFields([]string{" .applicant.name", "", "claimAmount"}))
+	assert.Empty(t, normalizeWatchedFields(nil))
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Watermark is the latest event compared by the incremental scans of a jurisdiction and case types.
+type Watermark struct {
+	Jurisdiction         string    `db:"jurisdiction"`
+	CaseTypeId           string    `db:"case_type_id"`
+	LastEventCreatedDate time.Time `db:"last_event_created_date"`
+	LastEventId          int64     `db:"last_event_id"`
+}
+
+// precedes reports whether the watermark is before the event, in the creation date and id order of the scans.
+func (w Watermark) precedes(event Watermark) bool {
+	return w.LastEventCreatedDate.Before(event.LastEventCreatedDate) ||
+		(w.LastEventCreatedDate.Equal(event.LastEventCreatedDate) && w.LastEventId < event.LastEventId)
+}
+
+// incrementalRun notes whether events of an incremental scan were neither compared nor recorded in the dead letter
+// file, as moving the watermark past them would never compare them.
+type incrementalRun struct {
+	lostEvents atomic.Bool
+}
+
+func newIncrementalRun() *incrementalRun {
+	return &incrementalRun{}
+}
+
+// markLostEvents is a no-op when the scan isn't incremental.
+func (r *incrementalRun) markLostEvents() {
+	if r != nil {
+		r.lostEvents.Store(true)
+	}
+}
+
+func (r *incrementalRun) hasLostEvents() bool {
+	return r != nil && r.lostEvents.Load()
+}
@@ -8,17 +8,21 @@ import (
 const defaultTimeStampLayout = "2006-01-02T15:04:05.999999"
 
 func MustParseTime(layout, value string) time.Time {
-	if layout == "" {
-		layout = defaultTimeStampLayout
-	}
-
-	parsedTime, err := time.Parse(layout, value)
+	parsedTime, err := ParseTime(layout, value)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to parse time: %s", err))
 	}
 	return parsedTime
 }
 
+// ParseTime parses the value with the layout, or with the default timestamp layout when the layout is empty.
+func ParseTime(layout, value string) (time.Time, error) {
+	if layout == "" {
+		layout = defaultTimeStampLayout
+	}
+	return time.Parse(layout, value)
+}
+
 func FormatTimeStamp(sourceTimeStamp time.Time) string {
 	return sourceTimeStamp.Format(defaultTimeStampLayout)
 }
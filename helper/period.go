@@ -0,0 +1,75 @@
+package helper
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// periodExpression matches the relative period values, an anchor optionally shifted by a number of minutes, hours,
+// days or weeks, such as now-7d or yesterday+6h.
+var periodExpression = regexp.MustCompile(`^(now|today|yesterday|this-month|last-month)(?:([+-])(\d+)([mhdw]))?$`)
+
+// ParsePeriodTime parses a period value and returns it in UTC, like the event dates. The value is RFC 3339 with an
+// offset, a timestamp or a date read in the location, or a relative expression evaluated at now in the location.
+// today, yesterday, this-month and last-month start at midnight, and days and weeks are added to the wall clock so
+// that they don't shift by an hour across daylight saving changes.
+func ParsePeriodTime(value string, now time.Time, location *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return parsed.UTC(), nil
+	}
+	for _, layout := range []string{defaultTimeStampLayout, dateLayout} {
+		if parsed, err := time.ParseInLocation(layout, value, location); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+
+	match := periodExpression.FindStringSubmatch(strings.ToLower(value))
+	if match == nil {
+		return time.Time{}, errors.Errorf("invalid period time '%s', expected RFC 3339, %s, %s or an expression "+
+			"such as now-7d, yesterday or last-month", value, defaultTimeStampLayout, dateLayout)
+	}
+
+	now = now.In(location)
+	year, month, day := now.Date()
+	var anchor time.Time
+	switch match[1] {
+	case "now":
+		anchor = now
+	case "today":
+		anchor = time.Date(year, month, day, 0, 0, 0, 0, location)
+	case "yesterday":
+		anchor = time.Date(year, month, day-1, 0, 0, 0, 0, location)
+	case "this-month":
+		anchor = time.Date(year, month, 1, 0, 0, 0, 0, location)
+	case "last-month":
+		anchor = time.Date(year, month-1, 1, 0, 0, 0, 0, location)
+	}
+
+	if match[2] != "" {
+		amount, err := strconv.Atoi(match[3])
+		if err != nil {
+			return time.Time{}, errors.Wrapf(err, "invalid period time '%s'", value)
+		}
+		if match[2] == "-" {
+			amount = -amount
+		}
+		switch match[4] {
+		case "m":
+			anchor = anchor.Add(time.Duration(amount) * time.Minute)
+		case "h":
+			anchor = anchor.Add(time.Duration(amount) * time.Hour)
+		case "d":
+			anchor = anchor.AddDate(0, 0, amount)
+		case "w":
+			anchor = anchor.AddDate(0, 0, 7*amount)
+		}
+	}
+
+	return anchor.UTC(), nil
+}
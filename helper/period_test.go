@@ -0,0 +1,50 @@
+package helper
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParsePeriodTime(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	assert.NoError(t, err)
+	// 2024-04-02 12:00 BST, two days after the clocks went forward
+	now := time.Date(2024, 4, 2, 11, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"2024-07-01T10:00:00+02:00", time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)},
+		{"2024-07-01T10:00:00Z", time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)},
+		{"2024-07-01T10:00:00.000", time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)},
+		{"2024-01-15T10:00:00", time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{"2024-07-01", time.Date(2024, 6, 30, 23, 0, 0, 0, time.UTC)},
+		{"now", now},
+		{"now-12h", time.Date(2024, 4, 1, 23, 0, 0, 0, time.UTC)},
+		{"now-7d", time.Date(2024, 3, 26, 12, 0, 0, 0, time.UTC)},
+		{"now+30m", time.Date(2024, 4, 2, 11, 30, 0, 0, time.UTC)},
+		{"today", time.Date(2024, 4, 1, 23, 0, 0, 0, time.UTC)},
+		{"yesterday", time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)},
+		{"today-2d", time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"Last-Month", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"this-month", time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)},
+		{"this-month-1w", time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		parsed, err := ParsePeriodTime(test.value, now, london)
+
+		assert.NoError(t, err, test.value)
+		assert.Equal(t, test.expected, parsed, test.value)
+	}
+}
+
+func TestParsePeriodTime_InvalidValue(t *testing.T) {
+	for _, value := range []string{"", "next-week", "now-7y", "01/07/2024", "2024-07-01T10:00"} {
+		_, err := ParsePeriodTime(value, time.Now(), time.UTC)
+
+		assert.Error(t, err, value)
+	}
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -12,13 +13,13 @@ import (
 )
 
 const (
-	maxOpenConnection = 25
-	maxIdleConnection = 25
-	connMaxLifetime   = 5 * time.Minute
+	defaultMaxOpenConnection = 25
+	defaultMaxIdleConnection = 25
+	connMaxLifetime          = 5 * time.Minute
 )
 
 type Transaction interface {
-	NamedExec(query string, arg interface{}) (interface{}, error)
+	NamedExec(ctx context.Context, query string, arg interface{}) (interface{}, error)
 	Commit() error
 	Rollback() error
 }
@@ -27,67 +28,118 @@ type txWrapper struct {
 	tx *sqlx.Tx
 }
 
-func (t txWrapper) NamedExec(query string, arg interface{}) (interface{}, error) {
-	return t.tx.NamedExec(query, arg)
+func (t txWrapper) NamedExec(ctx context.Context, query string, arg interface{}) (interface{}, error) {
+	result, err := t.tx.NamedExecContext(ctx, query, arg)
+	return result, wrapConnectionError(err)
 }
 
+// Commit errors are never retried, as the server may have committed the transaction before the failure.
 func (t txWrapper) Commit() error {
-	return t.tx.Commit()
+	if err := t.tx.Commit(); err != nil {
+		return commitError{err}
+	}
+	return nil
 }
 
 func (t txWrapper) Rollback() error {
 	return t.tx.Rollback()
 }
 
+// Rows iterates over a query result one row at a time.
+type Rows interface {
+	Next() bool
+	StructScan(dest interface{}) error
+	Err() error
+	Close() error
+}
+
+// DB runs the statements within the context, which cancels them when it is done. A transaction begun with a
+// context is rolled back when the context is done before it commits.
 type DB interface {
-	MustBegin() Transaction
-	Select(dest interface{}, query string, args ...interface{}) error
+	Begin(ctx context.Context) (Transaction, error)
+	Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	Queryx(ctx context.Context, query string, args ...interface{}) (Rows, error)
+}
+
+type rowsWrapper struct {
+	*sqlx.Rows
+}
+
+func (r rowsWrapper) StructScan(dest interface{}) error {
+	return wrapConnectionError(r.Rows.StructScan(dest))
+}
+
+func (r rowsWrapper) Err() error {
+	return wrapConnectionError(r.Rows.Err())
 }
 
 type sqlxDB struct {
 	dbx *sqlx.DB
 }
 
-func (s sqlxDB) Select(dest interface{}, query string, args ...interface{}) error {
-	return s.dbx.Select(dest, query, args...)
+func (s sqlxDB) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return wrapConnectionError(s.dbx.SelectContext(ctx, dest, query, args...))
 }
 
-func (s sqlxDB) MustBegin() Transaction {
-	tx := s.dbx.MustBegin()
-	return txWrapper{tx}
+func (s sqlxDB) Queryx(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	rows, err := s.dbx.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapConnectionError(err)
+	}
+	return rowsWrapper{rows}, nil
+}
+
+func (s sqlxDB) Begin(ctx context.Context) (Transaction, error) {
+	tx, err := s.dbx.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, wrapConnectionError(err)
+	}
+	return txWrapper{tx}, nil
 }
 
 var (
-	dbInitOnce sync.Once
-	dbInstance DB
+	dbInitOnce     sync.Once
+	sourceInstance DB
+	sinkInstance   DB
 )
 
-func InitDatabase(configuration *config.Configurations) DB {
+// InitDatabases opens the source pool the cases are read from and the sink pool the report is written to. Both use
+// the same pool when the sink connection is the same as the source connection.
+func InitDatabases(configuration *config.Configurations) (source DB, sink DB) {
 	dbInitOnce.Do(func() {
 		var dbConfig = configuration.Database
-		var err error
 
-		var db *sqlx.DB
-		db, err = initializeDB(&dbConfig)
+		sourceDB, err := initializeDB(&dbConfig, &dbConfig.Connection)
 		if err != nil {
-			log.Fatal().Msgf("Failed to initialize the database: %s", err)
+			log.Fatal().Msgf("Failed to initialize the source database: %s", err)
+		}
+		sourceInstance = &sqlxDB{sourceDB}
+
+		sinkConnection := dbConfig.SinkConnection()
+		if sinkConnection == dbConfig.Connection {
+			sinkInstance = sourceInstance
+			return
 		}
 
-		dbInstance = &sqlxDB{db}
+		sinkDB, err := initializeDB(&dbConfig, &sinkConnection)
+		if err != nil {
+			log.Fatal().Msgf("Failed to initialize the sink database: %s", err)
+		}
+		sinkInstance = &sqlxDB{sinkDB}
 	})
-	return dbInstance
+	return sourceInstance, sinkInstance
 }
 
-func initializeDB(config *config.Database) (*sqlx.DB, error) {
-	dataSourceURL := composeDataSourceURL(config)
+func initializeDB(config *config.Database, connection *config.Connection) (*sqlx.DB, error) {
+	dataSourceURL := composeDataSourceURL(connection, config.StatementTimeoutSeconds)
 
 	db, err := sqlx.Open(config.Driver, dataSourceURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to the database: %w", err)
 	}
 
-	db.SetMaxOpenConns(maxOpenConnection)
-	db.SetMaxIdleConns(maxIdleConnection)
+	db.SetMaxOpenConns(valueOrDefault(connection.MaxOpenConnections, defaultMaxOpenConnection))
+	db.SetMaxIdleConns(valueOrDefault(connection.MaxIdleConnections, defaultMaxIdleConnection))
 	db.SetConnMaxLifetime(connMaxLifetime)
 
 	if err = db.Ping(); err != nil {
@@ -95,12 +147,38 @@ func initializeDB(config *config.Database) (*sqlx.DB, error) {
 		return nil, fmt.Errorf("failed to ping the database: %w", err)
 	}
 
-	log.Info().Msg("Database connection is successful.")
+	log.Info().Msgf("Database connection to %s:%d/%s is successful.", connection.Host, connection.Port,
+		connection.Name)
 
 	return db, nil
 }
 
-func composeDataSourceURL(config *config.Database) string {
-	return fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=%s", config.Username,
-		config.Password, config.Host, config.Port, config.Name, config.SslMode)
+func valueOrDefault(value, defaultValue int) int {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func composeDataSourceURL(connection *config.Connection, statementTimeoutSeconds int) string {
+	dataSourceURL := fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=%s", connection.Username,
+		connection.Password, connection.Host, connection.Port, connection.Name, connection.SslMode)
+
+	optionalParameters := []struct{ key, value string }{
+		{"sslrootcert", connection.SslRootCert},
+		{"sslcert", connection.SslCert},
+		{"sslkey", connection.SslKey},
+		{"application_name", connection.ApplicationName},
+	}
+	for _, parameter := range optionalParameters {
+		if parameter.value != "" {
+			dataSourceURL += fmt.Sprintf(" %s=%s", parameter.key, parameter.value)
+		}
+	}
+
+	if statementTimeoutSeconds > 0 {
+		// Sent as a run-time parameter so that the server cancels any statement running longer
+		dataSourceURL += fmt.Sprintf(" statement_timeout=%d", statementTimeoutSeconds*1000)
+	}
+	return dataSourceURL
 }
@@ -10,7 +10,7 @@ import (
 func TestInitializeDBFailure(t *testing.T) {
 	dbInitOnce = sync.Once{} // Reset the sync.Once to allow reinitialization for this test case
 	dbConfig := config.Configurations{Database: config.Database{}}
-	db, err := initializeDB(&dbConfig.Database)
+	db, err := initializeDB(&dbConfig.Database, &dbConfig.Database.Connection)
 	assert.Nil(t, db)
 	assert.Error(t, err)
 }
@@ -19,6 +19,26 @@ func TestGetDataSourceURL(t *testing.T) {
 	appConfig := config.GetConfigurations("../..", "config_test")
 
 	expectedURL := "user=ccd password=ccd host=localhost port=5050 dbname=ccd_data sslmode=disable"
-	url := composeDataSourceURL(&appConfig.Database)
+	url := composeDataSourceURL(&appConfig.Database.Connection, appConfig.Database.StatementTimeoutSeconds)
 	assert.Equal(t, expectedURL, url)
 }
+
+func TestGetSinkDataSourceURL(t *testing.T) {
+	appConfig := config.GetConfigurations("../..", "config_test")
+
+	sinkConnection := appConfig.Database.SinkConnection()
+
+	expectedURL := "user=ccd password=ccd host=localhost port=5050 dbname=ccd_report sslmode=disable " +
+		"application_name=ccd-comparator-report"
+	assert.Equal(t, expectedURL, composeDataSourceURL(&sinkConnection, 0))
+	assert.Equal(t, 5, sinkConnection.MaxOpenConnections)
+}
+
+func TestGetDataSourceURLWithOptionalParameters(t *testing.T) {
+	connection := config.Connection{Username: "ccd", Password: "ccd", Host: "localhost", Port: 5050,
+		Name: "ccd_data", SslMode: "verify-full", SslRootCert: "/certs/root.crt", ApplicationName: "comparator"}
+
+	expectedURL := "user=ccd password=ccd host=localhost port=5050 dbname=ccd_data sslmode=verify-full " +
+		"sslrootcert=/certs/root.crt application_name=comparator statement_timeout=30000"
+	assert.Equal(t, expectedURL, composeDataSourceURL(&connection, 30))
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// retryableCodes are the PostgreSQL error codes of failures that may succeed when the statement is run again.
+var retryableCodes = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"53300": true, // too_many_connections
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// connectionError marks an EOF read from a database connection, which was dropped by the server or the network.
+type connectionError struct {
+	error
+}
+
+func (c connectionError) Unwrap() error {
+	return c.error
+}
+
+// wrapConnectionError marks the EOF errors of database calls, so that they are told apart from the EOF of files and
+// API responses that are cut short.
+func wrapConnectionError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return connectionError{err}
+	}
+	return err
+}
+
+// commitError marks a failed commit, which the server may have applied before the failure.
+type commitError struct {
+	error
+}
+
+func (c commitError) Unwrap() error {
+	return c.error
+}
+
+// IsRetryable reports whether the error is a transient database failure, such as a serialization failure, a
+// dropped connection or a server shutdown, rather than a problem with the statement or the data. Statements
+// cancelled with their context aren't retried, nor are failed commits as running the transaction again could
+// write it twice.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.As(err, &commitError{}) {
+		return false
+	}
+	if errors.As(err, &connectionError{}) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 is connection exception
+		return retryableCodes[pqErr.Code] || pqErr.Code.Class() == "08"
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(&pq.Error{Code: "40001"}))
+	assert.True(t, IsRetryable(errors.Wrap(&pq.Error{Code: "57P01"}, "failed")))
+	assert.True(t, IsRetryable(&pq.Error{Code: "08006"}))
+	assert.True(t, IsRetryable(errors.Wrap(driver.ErrBadConn, "failed")))
+	assert.True(t, IsRetryable(&net.OpError{Op: "read", Err: syscall.ECONNRESET}))
+	assert.True(t, IsRetryable(errors.Wrap(wrapConnectionError(io.EOF), "failed")))
+	assert.True(t, IsRetryable(wrapConnectionError(io.ErrUnexpectedEOF)))
+
+	assert.False(t, IsRetryable(nil))
+	assert.False(t, IsRetryable(&pq.Error{Code: "42P01"})) // undefined_table
+	assert.False(t, IsRetryable(&pq.Error{Code: "57014"})) // query_canceled by the statement timeout
+	assert.False(t, IsRetryable(errors.New("invalid case id")))
+	assert.False(t, IsRetryable(errors.Wrap(context.Canceled, "failed")))
+	assert.False(t, IsRetryable(&net.OpError{Op: "read", Err: context.DeadlineExceeded}))
+	assert.False(t, IsRetryable(errors.Wrap(io.EOF, "failed"))) // a truncated file or API response
+	assert.False(t, IsRetryable(io.ErrUnexpectedEOF))
+	assert.False(t, IsRetryable(errors.Wrap(commitError{driver.ErrBadConn}, "failed")))
+}
@@ -1,33 +1,53 @@
 package main
 
 import (
-	"bufio"
 	"ccd-comparator-data-diff-rapid/comparator"
 	"ccd-comparator-data-diff-rapid/config"
 	"ccd-comparator-data-diff-rapid/domain"
 	"ccd-comparator-data-diff-rapid/helper"
 	"ccd-comparator-data-diff-rapid/internal/store"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strings"
+	"syscall"
 	"time"
+	_ "time/tzdata"
 )
 
 var cpuProfile = flag.Bool("cpu-profile", false, "write cpu profile to `file`")
 var memoryProfile = flag.Bool("mem-profile", false, "write memory profile to `file`")
 var configFile = flag.String("configFile", "./config", "Configuration file")
 var sourceFile = flag.String("sourceFile", "", "File contains existing case types")
+var eventFile = flag.String("eventFile", "", "NDJSON or CSV event export, optionally gzipped, to scan instead of the database")
+var reportFile = flag.String("reportFile", "event_data_report.ndjson", "NDJSON file the report is written to when not scanning the database")
+var retryFailed = flag.Bool("retry-failed", false, "Compare the batches recorded in the dead letter file again instead of scanning")
+var resume = flag.String("resume", "", "Run id of an interrupted run to resume, skipping the batches it completed")
+
+// runStartTime is the time the relative periods are resolved at, when the resumed run first started with -resume.
+var runStartTime = time.Now()
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs the command and returns the exit code of the process.
+func run() int {
 	fmt.Println("Starting...")
 
+	if len(os.Args) > 1 && os.Args[1] == "discover" {
+		discover(os.Args[2:])
+		return domain.ExitCodeSuccess
+	}
+
 	flag.Parse()
 
 	fmt.Printf("Using the target configuration file: %s\n", *configFile)
@@ -41,13 +61,158 @@ func main() {
 
 	enableAndManageProfiles()
 
-	activeRules := comparator.NewRuleFactory(configurations).GetEnabledRuleList()
-	db := store.InitDatabase(configurations)
-	queryRepo := domain.NewQueryRepository(db)
-	saveRepo := domain.NewSaveRepository(db)
-	service := domain.NewService(configurations, &activeRules, queryRepo, saveRepo)
+	ctx := interruptContext()
+	eventSource, saveRepo := initiateRepositories(configurations)
+	summary := domain.NewRunSummary()
+
+	if *retryFailed {
+		service := newService(configurations, eventSource, saveRepo, nil, summary)
+		if err := service.RetryFailedBatches(ctx); err != nil {
+			log.Fatal().Msgf("Couldn't retry the failed batches: %s", err)
+		}
+		return reportRun(ctx, configurations, summary, nil)
+	}
+
+	checkpoints := initiateCheckpoints(configurations)
+	validatePeriod(*configurations)
+	orchestrateEventComparisons(ctx, configurations, eventSource, saveRepo, checkpoints, summary)
+	return reportRun(ctx, configurations, summary, checkpoints)
+}
+
+// interruptContext returns a context done on the first SIGINT or SIGTERM, which stops dispatching batches and gives
+// the batches in progress the shutdown timeout to finish. A second signal terminates the process straight away.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		received := <-signals
+		signal.Stop(signals)
+		log.Warn().Msgf("Received %s, no more batches are dispatched. Send it again to exit straight away.",
+			received)
+		cancel()
+	}()
+	return ctx
+}
+
+// reportRun prints the totals of the run, which are partial when the run was interrupted, writes them to the summary
+// file and logs how the batches that weren't compared can be compared. It returns the exit code of the run.
+func reportRun(ctx context.Context, configurations *config.Configurations, summary *domain.RunSummary,
+	checkpoints *domain.Checkpoints) int {
+	var runId string
+	if checkpoints != nil {
+		runId = checkpoints.RunId()
+	}
+	report, err := summary.Report(runId, ctx.Err() != nil, configurations.Rule)
+	if err != nil {
+		log.Error().Msgf("Couldn't report the run: %s", err)
+		return domain.ExitCodeFailure
+	}
+
+	if err := report.WriteTable(os.Stdout); err != nil {
+		log.Error().Msgf("Couldn't print the run summary: %s", err)
+	}
+	if configurations.SummaryFile != "" {
+		if err := report.WriteFile(configurations.SummaryFile); err != nil {
+			log.Error().Msgf("Couldn't write the run summary: %s", err)
+		} else {
+			log.Info().Msgf("The run summary has been written to %s", configurations.SummaryFile)
+		}
+	}
+	if report.Gate != nil && !report.Gate.Passed {
+		log.Error().Msgf("The run found %d violations of %s severity or higher, more than the %d the gate allows",
+			report.Gate.Violations, report.Gate.MinSeverity, report.Gate.MaxViolations)
+	}
+
+	if ctx.Err() != nil {
+		if checkpoints != nil && configurations.CheckpointFile != "" {
+			log.Warn().Msgf("The run was interrupted, resume it with -resume %s", checkpoints.RunId())
+		} else {
+			log.Warn().Msgf("The run was interrupted, the batches that weren't compared are recorded in %s",
+				configurations.DeadLetterFile)
+		}
+	}
+	log.Info().Msgf("Exiting with code %d", report.ExitCode)
+	return report.ExitCode
+}
+
+// initiateCheckpoints starts a new run, or resumes the run given with -resume from the time it first started and
+// with the seed of its sample, so that its relative period resolves the same and the same sample is drawn.
+func initiateCheckpoints(configurations *config.Configurations) *domain.Checkpoints {
+	if *resume == "" {
+		if configurations.Sample.IsEnabled() && configurations.Sample.Seed == 0 {
+			configurations.Sample.Seed = time.Now().UnixNano()
+		}
+		checkpoints, err := domain.NewCheckpoints(configurations.CheckpointFile, runStartTime,
+			configurations.Sample.Seed)
+		if err != nil {
+			log.Fatal().Msgf("Couldn't create the checkpoint file: %s", err)
+		}
+		if configurations.CheckpointFile != "" {
+			log.Info().Msgf("Run id: %s, an interrupted run can be resumed with -resume %s", checkpoints.RunId(),
+				checkpoints.RunId())
+		}
+		return checkpoints
+	}
+
+	checkpoints, err := domain.ResumeCheckpoints(configurations.CheckpointFile, *resume)
+	if err != nil {
+		log.Fatal().Msgf("Couldn't resume the run: %s", err)
+	}
+	runStartTime = checkpoints.RunStartedAt()
+	if configurations.Sample.IsEnabled() {
+		if checkpoints.SampleSeed() == 0 {
+			log.Fatal().Msgf("Couldn't resume the run: run %s recorded no sample seed, so its sample can't be "+
+				"drawn again", checkpoints.RunId())
+		}
+		configurations.Sample.Seed = checkpoints.SampleSeed()
+	}
+	log.Info().Msgf("Resuming run %s started at %s, skipping %d completed batches", checkpoints.RunId(),
+		helper.FormatTimeStamp(runStartTime), checkpoints.CompletedBatchCount())
+	return checkpoints
+}
 
-	orchestrateEventComparisons(service, configurations)
+// newService creates a service running the rules enabled in the configurations.
+func newService(configurations *config.Configurations, eventSource domain.EventSource,
+	saveRepo domain.SaveRepository, checkpoints *domain.Checkpoints, summary *domain.RunSummary) *domain.Service {
+	ruleFactory := comparator.NewRuleFactory(configurations)
+	activeRules := ruleFactory.GetEnabledRuleList()
+	activeCaseRules := ruleFactory.GetEnabledCaseRuleList()
+	if len(configurations.WatchedFields) > 0 && len(activeCaseRules) > 0 {
+		log.Warn().Msgf("Case rules are not applied when scanning the watched fields %s", configurations.WatchedFields)
+	}
+	return domain.NewService(configurations, &activeRules, &activeCaseRules, eventSource, saveRepo, checkpoints,
+		summary)
+}
+
+// initiateRepositories reads the events from the event file or the data store API when configured, without
+// connecting to any database, and from the database otherwise.
+func initiateRepositories(configurations *config.Configurations) (domain.EventSource, domain.SaveRepository) {
+	var eventSource domain.EventSource
+	switch {
+	case *eventFile != "":
+		fileEventSource, err := domain.NewFileEventSource(*eventFile)
+		if err != nil {
+			log.Fatal().Msgf("Couldn't load the event file: %s", err)
+		}
+		eventSource = fileEventSource
+		log.Info().Msgf("Scanning events from %s", *eventFile)
+	case configurations.BaseUrl != "":
+		eventSource = domain.NewApiEventSource(configurations.Api)
+		log.Info().Msgf("Scanning events from the data store API %s", configurations.BaseUrl)
+	default:
+		sourceDB, sinkDB := store.InitDatabases(configurations)
+		return domain.NewQueryRepository(sourceDB), domain.NewSaveRepository(sinkDB)
+	}
+
+	saveRepo, err := domain.NewFileSaveRepository(*reportFile)
+	if err != nil {
+		log.Fatal().Msgf("Couldn't create the report file: %s", err)
+	}
+	log.Info().Msgf("The report will be written to %s", *reportFile)
+
+	return eventSource, saveRepo
 }
 
 func validateConfigurations(c config.Configurations) {
@@ -57,17 +222,82 @@ func validateConfigurations(c config.Configurations) {
 		}
 	}()
 
-	// Check period start time
-	if isEmpty(c.Period.StartTime) {
-		log.Fatal().Msg("Validation error: Period start time is empty. Please provide a valid start time.")
-	} else {
-		helper.MustParseTime("", c.Period.StartTime)
-	}
-
 	// Check Jurisdiction and CaseId
 	if isEmpty(c.Jurisdiction) && isEmpty(c.CaseId) {
 		log.Fatal().Msg("Validation error: Either Jurisdiction or CaseId must be set. Please provide one of them.")
 	}
+
+	// Check oversized case handling
+	if c.OversizedCase != "isolate" && c.OversizedCase != "skip" {
+		log.Fatal().Msgf("Validation error: Oversized case '%s' is invalid. Please provide isolate or skip.",
+			c.OversizedCase)
+	}
+
+	// Check incremental scans
+	if c.Incremental && (*eventFile != "" || c.BaseUrl != "") {
+		log.Fatal().Msg("Validation error: Incremental scans keep their watermark in the report database. Please " +
+			"scan the database.")
+	}
+	if c.Incremental && isEmpty(c.WatermarkTable) {
+		log.Fatal().Msg("Validation error: Watermark table is empty. Please provide the table of the incremental " +
+			"scans.")
+	}
+
+	// Check data store API scans, the entries of the source file are checked once it is read
+	if *sourceFile == "" && !*retryFailed {
+		if err := validateApiScan(c); err != nil {
+			log.Fatal().Msgf("Validation error: %s. Please provide scan.caseId or scan.caseIdFile without "+
+				"scan.sample, or scan the database.", err)
+		}
+	}
+
+	// Check sampling
+	if c.Sample.Size < 0 || c.Sample.Percentage < 0 || c.Sample.Percentage > 100 {
+		log.Fatal().Msgf("Validation error: Sample size %d or percentage %g is invalid. Please provide a positive "+
+			"size or a percentage up to 100.", c.Sample.Size, c.Sample.Percentage)
+	}
+	if c.Sample.Stratify != "" && c.Sample.Stratify != "casetype" && c.Sample.Stratify != "eventcount" {
+		log.Fatal().Msgf("Validation error: Sample stratification '%s' is invalid. Please provide casetype or "+
+			"eventcount.", c.Sample.Stratify)
+	}
+	if c.Sample.ConfidenceLevel <= 0 || c.Sample.ConfidenceLevel >= 1 {
+		log.Fatal().Msgf("Validation error: Sample confidence level %g is invalid. Please provide a value between "+
+			"0 and 1.", c.Sample.ConfidenceLevel)
+	}
+
+	// Check severities and the violation gate
+	if err := comparator.ValidateSeverities(c.Severities); err != nil {
+		log.Fatal().Msgf("Validation error: Rule severity is invalid: %s. Please provide low, medium or high for "+
+			"a known rule.", err)
+	}
+	if _, err := comparator.ParseSeverity(c.Gate.MinSeverity); c.Gate.Enabled && err != nil {
+		log.Fatal().Msgf("Validation error: Gate %s. Please provide low, medium or high.", err)
+	}
+	if c.Gate.MaxViolations < 0 {
+		log.Fatal().Msgf("Validation error: Gate max violations %d is invalid. Please provide 0 or more.",
+			c.Gate.MaxViolations)
+	}
+}
+
+// validateApiScan rejects the scans the data store API can't run, as it only loads the events of the given cases.
+func validateApiScan(c config.Configurations) error {
+	if *eventFile != "" || isEmpty(c.BaseUrl) {
+		return nil
+	}
+	if c.Sample.IsEnabled() {
+		return errors.New("the data store API can't discover the cases to sample")
+	}
+	if isEmpty(c.CaseId) && isEmpty(c.CaseIdFile) {
+		return errors.New("the data store API can't discover the cases by period")
+	}
+	return nil
+}
+
+// validatePeriod resolves the period at the start of the run, so it is validated once a resumed run has set it.
+func validatePeriod(c config.Configurations) {
+	if _, _, err := c.Period.Resolve(runStartTime); err != nil {
+		log.Fatal().Msgf("Validation error: %s. Please provide a valid period.", err)
+	}
 }
 
 func isEmpty(value string) bool {
@@ -85,48 +315,150 @@ func loadConfig(configFile string) *config.Configurations {
 	return config.GetConfigurations(dirPath, fileNameWithoutExt)
 }
 
-func orchestrateEventComparisons(service *domain.Service, configurations *config.Configurations) {
-	log.Info().Msgf("Enabled roles: %s", configurations.Active)
+// orchestrateEventComparisons scans the configured jurisdiction and case type, or every entry of the source file in
+// plan order, each with the configuration overridden by its entry. The entries left once ctx is done are skipped.
+func orchestrateEventComparisons(ctx context.Context, configurations *config.Configurations,
+	eventSource domain.EventSource, saveRepo domain.SaveRepository, checkpoints *domain.Checkpoints,
+	summary *domain.RunSummary) {
+	if *sourceFile == "" {
+		log.Info().Msgf("Enabled roles: %s", configurations.Active)
+		startTime, endTime := comparisonPeriod(configurations)
+		service := newService(configurations, eventSource, saveRepo, checkpoints, summary)
+		performEventComparisonByJurisdiction(ctx, service, configurations.Jurisdiction, configurations.CaseType,
+			startTime, endTime, configurations.EventFilter, configurations.Incremental)
+		return
+	}
 
-	startTime := helper.MustParseTime("", configurations.StartTime)
+	scanPlan, err := domain.ReadScanPlan(*sourceFile, configurations.Period, runStartTime)
+	if err != nil {
+		log.Fatal().Msgf("Couldn't read the source file: %s", err)
+	}
+	for _, entry := range scanPlan {
+		if err := validateApiScan(*entry.Apply(*configurations)); err != nil {
+			log.Fatal().Msgf("Validation error: %s for jurisdiction: %s and caseType: %s of the source file. "+
+				"Please provide its case_ids, scan.caseId or scan.caseIdFile without scan.sample, or scan the "+
+				"database.", err, entry.Jurisdiction, entry.CaseTypeId)
+		}
+	}
 
-	var endTime time.Time
-	if strings.TrimSpace(configurations.EndTime) == "" {
-		endTime = time.Now()
-	} else {
-		endTime = helper.MustParseTime("", configurations.EndTime)
+	for i, entry := range scanPlan {
+		if ctx.Err() != nil {
+			log.Warn().Msgf("Skipping the last %d entries of the source file as the run was interrupted",
+				len(scanPlan)-i)
+			return
+		}
+
+		entryConfigurations := entry.Apply(*configurations)
+		log.Info().Msgf("Scanning - jurisdiction: %s and caseType: %s with priority: %d and enabled roles: %s",
+			entry.Jurisdiction, entry.CaseTypeId, entry.Priority, entryConfigurations.Active)
+
+		startTime, endTime := comparisonPeriod(entryConfigurations)
+		service := newService(entryConfigurations, eventSource, saveRepo, checkpoints, summary)
+		performEventComparisonByJurisdiction(ctx, service, entry.Jurisdiction, entry.CaseTypeId, startTime, endTime,
+			entryConfigurations.EventFilter, entryConfigurations.Incremental)
 	}
+}
 
-	if *sourceFile != "" {
-		jurisdictionWithCaseTypes := readCSVIntoMap(*sourceFile)
+// comparisonPeriod resolves the configured period at the start of the run, which ends then when no end time is set.
+// The configurations are set to the resolved times so that the rules use the same period.
+func comparisonPeriod(configurations *config.Configurations) (time.Time, time.Time) {
+	startTime, endTime, err := configurations.Period.Resolve(runStartTime)
+	if err != nil {
+		log.Fatal().Msgf("Invalid period for jurisdiction: %s and caseType: %s: %s", configurations.Jurisdiction,
+			configurations.CaseType, err)
+	}
 
-		var sortedJurisdictions []string
-		for jurisdiction := range jurisdictionWithCaseTypes {
-			sortedJurisdictions = append(sortedJurisdictions, jurisdiction)
-		}
-		sort.Strings(sortedJurisdictions)
-
-		for _, jurisdiction := range sortedJurisdictions {
-			caseTypes := jurisdictionWithCaseTypes[jurisdiction]
-			for _, caseType := range caseTypes {
-				log.Info().Msgf("Scanning - jurisdiction: %s and caseType: %s", jurisdiction, caseType)
-				performEventComparisonByJurisdiction(service, jurisdiction, caseType, startTime, endTime)
-			}
+	configurations.StartTime = startTime.Format(time.RFC3339Nano)
+	configurations.EndTime = endTime.Format(time.RFC3339Nano)
+	log.Info().Msgf("Period from %s to %s UTC", helper.FormatTimeStamp(startTime), helper.FormatTimeStamp(endTime))
+	return startTime, endTime
+}
+
+// discover writes the jurisdictions and case types with multi event cases in the configured period to a CSV that
+// can be passed as -sourceFile.
+func discover(args []string) {
+	discoverFlags := flag.NewFlagSet("discover", flag.ExitOnError)
+	discoverFlags.StringVar(configFile, "configFile", *configFile, "Configuration file")
+	discoverFlags.StringVar(eventFile, "eventFile", *eventFile, "NDJSON or CSV event export to discover instead of the database")
+	output := discoverFlags.String("output", "case_type_details.csv", "CSV file the case types are written to")
+	includeJurisdictions := discoverFlags.String("includeJurisdictions", "", "Comma separated jurisdictions to include")
+	excludeJurisdictions := discoverFlags.String("excludeJurisdictions", "", "Comma separated jurisdictions to exclude")
+	includeCaseTypes := discoverFlags.String("includeCaseTypes", "", "Comma separated case types to include")
+	excludeCaseTypes := discoverFlags.String("excludeCaseTypes", "", "Comma separated case types to exclude")
+	_ = discoverFlags.Parse(args)
+
+	configurations := loadConfig(*configFile)
+	initiateLogger(configurations.Level, configurations.Type)
+	defer elapsed("Discovery")()
+
+	if *eventFile == "" && !isEmpty(configurations.BaseUrl) {
+		log.Fatal().Msg("Validation error: The data store API can't discover case types. Please discover them " +
+			"from the database with an empty api.baseUrl, or from an event file.")
+	}
+
+	var eventSource domain.EventSource
+	if *eventFile != "" {
+		fileEventSource, err := domain.NewFileEventSource(*eventFile)
+		if err != nil {
+			log.Fatal().Msgf("Couldn't load the event file: %s", err)
 		}
-		return
+		eventSource = fileEventSource
+	} else {
+		sourceDB, _ := store.InitDatabases(configurations)
+		eventSource = domain.NewQueryRepository(sourceDB)
 	}
 
-	performEventComparisonByJurisdiction(service, configurations.Jurisdiction, configurations.CaseType, startTime, endTime)
+	startTime, endTime := comparisonPeriod(configurations)
+	filter := domain.DiscoveryFilter{
+		IncludeJurisdictions: splitFlagValues(*includeJurisdictions),
+		ExcludeJurisdictions: splitFlagValues(*excludeJurisdictions),
+		IncludeCaseTypes:     splitFlagValues(*includeCaseTypes),
+		ExcludeCaseTypes:     splitFlagValues(*excludeCaseTypes),
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Fatal().Msgf("Couldn't create the output file: %s", err)
+	}
+	defer file.Close()
+
+	count, err := domain.DiscoverCaseTypes(interruptContext(), eventSource,
+		domain.Comparison{StartTime: startTime, SearchPeriodEndTime: endTime}, filter, file)
+	if err != nil {
+		log.Fatal().Msgf("Couldn't discover the case types: %s", err)
+	}
+	log.Info().Msgf("%d case types with multi event cases between %s and %s have been written to %s", count,
+		helper.FormatTimeStamp(startTime), helper.FormatTimeStamp(endTime), *output)
 }
 
-func performEventComparisonByJurisdiction(service *domain.Service, jurisdiction string, caseType string, startTime time.Time, endTime time.Time) {
+func splitFlagValues(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func performEventComparisonByJurisdiction(ctx context.Context, service *domain.Service, jurisdiction string,
+	caseType string, startTime time.Time, endTime time.Time, eventFilter config.EventFilter, incremental bool) {
 	comparison := domain.Comparison{
 		Jurisdiction:        jurisdiction,
 		CaseTypeId:          caseType,
 		StartTime:           startTime,
 		SearchPeriodEndTime: endTime,
+		EventFilter:         eventFilter,
+	}
+	if !incremental {
+		service.CompareEventsInImpactPeriod(ctx, comparison)
+		return
+	}
+
+	if err := service.CompareEventsSinceWatermark(ctx, comparison, runStartTime); err != nil {
+		log.Error().Msgf("Incremental scan of jurisdiction: %s and caseType: %s failed: %s", jurisdiction, caseType,
+			err)
 	}
-	service.CompareEventsInImpactPeriod(comparison)
 }
 
 func enableAndManageProfiles() {
@@ -174,55 +506,15 @@ func openLogFile() (*os.File, error) {
 	return logFile, nil
 }
 
-func readCSVIntoMap(csvFilePath string) map[string][]string {
-	csvDataMap := make(map[string][]string)
-
-	csvFile, err := os.Open(csvFilePath)
-	if err != nil {
-		log.Fatal().Msgf("err opening CSV file: %s", err)
-	}
-	defer csvFile.Close()
-
-	scanner := bufio.NewScanner(csvFile)
-
-	// Skip header
-	if scanner.Scan() {
-	}
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		rowData := strings.Split(line, ",")
-
-		if len(rowData) < 2 {
-			continue
-		}
-
-		key := rowData[0]
-		value := rowData[1]
-
-		if _, found := csvDataMap[key]; !found {
-			csvDataMap[key] = make([]string, 0)
-		}
-
-		csvDataMap[key] = append(csvDataMap[key], value)
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Fatal().Msgf("err reading CSV file: %s", err)
-	}
-
-	return csvDataMap
-}
-
 func maskPassword(config string) string {
-	pattern := `"Password":\s*"[^"]*"`
+	pattern := `"(Password|S2SToken|IdamToken)":\s*"[^"]*"`
 
 	r, err := regexp.Compile(pattern)
 	if err != nil {
 		panic(err)
 	}
 
-	return r.ReplaceAllString(config, `"Password": "***"`)
+	return r.ReplaceAllString(config, `"$1": "***"`)
 }
 
 func printConfigurations(s interface{}) {